@@ -0,0 +1,136 @@
+package gson
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// MessagePack, CBOR等二进制格式与yaml等文本格式相同，先转为中间结构再生成json。
+// 二进制串没有对应的json类型，按encoding/json的方式转为base64字符串。
+
+type BinaryErr struct {
+	Format string
+	Offset int
+	Msg    string
+}
+
+func (de BinaryErr) Error() string {
+	return fmt.Sprintf("gson: %v offset %v: %v", de.Format, de.Offset, de.Msg)
+}
+
+// binReader 二进制解码的公共部分
+type binReader struct {
+	format string
+	b      []byte
+	i      int
+	depth  int
+}
+
+func (r *binReader) fail(msg string, a ...interface{}) error {
+	return BinaryErr{Format: r.format, Offset: r.i, Msg: fmt.Sprintf(msg, a...)}
+}
+
+// read 读取n字节，数据不足时返回错误
+func (r *binReader) read(n uint64) ([]byte, error) {
+	if n > uint64(len(r.b)-r.i) {
+		return nil, r.fail("unexpected end of input")
+	}
+	p := r.b[r.i : r.i+int(n)]
+	r.i += int(n)
+	return p, nil
+}
+
+// uint 读取n字节的大端无符号整数
+func (r *binReader) uint(n int) (uint64, error) {
+	p, err := r.read(uint64(n))
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(p[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(p)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(p)), nil
+	}
+	return binary.BigEndian.Uint64(p), nil
+}
+
+// count 检查容器元素个数，每个元素至少占一个字节，避免按伪造的长度分配内存
+func (r *binReader) count(n uint64) (int, error) {
+	if n > uint64(len(r.b)-r.i) {
+		return 0, r.fail("unexpected end of input")
+	}
+	return int(n), nil
+}
+
+func (r *binReader) enter() error {
+	r.depth++
+	if r.depth > maxNesting {
+		return r.fail("exceeded max depth")
+	}
+	return nil
+}
+
+func strNode(s string) *node {
+	n := newNode(TypString)
+	n.s = s
+	return n
+}
+
+// bytesNode 二进制串转为base64字符串
+func bytesNode(p []byte) *node {
+	return strNode(base64.StdEncoding.EncodeToString(p))
+}
+
+func rawNode(t Type, raw string) *node {
+	n := newNode(t)
+	n.raw = raw
+	return n
+}
+
+// floatNode 浮点数转为json数字，float32按其精确值输出；NaN和Inf不能表示为json
+func (r *binReader) floatNode(f float64) (*node, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, r.fail("%v cannot be represented in json", f)
+	}
+	return rawNode(TypNumber, strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
+
+// binNumber 二进制编码时的数值：整数用int64/uint64/big.Int，其它用float64
+type binNumber struct {
+	i     int64
+	u     uint64
+	big   *big.Int // 超出uint64的整数，f为其近似值
+	f     float64
+	isInt bool
+	isU   bool // 超出int64的正整数
+}
+
+// parseBinNumber 解析json数字字面量，1e3等整数值按整数编码
+func parseBinNumber(s string) (binNumber, error) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return binNumber{i: i, isInt: true}, nil
+	}
+	if r := parseRat(s); r != nil && r.IsInt() {
+		n := r.Num()
+		if n.IsInt64() {
+			return binNumber{i: n.Int64(), isInt: true}, nil
+		}
+		if n.IsUint64() {
+			return binNumber{u: n.Uint64(), isInt: true, isU: true}, nil
+		}
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return binNumber{big: n, f: f}, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return binNumber{}, fmt.Errorf("gson: number %v out of range", s)
+	}
+	return binNumber{f: f}, nil
+}
@@ -0,0 +1,590 @@
+package gson
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 节点与Go类型之间的直接转换，不经过整体MarshalJSON/Unmarshal。
+
+type DecodeErr struct {
+	Typ    Type   // 节点类型
+	GoType string // 目标类型
+	Path   string
+}
+
+func (de DecodeErr) Error() string {
+	return fmt.Sprintf("gson: cannot decode '%v' into Go value of type %v, path: %v",
+		de.Typ, de.GoType, de.Path)
+}
+
+// As 将g解码为T，类型不匹配时返回DecodeErr，
+// 例如: n, err := gson.As[int](g.Get("a.b"))
+func As[T any](g *GSON) (T, error) {
+	var v T
+	err := g.Decode(&v)
+	return v, err
+}
+
+// Decode 将节点解码到v，v须为非nil指针。
+// 已修改但尚未序列化的子树直接遍历节点，未修改且不含内嵌json字符串的部分使用原始数据解码；
+// 内嵌json字符串可直接解码为struct, map或slice。
+func (g *GSON) Decode(v interface{}) error {
+	if g.e != nil {
+		return g.e
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("gson: Decode(non-pointer %T)", v)
+	}
+	if g.v.miss != nil {
+		return g.check(TypUnknown)
+	}
+	return g.decode(rv.Elem())
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	marshalerType       = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (g *GSON) decodeErr(v reflect.Value) error {
+	return DecodeErr{Typ: g.Type(), GoType: v.Type().String(), Path: g.Path()}
+}
+
+func (g *GSON) decode(v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if g.IsNull() {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return g.decode(v.Elem())
+	}
+
+	pt := reflect.PtrTo(v.Type())
+	if pt.Implements(unmarshalerType) || pt.Implements(textUnmarshalerType) {
+		return g.decodeRaw(v)
+	}
+
+	switch g.Type() {
+	case TypObject:
+		if !g.u && !g.mayEmbed() {
+			return g.decodeRaw(v)
+		}
+		g.objInit()
+		return g.decodeObject(v)
+	case TypList:
+		if !g.u && !g.mayEmbed() {
+			return g.decodeRaw(v)
+		}
+		g.listInit()
+		return g.decodeList(v)
+	case TypString:
+		switch v.Kind() {
+		case reflect.Struct, reflect.Map:
+			g.objInit()
+			if g.v.o != nil {
+				return g.decodeObject(v)
+			}
+		case reflect.Slice, reflect.Array:
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				break // base64
+			}
+			g.listInit()
+			if g.v.l != nil {
+				return g.decodeList(v)
+			}
+		case reflect.Interface:
+			if g.v.o != nil {
+				return g.decodeObject(v)
+			}
+			if g.v.l != nil {
+				return g.decodeList(v)
+			}
+		}
+	}
+	return g.decodeRaw(v)
+}
+
+// decodeRaw 使用encoding/json解码当前数据，错误转换为DecodeErr
+func (g *GSON) decodeRaw(v reflect.Value) error {
+	b, err := g.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(b, v.Addr().Interface())
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) {
+		de := DecodeErr{Typ: jsonType(te.Value), GoType: te.Type.String(), Path: g.Path()}
+		if te.Field != "" {
+			if de.Path != "" {
+				de.Path += "."
+			}
+			de.Path += te.Field
+		}
+		return de
+	}
+	return err
+}
+
+func jsonType(s string) Type {
+	switch s {
+	case "object":
+		return TypObject
+	case "array":
+		return TypList
+	case "string":
+		return TypString
+	case "number":
+		return TypNumber
+	case "bool":
+		return TypBool
+	}
+	return Type(s)
+}
+
+func (g *GSON) decodeObject(v reflect.Value) error {
+	o := g.v.o
+	if o == nil {
+		return g.decodeErr(v)
+	}
+	o.complete()
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return g.decodeErr(v)
+		}
+		m := make(map[string]interface{}, len(o.ks))
+		for _, k := range o.ks {
+			var x interface{}
+			if err := o.mp[k].decode(reflect.ValueOf(&x).Elem()); err != nil {
+				return err
+			}
+			m[k] = x
+		}
+		v.Set(reflect.ValueOf(m))
+		return nil
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return g.decodeRaw(v)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(o.ks)))
+		}
+		for _, k := range o.ks {
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := o.mp[k].decode(e); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), e)
+		}
+		return nil
+
+	case reflect.Struct:
+		fs := cachedFields(v.Type())
+		for _, k := range o.ks {
+			f := fs.lookup(k)
+			if f == nil {
+				continue
+			}
+			c, fv := o.mp[k], fieldByIndex(v, f.index)
+			decode := c.decode
+			if f.quoted {
+				decode = c.decodeQuoted
+			}
+			if err := decode(fv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return g.decodeErr(v)
+}
+
+// decodeQuoted 解码带",string"选项的字段，节点须为包含json字面量的字符串
+func (g *GSON) decodeQuoted(v reflect.Value) error {
+	if g.IsNull() {
+		return g.decode(v)
+	}
+	if g.Type() != TypString {
+		return g.decodeErr(v)
+	}
+	if err := json.Unmarshal([]byte(g.Str()), v.Addr().Interface()); err != nil {
+		return g.decodeErr(v)
+	}
+	return nil
+}
+
+func (g *GSON) decodeList(v reflect.Value) error {
+	l := g.v.l
+	if l == nil {
+		return g.decodeErr(v)
+	}
+	l.complete()
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return g.decodeErr(v)
+		}
+		s := make([]interface{}, len(l.els))
+		for i, c := range l.els {
+			if err := c.decode(reflect.ValueOf(&s[i]).Elem()); err != nil {
+				return err
+			}
+		}
+		v.Set(reflect.ValueOf(s))
+		return nil
+
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), len(l.els), len(l.els))
+		for i, c := range l.els {
+			if err := c.decode(s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if i < len(l.els) {
+				if err := l.els[i].decode(v.Index(i)); err != nil {
+					return err
+				}
+			} else {
+				v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+			}
+		}
+		return nil
+	}
+	return g.decodeErr(v)
+}
+
+// fieldByIndex 同reflect.Value.FieldByIndex，但会分配nil的内嵌指针
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// Encode 将v直接构建为当前节点的子树，替换原有内容。
+// 与Set不同，struct, map, slice会直接生成子节点，无需再次扫描。
+func (g *GSON) Encode(v interface{}) error {
+	n := &GSON{}
+	if err := n.encode(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	g.change(func() {
+		g.replace(nil)
+		g.b, g.t, g.u = n.b, n.t, n.u
+		g.v.o, g.v.l = n.v.o, n.v.l
+		if g.v.o != nil {
+			g.v.o.p = g
+			for _, c := range g.v.o.mp {
+				c.p = g
+			}
+		}
+		if g.v.l != nil {
+			g.v.l.p = g
+			for _, c := range g.v.l.els {
+				c.p = g
+			}
+		}
+	})
+	return nil
+}
+
+func (g *GSON) encode(v reflect.Value) error {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			g.b = []byte("null")
+			g.t = TypNull
+			return nil
+		}
+		if v.Type().Implements(marshalerType) || v.Type().Implements(textMarshalerType) {
+			return g.encodeRaw(v)
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		g.b = []byte("null")
+		g.t = TypNull
+		return nil
+	}
+	if v.Type().Implements(marshalerType) || v.Type().Implements(textMarshalerType) {
+		return g.encodeRaw(v)
+	}
+	if v.CanAddr() {
+		pt := v.Addr().Type()
+		if pt.Implements(marshalerType) || pt.Implements(textMarshalerType) {
+			return g.encodeRaw(v.Addr())
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		o := &object{p: g, mp: make(map[string]*GSON), done: true}
+		for _, f := range cachedFields(v.Type()).list {
+			fv, ok := fieldByIndexNoAlloc(v, f.index)
+			if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			c := &GSON{p: g}
+			if err := c.encode(fv); err != nil {
+				return err
+			}
+			if f.quoted && c.t != TypNull {
+				c.b, _ = json.Marshal(string(c.b))
+				c.t = TypString
+			}
+			o.ks = append(o.ks, f.name)
+			o.mp[f.name] = c
+		}
+		g.t, g.v.o, g.u = TypObject, o, true
+		return nil
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return g.encodeRaw(v)
+		}
+		if v.IsNil() {
+			g.b = []byte("null")
+			g.t = TypNull
+			return nil
+		}
+		o := &object{p: g, mp: make(map[string]*GSON, v.Len()), done: true}
+		for _, k := range v.MapKeys() {
+			o.ks = append(o.ks, k.String())
+		}
+		sort.Strings(o.ks)
+		for _, k := range o.ks {
+			c := &GSON{p: g}
+			err := c.encode(v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key())))
+			if err != nil {
+				return err
+			}
+			o.mp[k] = c
+		}
+		g.t, g.v.o, g.u = TypObject, o, true
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return g.encodeRaw(v) // base64
+		}
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			g.b = []byte("null")
+			g.t = TypNull
+			return nil
+		}
+		l := &list{p: g, els: make([]*GSON, v.Len()), done: true}
+		for i := range l.els {
+			c := &GSON{p: g}
+			if err := c.encode(v.Index(i)); err != nil {
+				return err
+			}
+			l.els[i] = c
+		}
+		g.t, g.v.l, g.u = TypList, l, true
+		return nil
+	}
+	return g.encodeRaw(v)
+}
+
+func (g *GSON) encodeRaw(v reflect.Value) error {
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	g.b = b
+	g.t = guess(b)
+	return nil
+}
+
+func fieldByIndexNoAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+// - - - - - - - - - - struct fields - - - - - - - - - -
+
+type field struct {
+	name      string
+	index     []int
+	tagged    bool // json tag中指定了名字
+	omitEmpty bool
+	quoted    bool // ",string"选项，数值、bool和字符串编码为json字符串
+}
+
+type fields struct {
+	list   []field
+	byName map[string]*field
+}
+
+// lookup 先精确匹配，再忽略大小写匹配，同encoding/json
+func (fs *fields) lookup(name string) *field {
+	if f := fs.byName[name]; f != nil {
+		return f
+	}
+	for i := range fs.list {
+		if strings.EqualFold(fs.list[i].name, name) {
+			return &fs.list[i]
+		}
+	}
+	return nil
+}
+
+var fieldCache sync.Map // map[reflect.Type]*fields
+
+func cachedFields(t reflect.Type) *fields {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.(*fields)
+	}
+	var all []field
+	typeFields(t, nil, map[reflect.Type]bool{}, &all)
+
+	// 同名字段取最浅的，同一深度有多个时取唯一带tag的，否则全部忽略，
+	// 再按声明顺序排列，同encoding/json
+	sort.SliceStable(all, func(i, j int) bool {
+		return len(all[i].index) < len(all[j].index)
+	})
+	var names []string
+	byName := make(map[string][]field)
+	for _, f := range all {
+		if _, ok := byName[f.name]; !ok {
+			names = append(names, f.name)
+		}
+		byName[f.name] = append(byName[f.name], f)
+	}
+	fs := &fields{byName: make(map[string]*field)}
+	for _, name := range names {
+		if f, ok := dominantField(byName[name]); ok {
+			fs.list = append(fs.list, f)
+		}
+	}
+	sort.Slice(fs.list, func(i, j int) bool {
+		a, b := fs.list[i].index, fs.list[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	for i := range fs.list {
+		fs.byName[fs.list[i].name] = &fs.list[i]
+	}
+	actual, _ := fieldCache.LoadOrStore(t, fs)
+	return actual.(*fields)
+}
+
+// dominantField 从按深度排序的同名字段中选出生效的字段
+func dominantField(fs []field) (field, bool) {
+	i := 1
+	for i < len(fs) && len(fs[i].index) == len(fs[0].index) {
+		i++
+	}
+	if i == 1 {
+		return fs[0], true
+	}
+	var dom field
+	n := 0
+	for _, f := range fs[:i] {
+		if f.tagged {
+			dom = f
+			n++
+		}
+	}
+	return dom, n == 1
+}
+
+// typeFields 收集可导出字段，内嵌struct的字段被提升
+func typeFields(t reflect.Type, index []int, visiting map[reflect.Type]bool, all *[]field) {
+	if visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		idx := append(append([]int(nil), index...), i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if !sf.IsExported() && sf.Type.Kind() == reflect.Ptr {
+				continue
+			}
+			typeFields(ft, idx, visiting, all)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		tagged := name != ""
+		if !tagged {
+			name = sf.Name
+		}
+		quoted := false
+		if strings.Contains(","+opts+",", ",string,") {
+			switch ft.Kind() {
+			case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+				reflect.Float32, reflect.Float64, reflect.String:
+				quoted = true
+			}
+		}
+		*all = append(*all, field{
+			name:      name,
+			index:     idx,
+			tagged:    tagged,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+			quoted:    quoted,
+		})
+	}
+}
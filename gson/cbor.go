@@ -0,0 +1,393 @@
+package gson
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	cborUint = iota
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+/*
+FromCBOR 解码CBOR(RFC 8949)，map的key须为文本字符串，保持原始顺序。支持：
+定长和不定长的字符串、数组和map，半精度、单精度和双精度浮点数，
+bignum(tag 2, 3)和decimal fraction(tag 4)转为精确的数字字面量，
+字节串转为base64字符串，undefined转为null，其它tag忽略并保留其内容。
+*/
+func FromCBOR(b []byte) *GSON {
+	r := &binReader{format: "cbor", b: b}
+	n, err := r.cbor()
+	if err == nil && r.i < len(b) {
+		err = r.fail("unexpected data after top-level value")
+	}
+	if err != nil {
+		return &GSON{e: err}
+	}
+	return n.gson()
+}
+
+// cborHead 读取数据项头部，返回major type和参数，indef表示不定长
+func (r *binReader) cborHead() (major byte, arg uint64, indef bool, err error) {
+	p, err := r.read(1)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	major, info := p[0]>>5, p[0]&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), false, nil
+	case info <= 27:
+		arg, err = r.uint(1 << (info - 24))
+		return major, arg, false, err
+	case info == 31 && major != cborUint && major != cborNegInt && major != cborTag:
+		return major, 0, true, nil
+	}
+	r.i--
+	return 0, 0, false, r.fail("invalid additional information %v", info)
+}
+
+func (r *binReader) cbor() (*node, error) {
+	start := r.i
+	major, arg, indef, err := r.cborHead()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		return rawNode(TypNumber, strconv.FormatUint(arg, 10)), nil
+	case cborNegInt:
+		if arg <= math.MaxInt64 {
+			return rawNode(TypNumber, strconv.FormatInt(-1-int64(arg), 10)), nil
+		}
+		n := new(big.Int).SetUint64(arg)
+		return rawNode(TypNumber, n.Neg(n.Add(n, big.NewInt(1))).String()), nil
+	case cborBytes, cborText:
+		p, err := r.cborString(major, arg, indef)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborBytes {
+			return bytesNode(p), nil
+		}
+		return strNode(string(p)), nil
+	case cborArray:
+		return r.cborArray(arg, indef)
+	case cborMap:
+		return r.cborMap(arg, indef)
+	case cborTag:
+		return r.cborTag(arg)
+	}
+
+	switch arg {
+	case 20:
+		return rawNode(TypBool, "false"), nil
+	case 21:
+		return rawNode(TypBool, "true"), nil
+	case 22, 23: // null, undefined
+		return rawNode(TypNull, "null"), nil
+	}
+	if r.i-start == 1 { // 参数直接在头部中时不是浮点数
+		r.i = start
+		if indef {
+			return nil, r.fail("unexpected break")
+		}
+		return nil, r.fail("unsupported simple value %v", arg)
+	}
+	switch r.i - start {
+	case 3:
+		return r.floatNode(halfFloat(uint16(arg)))
+	case 5:
+		return r.floatNode(float64(math.Float32frombits(uint32(arg))))
+	case 9:
+		return r.floatNode(math.Float64frombits(arg))
+	}
+	r.i = start
+	return nil, r.fail("unsupported simple value %v", arg)
+}
+
+// cborString 读取字节串或文本串，不定长时拼接各个定长分段
+func (r *binReader) cborString(major byte, n uint64, indef bool) ([]byte, error) {
+	if !indef {
+		return r.read(n)
+	}
+	var buf []byte
+	for {
+		if r.i < len(r.b) && r.b[r.i] == 0xff {
+			r.i++
+			return buf, nil
+		}
+		at := r.i
+		m, n, indef, err := r.cborHead()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || indef {
+			r.i = at
+			return nil, r.fail("invalid chunk in indefinite-length string")
+		}
+		p, err := r.read(n)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, p...)
+	}
+}
+
+// cborEnd 判断容器是否结束：定长时已读取n个元素，不定长时遇到break
+func (r *binReader) cborEnd(i int, n int, indef bool) bool {
+	if !indef {
+		return i >= n
+	}
+	if r.i < len(r.b) && r.b[r.i] == 0xff {
+		r.i++
+		return true
+	}
+	return false
+}
+
+func (r *binReader) cborArray(n uint64, indef bool) (*node, error) {
+	cnt, err := r.count(n)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.enter(); err != nil {
+		return nil, err
+	}
+	a := newNode(TypList)
+	a.els = make([]*node, 0, cnt)
+	for i := 0; !r.cborEnd(i, cnt, indef); i++ {
+		v, err := r.cbor()
+		if err != nil {
+			return nil, err
+		}
+		a.els = append(a.els, v)
+	}
+	r.depth--
+	return a, nil
+}
+
+func (r *binReader) cborMap(n uint64, indef bool) (*node, error) {
+	cnt, err := r.count(n)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.enter(); err != nil {
+		return nil, err
+	}
+	m := newNode(TypObject)
+	for i := 0; !r.cborEnd(i, cnt, indef); i++ {
+		at := r.i
+		major, arg, indef, err := r.cborHead()
+		if err != nil {
+			return nil, err
+		}
+		if major != cborText {
+			r.i = at
+			return nil, r.fail("map key must be a text string")
+		}
+		k, err := r.cborString(major, arg, indef)
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.cbor()
+		if err != nil {
+			return nil, err
+		}
+		m.set(string(k), v)
+	}
+	r.depth--
+	return m, nil
+}
+
+func (r *binReader) cborTag(tag uint64) (*node, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { r.depth-- }()
+
+	start := r.i
+	switch tag {
+	case 2, 3: // bignum
+		major, arg, indef, err := r.cborHead()
+		if err != nil {
+			return nil, err
+		}
+		if major != cborBytes {
+			r.i = start
+			return nil, r.fail("bignum must be a byte string")
+		}
+		p, err := r.cborString(major, arg, indef)
+		if err != nil {
+			return nil, err
+		}
+		n := new(big.Int).SetBytes(p)
+		if tag == 3 {
+			n.Neg(n.Add(n, big.NewInt(1)))
+		}
+		return rawNode(TypNumber, n.String()), nil
+
+	case 4: // decimal fraction: [exponent, mantissa]
+		v, err := r.cbor()
+		if err != nil {
+			return nil, err
+		}
+		if v.t != TypList || len(v.els) != 2 || !isIntLiteral(v.els[0]) || !isIntLiteral(v.els[1]) {
+			r.i = start
+			return nil, r.fail("invalid decimal fraction")
+		}
+		exp, err := strconv.Atoi(v.els[0].raw)
+		if err != nil || exp > maxExp || exp < -maxExp {
+			r.i = start
+			return nil, r.fail("decimal fraction exponent out of range")
+		}
+		if exp == 0 {
+			return v.els[1], nil
+		}
+		return rawNode(TypNumber, v.els[1].raw+"e"+v.els[0].raw), nil
+	}
+	return r.cbor()
+}
+
+func isIntLiteral(n *node) bool {
+	return n.t == TypNumber && !strings.ContainsAny(n.raw, ".eE")
+}
+
+// halfFloat 半精度浮点数转为float64
+func halfFloat(h uint16) float64 {
+	exp, frac := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(frac+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// ToCBOR 编码为CBOR，整数使用最短格式，超出64位的整数使用bignum，
+// 小数在float32可精确表示时使用float32，否则使用float64
+func (g *GSON) ToCBOR() ([]byte, error) {
+	if g.e != nil {
+		return nil, g.e
+	}
+	buf := &bytes.Buffer{}
+	if err := writeCBOR(buf, g); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCBOR(buf *bytes.Buffer, g *GSON) error {
+	switch g.Type() {
+	case TypObject:
+		ks, err := g.keys()
+		if err != nil {
+			return err
+		}
+		writeCBORHead(buf, cborMap, uint64(len(ks)))
+		for _, k := range ks {
+			writeCBORHead(buf, cborText, uint64(len(k)))
+			buf.WriteString(k)
+			if err := writeCBOR(buf, g.ObjIdx(k)); err != nil {
+				return err
+			}
+		}
+	case TypList:
+		els, err := g.items()
+		if err != nil {
+			return err
+		}
+		writeCBORHead(buf, cborArray, uint64(len(els)))
+		for _, c := range els {
+			if err := writeCBOR(buf, c); err != nil {
+				return err
+			}
+		}
+	case TypString:
+		s := g.Str()
+		writeCBORHead(buf, cborText, uint64(len(s)))
+		buf.WriteString(s)
+	case TypNumber:
+		n, err := parseBinNumber(g.numStr())
+		if err != nil {
+			return err
+		}
+		switch {
+		case n.isU:
+			writeCBORHead(buf, cborUint, n.u)
+		case n.isInt && n.i >= 0:
+			writeCBORHead(buf, cborUint, uint64(n.i))
+		case n.isInt:
+			writeCBORHead(buf, cborNegInt, uint64(-(n.i + 1)))
+		case n.big != nil:
+			p := new(big.Int).Set(n.big)
+			if p.Sign() >= 0 {
+				writeCBORHead(buf, cborTag, 2)
+			} else {
+				writeCBORHead(buf, cborTag, 3)
+				p.Neg(p).Sub(p, big.NewInt(1))
+			}
+			b := p.Bytes()
+			writeCBORHead(buf, cborBytes, uint64(len(b)))
+			buf.Write(b)
+		case float64(float32(n.f)) == n.f:
+			buf.WriteByte(cborSimple<<5 | 26)
+			writeBigEndian(buf, uint64(math.Float32bits(float32(n.f))), 4)
+		default:
+			buf.WriteByte(cborSimple<<5 | 27)
+			writeBigEndian(buf, math.Float64bits(n.f), 8)
+		}
+	case TypBool:
+		if g.Bool() {
+			buf.WriteByte(cborSimple<<5 | 21)
+		} else {
+			buf.WriteByte(cborSimple<<5 | 20)
+		}
+	case TypNull:
+		buf.WriteByte(cborSimple<<5 | 22)
+	default:
+		return TypOpErr{Op: "cbor", Typ: g.Type(), Path: g.Path()}
+	}
+	return nil
+}
+
+// writeCBORHead 输出数据项头部，参数使用最短编码
+func writeCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		writeBigEndian(buf, arg, 2)
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		writeBigEndian(buf, arg, 4)
+	default:
+		buf.WriteByte(major<<5 | 27)
+		writeBigEndian(buf, arg, 8)
+	}
+}
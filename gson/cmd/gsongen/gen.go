@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type generator struct {
+	structs []*bytes.Buffer // 按定义顺序
+	funcs   bytes.Buffer
+	names   map[string]bool // 已使用的顶层名字
+	strconv bool            // 访问函数中用到了下标
+}
+
+// generate 生成包pkg中以name为根类型的代码，accessors为true时同时生成GSON访问函数
+func generate(pkg, name string, s *shape, accessors bool) ([]byte, error) {
+	g := &generator{names: make(map[string]bool)}
+	name = exportName(name)
+	if s.kinds&^kNull == kObject && len(s.keys) > 0 {
+		g.structType(s, name)
+	} else {
+		name = g.unique(name)
+		t, _ := g.goType(s, name+"Item")
+		buf := &bytes.Buffer{}
+		fmt.Fprintf(buf, "type %v %v\n", name, t)
+		g.structs = append([]*bytes.Buffer{buf}, g.structs...)
+	}
+	if accessors {
+		g.accessors(s, name, nil, nil, true)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by gsongen; DO NOT EDIT.\n\npackage %v\n\n", pkg)
+	if accessors && g.funcs.Len() > 0 {
+		buf.WriteString("import (\n")
+		if g.strconv {
+			buf.WriteString("\"strconv\"\n\n")
+		}
+		buf.WriteString("\"github.com/eachain/common/gson\"\n)\n\n")
+	}
+	for _, b := range g.structs {
+		buf.Write(b.Bytes())
+		buf.WriteByte('\n')
+	}
+	buf.Write(g.funcs.Bytes())
+	return format.Source(buf.Bytes())
+}
+
+// unique 返回未使用的顶层名字，重名时添加数字后缀
+func (g *generator) unique(name string) string {
+	n := name
+	for i := 2; g.names[n]; i++ {
+		n = name + strconv.Itoa(i)
+	}
+	g.names[n] = true
+	return n
+}
+
+// goType 返回s对应的Go类型，name为需要定义结构体时使用的名字；isStruct表示返回的是结构体类型
+func (g *generator) goType(s *shape, name string) (t string, isStruct bool) {
+	switch s.kinds &^ kNull {
+	case kObject:
+		if len(s.keys) == 0 {
+			return "map[string]interface{}", false
+		}
+		t, isStruct = g.structType(s, name), true
+	case kArray:
+		if s.elem == nil {
+			return "[]interface{}", false
+		}
+		t, _ = g.goType(s.elem, name)
+		return "[]" + t, false
+	default:
+		if t, _ = s.scalar(); t == "" {
+			return "interface{}", false
+		}
+	}
+	if s.nullable() {
+		return "*" + t, false
+	}
+	return t, isStruct
+}
+
+// structType 定义结构体并返回其名字
+func (g *generator) structType(s *shape, name string) string {
+	name = g.unique(name)
+	buf := &bytes.Buffer{}
+	g.structs = append(g.structs, buf)
+
+	fmt.Fprintf(buf, "type %v struct {\n", name)
+	used := make(map[string]bool)
+	for _, k := range s.keys {
+		if !validTag(k) {
+			fmt.Fprintf(buf, "// %v 不能用作json tag，请使用访问函数\n", strconv.Quote(k))
+			continue
+		}
+		field := exportName(k)
+		for i := 2; used[field]; i++ {
+			field = exportName(k) + strconv.Itoa(i)
+		}
+		used[field] = true
+
+		f := s.fields[k]
+		t, isStruct := g.goType(f, name+field)
+		tag := k
+		if s.optional(k) {
+			tag += ",omitempty"
+			if isStruct {
+				t = "*" + t
+			}
+		}
+		fmt.Fprintf(buf, "%v %v `json:%v`\n", field, t, strconv.Quote(tag))
+	}
+	buf.WriteString("}\n")
+	return name
+}
+
+// part 路径的一段：字面量或下标变量
+type part struct {
+	lit string
+	idx string
+}
+
+// accessors 为s及其子节点生成访问函数，fn为函数名(不含Get前缀)，params为路径中的下标参数
+func (g *generator) accessors(s *shape, fn string, path []part, params []string, root bool) {
+	if s.kinds&^kNull == kArray && s.elem != nil {
+		g.accessor(fn+"Len", path, params, "int", "Len()", "的长度")
+		p := indexName(len(params))
+		path = append(path[:len(path):len(path)], part{lit: "["}, part{idx: p}, part{lit: "]"})
+		g.accessors(s.elem, fn, path, append(params[:len(params):len(params)], p), false)
+		return
+	}
+	if !root {
+		if typ, method := s.scalar(); typ != "" {
+			if s.nullable() {
+				g.nullableAccessor(fn, path, params, typ, method+"()")
+			} else {
+				g.accessor(fn, path, params, typ, method+"()", "")
+			}
+			return
+		}
+		g.accessor(fn, path, params, "*gson.GSON", "", "")
+	}
+	if s.kinds&^kNull != kObject {
+		return
+	}
+	for _, k := range s.keys {
+		seg := keySeg(k)
+		if len(path) > 0 && seg[0] != '[' {
+			seg = "." + seg
+		}
+		sub := append(path[:len(path):len(path)], part{lit: seg})
+		g.accessors(s.fields[k], fn+exportName(k), sub, params, false)
+	}
+}
+
+// accessor 输出一个访问函数：返回g.Get(path)调用call的结果，what补充注释说明
+func (g *generator) accessor(fn string, path []part, params []string, typ, call, what string) {
+	fn = g.unique("Get" + fn)
+	show, args, expr := g.getExpr(path, params)
+	if call != "" {
+		expr += "." + call
+	}
+	fmt.Fprintf(&g.funcs, "// %v 返回%v%v\nfunc %v(%v) %v {\nreturn %v\n}\n\n", fn, show, what, fn, args, typ, expr)
+}
+
+// nullableAccessor 输出可能为null的标量的访问函数，返回(值, 是否存在且不为null)
+func (g *generator) nullableAccessor(fn string, path []part, params []string, typ, call string) {
+	fn = g.unique("Get" + fn)
+	show, args, expr := g.getExpr(path, params)
+	fmt.Fprintf(&g.funcs, "// %v 返回%v，为null或不存在时ok为false\n", fn, show)
+	fmt.Fprintf(&g.funcs, "func %v(%v) (v %v, ok bool) {\nn := %v\n", fn, args, typ, expr)
+	fmt.Fprintf(&g.funcs, "if n.IsNull() || n.Type() == gson.TypUnknown {\nreturn\n}\nreturn n.%v, true\n}\n\n", call)
+}
+
+// getExpr 返回路径的注释写法、函数参数和取值表达式
+func (g *generator) getExpr(path []part, params []string) (show, args, expr string) {
+	var sb strings.Builder
+	var exprs []string
+	lit := ""
+	for _, p := range path {
+		if p.idx == "" {
+			sb.WriteString(p.lit)
+			lit += p.lit
+			continue
+		}
+		sb.WriteString(p.idx)
+		if lit != "" {
+			exprs = append(exprs, strconv.Quote(lit))
+			lit = ""
+		}
+		exprs = append(exprs, "strconv.Itoa("+p.idx+")")
+		g.strconv = true
+	}
+	if lit != "" {
+		exprs = append(exprs, strconv.Quote(lit))
+	}
+
+	args = "g *gson.GSON"
+	if len(params) > 0 {
+		args += ", " + strings.Join(params, ", ") + " int"
+	}
+	expr = "g.Get(" + strings.Join(exprs, " + ") + ")"
+	if len(exprs) == 0 {
+		expr = "g"
+		sb.WriteString("根节点")
+	}
+	return sb.String(), args, expr
+}
+
+func indexName(i int) string {
+	if i < 3 {
+		return string(rune('i' + i))
+	}
+	return "i" + strconv.Itoa(i)
+}
+
+// keySeg 返回key在Get路径中的写法，包含'.', '[', ']'或为空时写作["key"]
+func keySeg(k string) string {
+	if k == "" || strings.ContainsAny(k, ".[]") {
+		q, _ := json.Marshal(k)
+		return "[" + string(q) + "]"
+	}
+	return k
+}
+
+// commonInitialisms 按Go命名习惯全部大写的单词
+var commonInitialisms = map[string]bool{
+	"API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true,
+	"HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"QPS": true, "RAM": true, "SQL": true, "SSH": true, "TCP": true, "TLS": true,
+	"TTL": true, "UDP": true, "UI": true, "UID": true, "URI": true, "URL": true,
+	"UTF8": true, "UUID": true, "VM": true, "XML": true,
+}
+
+// exportName 将key转为导出的Go标识符：user_id -> UserID, createdAt -> CreatedAt
+func exportName(k string) string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = nil
+		}
+	}
+	rs := []rune(k)
+	for i, r := range rs {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(cur) > 0 &&
+			(unicode.IsLower(cur[len(cur)-1]) || i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+			flush()
+		}
+		cur = append(cur, r)
+	}
+	flush()
+
+	var b strings.Builder
+	for _, w := range words {
+		if up := strings.ToUpper(w); commonInitialisms[up] {
+			b.WriteString(up)
+			continue
+		}
+		rs := []rune(w)
+		b.WriteRune(unicode.ToUpper(rs[0]))
+		b.WriteString(string(rs[1:]))
+	}
+	name := b.String()
+	if name == "" {
+		return "Field"
+	}
+	if r := []rune(name)[0]; !unicode.IsUpper(r) {
+		return "X" + name
+	}
+	return name
+}
+
+// validTag 同encoding/json对tag名字的要求
+func validTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
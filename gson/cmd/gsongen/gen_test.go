@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eachain/common/gson"
+)
+
+func TestGenerate(t *testing.T) {
+	s := &shape{}
+	for _, sample := range []string{
+		`{"user_id":1,"name":"a","score":1,"tags":["x"],"profile":{"homeURL":"u"},"items":[{"id":1,"price":1.5}],"a.b":true,"matrix":[[1]]}`,
+		`{"user_id":2,"name":null,"score":2.5,"tags":[],"profile":{"homeURL":"v","age":3},"items":[{"id":2}],"a.b":false,"matrix":[],"mixed":1}`,
+		`{"user_id":3,"name":"c","score":3,"profile":null,"items":[],"a.b":true,"matrix":[],"mixed":"s"}`,
+	} {
+		s.add(gson.FromString(sample))
+	}
+	src, err := generate("api", "resp", s, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := strings.Join(strings.Fields(string(src)), " ")
+	for _, want := range []string{
+		"package api",
+		"type Resp struct { UserID int64 `json:\"user_id\"` Name *string `json:\"name\"` Score float64 `json:\"score\"` " +
+			"Tags []string `json:\"tags,omitempty\"` Profile *RespProfile `json:\"profile\"` Items []RespItems `json:\"items\"` " +
+			"AB bool `json:\"a.b\"` Matrix [][]int64 `json:\"matrix\"` Mixed interface{} `json:\"mixed,omitempty\"` }",
+		"type RespProfile struct { HomeURL string `json:\"homeURL\"` Age int64 `json:\"age,omitempty\"` }",
+		"type RespItems struct { ID int64 `json:\"id\"` Price float64 `json:\"price,omitempty\"` }",
+		`func GetRespProfileHomeURL(g *gson.GSON) string { return g.Get("profile.homeURL").Str() }`,
+		`func GetRespName(g *gson.GSON) (v string, ok bool) { n := g.Get("name") if n.IsNull() || n.Type() == gson.TypUnknown { return } return n.Str(), true }`,
+		`func GetRespItemsPrice(g *gson.GSON, i int) float64 { return g.Get("items[" + strconv.Itoa(i) + "].price").Float() }`,
+		`func GetRespAB(g *gson.GSON) bool { return g.Get("[\"a.b\"]").Bool() }`,
+		`func GetRespMatrix(g *gson.GSON, i, j int) int64 { return g.Get("matrix[" + strconv.Itoa(i) + "][" + strconv.Itoa(j) + "]").Int() }`,
+		`func GetRespMixed(g *gson.GSON) *gson.GSON { return g.Get("mixed") }`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("missing %v in:\n%s", want, src)
+		}
+	}
+
+	s = &shape{}
+	s.add(gson.FromString(`[{"x":1}]`))
+	src, err = generate("main", "list", s, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code = strings.Join(strings.Fields(string(src)), " ")
+	if !strings.Contains(code, "type List []ListItem") ||
+		!strings.Contains(code, `func GetListLen(g *gson.GSON) int { return g.Len() }`) ||
+		!strings.Contains(code, `func GetListX(g *gson.GSON, i int) int64 { return g.Get("[" + strconv.Itoa(i) + "].x").Int() }`) {
+		t.Fatalf("root list:\n%s", src)
+	}
+}
+
+func TestExportName(t *testing.T) {
+	for k, want := range map[string]string{
+		"user_id":   "UserID",
+		"createdAt": "CreatedAt",
+		"HTTPCode":  "HTTPCode",
+		"api-url":   "APIURL",
+		"2fa":       "X2fa",
+		"":          "Field",
+		"$ref":      "Ref",
+	} {
+		if got := exportName(k); got != want {
+			t.Fatalf("exportName(%q) = %v, want %v", k, got, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/eachain/common/gson"
+)
+
+// kind 样本中出现过的值类型
+type kind uint8
+
+const (
+	kNull kind = 1 << iota
+	kBool
+	kInt
+	kFloat
+	kString
+	kObject
+	kArray
+)
+
+// shape 合并全部样本后某一路径上的结构
+type shape struct {
+	kinds kind
+
+	// kObject
+	keys    []string
+	fields  map[string]*shape
+	present map[string]int // 各key在多少个对象中出现
+	objects int            // 作为对象出现的次数
+
+	// kArray
+	elem *shape
+}
+
+// add 合并样本g
+func (s *shape) add(g *gson.GSON) {
+	switch g.Type() {
+	case gson.TypNull:
+		s.kinds |= kNull
+	case gson.TypBool:
+		s.kinds |= kBool
+	case gson.TypString:
+		s.kinds |= kString
+	case gson.TypNumber:
+		if isInt(string(g.Number())) {
+			s.kinds |= kInt
+		} else {
+			s.kinds |= kFloat
+		}
+	case gson.TypObject:
+		s.kinds |= kObject
+		s.objects++
+		if s.fields == nil {
+			s.fields = make(map[string]*shape)
+			s.present = make(map[string]int)
+		}
+		for _, k := range g.Keys() {
+			f := s.fields[k]
+			if f == nil {
+				f = &shape{}
+				s.fields[k] = f
+				s.keys = append(s.keys, k)
+			}
+			s.present[k]++
+			f.add(g.ObjIdx(k))
+		}
+	case gson.TypList:
+		s.kinds |= kArray
+		if s.elem == nil {
+			s.elem = &shape{}
+		}
+		for i, n := 0, g.Len(); i < n; i++ {
+			s.elem.add(g.Index(i))
+		}
+	}
+}
+
+// isInt 字面量是否为int64范围内的整数，1.0和1e3按浮点数处理
+func isInt(s string) bool {
+	if strings.ContainsAny(s, ".eE") {
+		return false
+	}
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+// optional key在部分对象中缺失
+func (s *shape) optional(k string) bool {
+	return s.present[k] < s.objects
+}
+
+// nullable 出现过null，且有其它类型
+func (s *shape) nullable() bool {
+	return s.kinds&kNull != 0 && s.kinds&^kNull != 0
+}
+
+// scalar 返回标量对应的Go类型和GSON取值方法，不是单一标量类型时返回""
+func (s *shape) scalar() (typ, method string) {
+	switch s.kinds &^ kNull {
+	case kBool:
+		return "bool", "Bool"
+	case kInt:
+		return "int64", "Int"
+	case kFloat, kInt | kFloat:
+		return "float64", "Float"
+	case kString:
+		return "string", "Str"
+	}
+	return "", ""
+}
@@ -0,0 +1,85 @@
+/*
+gsongen 根据样例json生成Go结构体和GSON访问函数。
+
+	gsongen -type User -pkg api user1.json user2.json > user.go
+
+每个文件可包含多个连续的json值(如NDJSON)，全部样例合并推断结构：
+部分样例中缺失的字段为可选(omitempty)，出现过null的字段为指针，
+整数和小数混合时为float64，类型不一致时为interface{}。
+访问函数使用与Get兼容的路径，列表元素通过下标参数访问，
+出现过null的标量返回(值, ok)，为null或不存在时ok为false。
+没有文件参数时从标准输入读取。
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/eachain/common/gson"
+)
+
+func main() {
+	typ := flag.String("type", "Root", "根类型名")
+	pkg := flag.String("pkg", "main", "生成代码的包名")
+	out := flag.String("o", "", "输出文件，默认输出到标准输出")
+	accessors := flag.Bool("accessors", true, "生成GSON访问函数")
+	flag.Parse()
+
+	if err := run(*typ, *pkg, *out, *accessors, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "gsongen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(typ, pkg, out string, accessors bool, files []string) error {
+	s := &shape{}
+	n := 0
+	read := func(name string, r io.Reader) error {
+		sr := gson.NewStreamReader(r)
+		for {
+			g, err := sr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+			g.SetEmbeddedJSON(false)
+			s.add(g)
+			n++
+		}
+	}
+
+	if len(files) == 0 {
+		if err := read("stdin", os.Stdin); err != nil {
+			return err
+		}
+	}
+	for _, name := range files {
+		fp, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = read(name, fp)
+		fp.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if n == 0 {
+		return fmt.Errorf("no samples")
+	}
+
+	src, err := generate(pkg, typ, s, accessors)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0644)
+}
@@ -0,0 +1,124 @@
+package gson
+
+import (
+	"encoding/json"
+)
+
+// 内嵌json字符串：内容为json对象或列表的字符串，例如{"data": "{\"a\":1}"}，
+// 默认可像普通对象一样访问g.Get("data.a")，修改后仍按字符串输出。
+
+// maxEmbed 内嵌json最多解析的编码层数，"\"{\\\"a\\\":1}\""为2层
+const maxEmbed = 8
+
+// SetEmbeddedJSON 设置是否解析内嵌json字符串，默认开启。
+// 对g及其所有子节点生效，应在访问子节点之前设置。
+func (g *GSON) SetEmbeddedJSON(on bool) {
+	g.noEmbed = !on
+}
+
+func (g *GSON) embedOff() bool {
+	for ; g != nil; g = g.p {
+		if g.noEmbed {
+			return true
+		}
+	}
+	return false
+}
+
+// mayEmbed 未修改的子树中是否可能有内嵌json字符串，即内容以'{', '['或转义字符开头的字符串
+func (g *GSON) mayEmbed() bool {
+	if g.embedOff() {
+		return false
+	}
+	b := g.b
+	for i := 0; i < len(b); i++ {
+		if b[i] != '"' {
+			continue
+		}
+		j := i + 1
+		for j < len(b) {
+			if b[j] == ' ' || b[j] == '\t' {
+				j++
+			} else if b[j] == '\\' && j+1 < len(b) && (b[j+1] == 'n' || b[j+1] == 'r' || b[j+1] == 't') {
+				j += 2
+			} else {
+				break
+			}
+		}
+		if j < len(b) && (b[j] == '{' || b[j] == '[' || b[j] == '\\') {
+			return true
+		}
+		end, err := scanString(b, i)
+		if err != nil {
+			return true
+		}
+		i = end - 1
+	}
+	return false
+}
+
+// embedded 返回字符串中内嵌的json对象或列表，以及编码层数
+func (g *GSON) embedded() ([]byte, int) {
+	if g.Type() != TypString || g.embedOff() {
+		return nil, 0
+	}
+	s := g.Str()
+	for n := 1; n <= maxEmbed; n++ {
+		b := []byte(s)
+		switch first(b) {
+		case '{', '[':
+			if json.Valid(b) {
+				return b, n
+			}
+			return nil, 0
+		case '"':
+			if json.Unmarshal(b, &s) != nil {
+				return nil, 0
+			}
+		default:
+			return nil, 0
+		}
+	}
+	return nil, 0
+}
+
+// IsEmbedded 是否为内嵌json字符串
+func (g *GSON) IsEmbedded() bool {
+	b, _ := g.embedded()
+	return b != nil
+}
+
+// Unwrap 将内嵌json字符串替换为其中的对象或列表(包括已做的修改)，多层编码一次全部展开
+func (g *GSON) Unwrap() error {
+	if g.e != nil {
+		return g.e
+	}
+	b, _ := g.embedded()
+	if b == nil {
+		return TypOpErr{Op: "unwrap", Typ: g.Type(), Path: g.Path()}
+	}
+	g.reset(b)
+	return nil
+}
+
+// Wrap 将对象或列表编码为json字符串，Unwrap的逆操作
+func (g *GSON) Wrap() error {
+	if g.e != nil {
+		return g.e
+	}
+	switch g.Type() {
+	case TypObject, TypList:
+	default:
+		return TypOpErr{Op: "wrap", Typ: g.Type(), Path: g.Path()}
+	}
+	b, err := g.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	b, err = json.Marshal(string(b))
+	if err != nil {
+		return err
+	}
+	g.reset(b)
+	return nil
+}
@@ -0,0 +1,314 @@
+package gson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoder 按选项输出GSON，接口同json.Encoder。
+// 默认未修改的子树原样输出，设置了缩进、排序等选项时会重新序列化。
+type Encoder struct {
+	w          io.Writer
+	prefix     string
+	indent     string
+	sortKeys   bool
+	canonical  bool
+	escapeHTML bool
+	compact    bool
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, escapeHTML: true}
+}
+
+// SetIndent 同json.Encoder.SetIndent
+func (enc *Encoder) SetIndent(prefix, indent string) {
+	enc.prefix = prefix
+	enc.indent = indent
+}
+
+// SetSortKeys 对象的key按字典序输出
+func (enc *Encoder) SetSortKeys(on bool) {
+	enc.sortKeys = on
+}
+
+// SetCanonical 按RFC 8785 (JCS)输出，用于签名或哈希，
+// 开启后忽略缩进、排序和HTML转义选项。
+func (enc *Encoder) SetCanonical(on bool) {
+	enc.canonical = on
+}
+
+// SetEscapeHTML 同json.Encoder.SetEscapeHTML，默认开启
+func (enc *Encoder) SetEscapeHTML(on bool) {
+	enc.escapeHTML = on
+}
+
+// SetCompact 未修改的子树也重新紧凑序列化，去除原始数据中的空白
+func (enc *Encoder) SetCompact(on bool) {
+	enc.compact = on
+}
+
+// Encode 输出g，末尾附加换行符，同json.Encoder
+func (enc *Encoder) Encode(g *GSON) error {
+	b, err := enc.marshal(g)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = enc.w.Write(b)
+	return err
+}
+
+func (enc *Encoder) marshal(g *GSON) ([]byte, error) {
+	if g.e != nil {
+		return nil, g.e
+	}
+	if enc.canonical {
+		c := *enc
+		c.prefix, c.indent, c.sortKeys, c.escapeHTML, c.compact = "", "", true, false, true
+		enc = &c
+	}
+	buf := &bytes.Buffer{}
+	err := enc.write(buf, g, 0)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalIndent 同json.MarshalIndent
+func (g *GSON) MarshalIndent(prefix, indent string) ([]byte, error) {
+	enc := &Encoder{prefix: prefix, indent: indent, escapeHTML: true}
+	return enc.marshal(g)
+}
+
+// MarshalCanonical 按RFC 8785输出，相同语义的文档输出字节相同
+func (g *GSON) MarshalCanonical() ([]byte, error) {
+	enc := &Encoder{canonical: true}
+	return enc.marshal(g)
+}
+
+// keepRaw 未修改的子树b是否可原样输出。
+// 原始数据中未转义的'<', '>', '&'及U+2028, U+2029与重新序列化的结果不一致，需要重新序列化
+func (enc *Encoder) keepRaw(b []byte) bool {
+	if enc.compact || enc.sortKeys || enc.indent != "" || enc.prefix != "" {
+		return false
+	}
+	if enc.escapeHTML && bytes.ContainsAny(b, "<>&") {
+		return false
+	}
+	return !bytes.Contains(b, lineSep) && !bytes.Contains(b, paraSep)
+}
+
+var (
+	lineSep = []byte("\u2028")
+	paraSep = []byte("\u2029")
+)
+
+func (enc *Encoder) newline(buf *bytes.Buffer, depth int) {
+	if enc.indent == "" && enc.prefix == "" {
+		return
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(enc.prefix)
+	for i := 0; i < depth; i++ {
+		buf.WriteString(enc.indent)
+	}
+}
+
+func (enc *Encoder) write(buf *bytes.Buffer, g *GSON, depth int) error {
+	if !g.u && len(g.b) > 0 && enc.keepRaw(g.b) {
+		buf.Write(bytes.TrimSpace(g.b))
+		return nil
+	}
+
+	switch g.Type() {
+	case TypObject:
+		ks, err := g.keys()
+		if err != nil {
+			return err
+		}
+		if len(ks) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		if enc.canonical {
+			sort.Slice(ks, func(i, j int) bool { return utf16Less(ks[i], ks[j]) })
+		} else if enc.sortKeys {
+			sort.Strings(ks)
+		}
+		buf.WriteByte('{')
+		for i, k := range ks {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			enc.newline(buf, depth+1)
+			enc.writeString(buf, k)
+			buf.WriteByte(':')
+			if enc.indent != "" || enc.prefix != "" {
+				buf.WriteByte(' ')
+			}
+			if err := enc.write(buf, g.v.o.mp[k], depth+1); err != nil {
+				return err
+			}
+		}
+		enc.newline(buf, depth)
+		buf.WriteByte('}')
+		return nil
+
+	case TypList:
+		els, err := g.items()
+		if err != nil {
+			return err
+		}
+		if len(els) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i, c := range els {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			enc.newline(buf, depth+1)
+			if err := enc.write(buf, c, depth+1); err != nil {
+				return err
+			}
+		}
+		enc.newline(buf, depth)
+		buf.WriteByte(']')
+		return nil
+
+	case TypString:
+		b, err := g.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		s, err := unquote(bytes.TrimSpace(b))
+		if err != nil {
+			return err
+		}
+		enc.writeString(buf, s)
+		return nil
+
+	case TypNumber:
+		if !enc.canonical {
+			buf.Write(bytes.TrimSpace(g.b))
+			return nil
+		}
+		f, err := strconv.ParseFloat(string(bytes.TrimSpace(g.b)), 64)
+		if err != nil {
+			return err
+		}
+		s, err := formatES6(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+		return nil
+
+	case TypBool, TypNull:
+		buf.Write(bytes.TrimSpace(g.b))
+		return nil
+	}
+
+	b, err := g.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSpace(b))
+	return nil
+}
+
+func (enc *Encoder) writeString(buf *bytes.Buffer, s string) {
+	if enc.canonical {
+		writeCanonicalString(buf, s)
+		return
+	}
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(enc.escapeHTML)
+	e.Encode(s)
+	buf.Truncate(buf.Len() - 1) // '\n'
+}
+
+// writeCanonicalString 按RFC 8785转义：只转义'"', '\\'和控制字符
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, n := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && n == 1 {
+				buf.WriteString("\uFFFD")
+			} else {
+				buf.WriteString(s[i : i+n])
+			}
+			i += n
+			continue
+		}
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < ' ' {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[c>>4])
+				buf.WriteByte(hex[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+		i++
+	}
+	buf.WriteByte('"')
+}
+
+// utf16Less 按UTF-16码元比较，RFC 8785要求的key顺序
+func utf16Less(a, b string) bool {
+	x, y := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if x[i] != y[i] {
+			return x[i] < y[i]
+		}
+	}
+	return len(x) < len(y)
+}
+
+// formatES6 按ECMAScript Number.prototype.toString格式化
+func formatES6(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", &json.UnsupportedValueError{Str: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	abs := math.Abs(f)
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	// 1e+21, 1e-07 -> 1e+21, 1e-7
+	mant, exp, _ := strings.Cut(s, "e")
+	sign := exp[0]
+	exp = strings.TrimLeft(exp[1:], "0")
+	return mant + "e" + string(sign) + exp, nil
+}
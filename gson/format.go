@@ -0,0 +1,98 @@
+package gson
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// YAML, TOML, JSON5等格式先转为json，再按json访问和修改。
+
+type FormatErr struct {
+	Format string
+	Line   int
+	Col    int
+	Msg    string
+}
+
+func (fe FormatErr) Error() string {
+	return fmt.Sprintf("gson: %v line %v col %v: %v", fe.Format, fe.Line, fe.Col, fe.Msg)
+}
+
+// formatErr 生成b[i]处的错误，行列号从1开始，列按字节计算
+func formatErr(format string, b []byte, i int, msg string, a ...interface{}) error {
+	if i > len(b) {
+		i = len(b)
+	}
+	line := bytes.Count(b[:i], []byte{'\n'}) + 1
+	col := i - bytes.LastIndexByte(b[:i], '\n')
+	return FormatErr{Format: format, Line: line, Col: col, Msg: fmt.Sprintf(msg, a...)}
+}
+
+// maxNesting 嵌套层数限制，同encoding/json
+const maxNesting = 10000
+
+// node 解析时使用的中间结构，保持key顺序
+type node struct {
+	t   Type
+	s   string // TypString的内容
+	raw string // TypNumber, TypBool, TypNull的json字面量
+	ks  []string
+	mp  map[string]*node
+	els []*node
+
+	// toml
+	header   bool // 由[table]定义
+	dotted   bool // 由a.b = 1定义
+	inline   bool // 内联表或数组，不可再修改
+	arrTable bool // 由[[table]]定义的数组
+}
+
+func newNode(t Type) *node {
+	n := &node{t: t}
+	if t == TypObject {
+		n.mp = make(map[string]*node)
+	}
+	return n
+}
+
+func (n *node) set(k string, v *node) {
+	if _, ok := n.mp[k]; !ok {
+		n.ks = append(n.ks, k)
+	}
+	n.mp[k] = v
+}
+
+func (n *node) writeJSON(buf *bytes.Buffer) {
+	switch n.t {
+	case TypObject:
+		buf.WriteByte('{')
+		for i, k := range n.ks {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			n.mp[k].writeJSON(buf)
+		}
+		buf.WriteByte('}')
+	case TypList:
+		buf.WriteByte('[')
+		for i, c := range n.els {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			c.writeJSON(buf)
+		}
+		buf.WriteByte(']')
+	case TypString:
+		writeCanonicalString(buf, n.s)
+	default:
+		buf.WriteString(n.raw)
+	}
+}
+
+func (n *node) gson() *GSON {
+	buf := &bytes.Buffer{}
+	n.writeJSON(buf)
+	return FromBytes(buf.Bytes())
+}
@@ -1,8 +1,6 @@
 package gson
 
 import (
-	"fmt"
-	"strconv"
 	"strings"
 )
 
@@ -28,6 +26,8 @@ func (g *GSON) Any(keys ...string) *GSON {
 }
 
 // Get支持类型'key.list[0][1].k'式取值。
+// 下标可为负数，表示倒数第几个；包含'.'的key可写作'["a.b"]'。
+// 多值查询(通配符、切片、过滤等)请使用Query。
 func (g *GSON) Get(smartKey string) *GSON {
 	es, err := parseSmartKey(smartKey)
 	if err != nil {
@@ -41,64 +41,26 @@ func (g *GSON) Get(smartKey string) *GSON {
 
 type entry func(*GSON) *GSON
 
-func invalidFormat(i int, s string) error {
-	return fmt.Errorf("gson: smart key %vth part invalid format: '%v'", i, s)
-}
-
 func parseSmartKey(keys string) ([]entry, error) {
-	var entries []entry
-	parts := strings.Split(keys, ".")
-	for i := 0; i < len(parts); i++ {
-		part := parts[i]
-		l := strings.IndexByte(part, '[')
-		r := strings.IndexByte(part, ']')
-		if l < 0 {
-			if r > 0 {
-				return nil, invalidFormat(i, part)
-			}
-
-			entries = append(entries, func(j *GSON) *GSON {
-				return j.ObjIdx(part)
-			})
-			continue
-		}
-		if l == 0 {
-			if i > 0 { // "[0]"
-				return nil, invalidFormat(i, part)
-			}
-			if r < 0 {
-				return nil, invalidFormat(i, part)
-			}
-		}
-		if l > 0 {
-			if (r < 0) || (r > 0 && r < l) {
-				return nil, invalidFormat(i, part)
-			}
-
+	sels, err := parseSmartPath(keys)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]entry, 0, len(sels))
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case keySel:
 			entries = append(entries, func(j *GSON) *GSON {
-				return j.ObjIdx(part[:l])
+				return j.ObjIdx(string(s))
 			})
-		}
-
-		tmp := part[l:]
-		for tmp != "" {
-			if tmp[0] != '[' {
-				return nil, invalidFormat(i, part)
-			}
-			r = strings.IndexByte(tmp, ']')
-			if r < 0 {
-				return nil, invalidFormat(i, part)
-			}
-			idx, err := strconv.Atoi(tmp[1:r])
-			if err != nil {
-				return nil, fmt.Errorf("gson: smart key %vth invalid index: '%v'",
-					i, part)
-			}
+		case indexSel:
 			entries = append(entries, func(j *GSON) *GSON {
-				return j.Index(idx)
+				i := int(s)
+				if i < 0 && j.Len()+i >= 0 { // [-1]为最后一个
+					i += j.Len()
+				}
+				return j.Index(i)
 			})
-
-			tmp = tmp[r+1:]
 		}
 	}
 	return entries, nil
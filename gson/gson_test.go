@@ -1,6 +1,16 @@
 package gson
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -66,6 +76,62 @@ func TestNumber(t *testing.T) {
 	testFloat(t, `-1.23`, -1.23)
 	testFloatStr(t, `"1.23"`, 1.23)
 	testFloatStr(t, `"-1.23"`, -1.23)
+
+	testInt(t, `1e3`, 1000)
+	testInt(t, `1.5E3`, 1500)
+	testInt(t, `2.0`, 2)
+	testIntStr(t, `"1e3"`, 1000)
+	testFloat(t, `1e-3`, 0.001)
+	testFloat(t, `1e30`, 1e30)
+	testFloat(t, `12345678901234567890`, 12345678901234567890)
+
+	// Str在IsInt之前调用
+	g := FromString(`"123"`)
+	if g.Str() != "123" || !g.IsInt() || g.Int() != 123 {
+		t.Fatal("string number:", g.IsInt(), g.Int())
+	}
+}
+
+func TestBigNumber(t *testing.T) {
+	g := FromString(`{"id": 18446744073709551615, "big": 123456789012345678901234567890, "d": 0.1, "e": 1e3, "n": -1, "s": "abc"}`)
+	if u := g.Get("id").Uint(); u != 18446744073709551615 {
+		t.Fatal("uint:", u)
+	}
+	if u := g.Get("e").Uint(); u != 1000 {
+		t.Fatal("uint:", u)
+	}
+	if u := g.Get("n").Uint(); u != 0 {
+		t.Fatal("uint:", u)
+	}
+	if n := g.Get("big").BigInt(); n == nil || n.String() != "123456789012345678901234567890" {
+		t.Fatal("big int:", n)
+	}
+	if n := g.Get("d").BigInt(); n != nil {
+		t.Fatal("big int:", n)
+	}
+	if d := g.Get("d").Decimal(); d == nil || d.RatString() != "1/10" {
+		t.Fatal("decimal:", d)
+	}
+	if n := g.Get("big").Number(); n != "123456789012345678901234567890" {
+		t.Fatal("number:", n)
+	}
+	if n := g.Get("s").Number(); n != "" {
+		t.Fatal("number:", n)
+	}
+	if d := FromString(`1e1000000000`).Decimal(); d != nil {
+		t.Fatal("decimal:", d)
+	}
+
+	if err := g.Get("d").SetNumber("0.10000000000000000000001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Get("e").SetNumber("0x10"); err == nil {
+		t.Fatal("invalid number literal accepted")
+	}
+	g.Get("big").Set(json.Number("98765432109876543210"))
+	if s := marshalString(t, g); s != `{"id":18446744073709551615,"big":98765432109876543210,"d":0.10000000000000000000001,"e":1e3,"n":-1,"s":"abc"}` {
+		t.Fatal("marshal:", s)
+	}
 }
 
 func stringsEqual(a, b []string) bool {
@@ -212,3 +278,1575 @@ func TestPath(t *testing.T) {
 		t.Fatal("path:", path)
 	}
 }
+
+func TestGetQuotedKey(t *testing.T) {
+	g := FromString(`{"a.b": {"c": [1, 2, 3]}}`)
+	if i := g.Get(`["a.b"].c[-1]`).Int(); i != 3 {
+		t.Fatal("object get:", i)
+	}
+	if i := g.Get(`['a.b'].c[-3]`).Int(); i != 1 {
+		t.Fatal("object get:", i)
+	}
+	if path := g.Get(`["a.b"].c[1]`).Path(); path != `["a.b"].c[1]` {
+		t.Fatal("path:", path)
+	}
+	if g.Get(`a[x]`).Err() == nil {
+		t.Fatal("invalid smart key should fail")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	g := FromString(`{"items": [
+		{"id": 1, "price": 5, "tags": ["a"]},
+		{"id": 2, "price": 12, "tags": ["b"]},
+		{"id": 3, "price": 20, "sub": {"id": 4}}
+	]}`)
+
+	ids := func(r *Result) []int64 {
+		if r.Err() != nil {
+			t.Fatal("query:", r.Err())
+		}
+		var is []int64
+		r.Each(func(_ int, g *GSON) bool {
+			is = append(is, g.Int())
+			return true
+		})
+		return is
+	}
+	equal := func(a, b []int64) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, c := range []struct {
+		path string
+		ids  []int64
+	}{
+		{`items[*].id`, []int64{1, 2, 3}},
+		{`$.items[-1].id`, []int64{3}},
+		{`$.items[1:3].id`, []int64{2, 3}},
+		{`$.items[::-1].id`, []int64{3, 2, 1}},
+		{`$.items[0,2].id`, []int64{1, 3}},
+		{`$..id`, []int64{1, 2, 3, 4}},
+		{`$.items[?(@.price > 10)].id`, []int64{2, 3}},
+		{`$.items[?(@.price > 10 && !@.sub)].id`, []int64{2}},
+		{`$.items[?(@.tags[0] == 'a' || @.id == 3)].id`, []int64{1, 3}},
+		{`$['items'][*]["id"]`, []int64{1, 2, 3}},
+	} {
+		if is := ids(g.Query(c.path)); !equal(is, c.ids) {
+			t.Fatalf("query %v: %v", c.path, is)
+		}
+	}
+
+	if g.Query(`$.items[?(@.price >)]`).Err() == nil {
+		t.Fatal("invalid query should fail")
+	}
+
+	r := g.Query(`$.items[?(@.price < 10)].price`)
+	if r.Len() != 1 {
+		t.Fatal("query len:", r.Len())
+	}
+	r.Set(10)
+	if i := g.Get("items[0].price").Int(); i != 10 {
+		t.Fatal("query set:", i)
+	}
+
+	g.Query(`$..sub`).Remove()
+	if n := g.Query(`$..id`).Len(); n != 3 {
+		t.Fatal("query remove:", n)
+	}
+}
+
+func TestLazy(t *testing.T) {
+	// 只展开路径上的值，其他值只匹配括号，内部的非法内容不影响取值
+	g := FromString(`{"a": {"b": [1, {"c": "x"}, ]}, "z": [1 2]}`)
+	if s := g.Get("a.b[1].c").Str(); s != "x" {
+		t.Fatal("lazy get:", s)
+	}
+	if z := g.Get("z"); z.Len() != 0 || z.Err() == nil {
+		t.Fatal("invalid list:", z.Len(), z.Err())
+	}
+
+	g = FromString(`{"k": 1, "k": 2, "s": "a\"}"}`)
+	if i := g.ObjIdx("k").Int(); i != 2 {
+		t.Fatal("duplicate key:", i)
+	}
+	if s := g.ObjIdx("s").Str(); s != `a"}` {
+		t.Fatal("escaped string:", s)
+	}
+	if !stringsEqual(g.Keys(), []string{"k", "s"}) {
+		t.Fatal("object keys:", g.Keys())
+	}
+
+	g = FromString(`{"a": [1, 2, 3], "b": {}}`)
+	g.Get("a[1]").Set(5)
+	g.ObjIdx("c").Set(true)
+	b, _ := g.MarshalJSON()
+	if string(b) != `{"a":[1,5,3],"b":{},"c":true}` {
+		t.Fatal("marshal:", string(b))
+	}
+
+	// 扫描到的错误使节点成为错误节点
+	g = FromString(`[1, 2 3, 4]`)
+	g.Index(0).Set(9)
+	if n := g.Len(); n != 0 || g.Err() == nil {
+		t.Fatal("malformed list:", n, g.Err())
+	}
+	if err := g.Index(1).Set(9); err == nil {
+		t.Fatal("set on malformed list")
+	}
+	if b, err := g.MarshalJSON(); err == nil {
+		t.Fatal("marshal malformed list:", string(b))
+	}
+	g = FromString(`{"a": 1 "b": 2}`)
+	if g.ObjIdx("a").Err() == nil || g.Keys() != nil {
+		t.Fatal("malformed object:", g.Keys())
+	}
+	g.Set(M{"a": 1})
+	if g.Err() != nil || g.ObjIdx("a").Int() != 1 {
+		t.Fatal("reset malformed object:", g.Err())
+	}
+
+	// keys和items返回扫描错误，不返回残缺的结果
+	g = FromString(`{"x": {"a": 1 "b": 2}, "y": [1 2], "z": {"c": [3]}}`)
+	if ks, err := g.ObjIdx("x").keys(); ks != nil || err == nil {
+		t.Fatal("keys of malformed object:", ks, err)
+	}
+	if els, err := g.ObjIdx("y").items(); els != nil || err == nil {
+		t.Fatal("items of malformed list:", els, err)
+	}
+	if ks, err := g.ObjIdx("z").keys(); len(ks) != 1 || err != nil {
+		t.Fatal("keys:", ks, err)
+	}
+	if els, err := g.Get("z.c").items(); len(els) != 1 || err != nil {
+		t.Fatal("items:", els, err)
+	}
+
+	for _, s := range []string{"0", "-1", "1.5", "-0.5e10", "2E+3", "1e-2"} {
+		if typ := FromString(s).Type(); typ != TypNumber {
+			t.Errorf("%q: %v", s, typ)
+		}
+	}
+	for _, s := range []string{"1-2e-", "01", "1.", ".5", "-", "1e", "1e+", "--1", "1.2.3"} {
+		if typ := FromString(s).Type(); typ != TypUnknown {
+			t.Errorf("%q: %v", s, typ)
+		}
+		if l := FromString("[" + s + "]"); l.Len() != 0 || l.Err() == nil {
+			t.Errorf("[%v] should fail", s)
+		}
+	}
+}
+
+func bigDoc() []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"a": {"b": {"c": 123}}, "pad": [`)
+	for i := 0; i < 50000; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"id": %d, "name": "item-%d", "tags": ["x", "y"]}`, i, i)
+	}
+	sb.WriteString(`], "z": {"b": {"c": 456}}}`)
+	return []byte(sb.String())
+}
+
+func BenchmarkGetFirst(b *testing.B) {
+	doc := bigDoc()
+	b.SetBytes(int64(len(doc)))
+	for i := 0; i < b.N; i++ {
+		if FromBytes(doc).Get("a.b.c").Int() != 123 {
+			b.Fatal("get")
+		}
+	}
+}
+
+func BenchmarkGetLast(b *testing.B) {
+	doc := bigDoc()
+	b.SetBytes(int64(len(doc)))
+	for i := 0; i < b.N; i++ {
+		if FromBytes(doc).Get("z.b.c").Int() != 456 {
+			b.Fatal("get")
+		}
+	}
+}
+
+// eagerGet 旧实现的取值路径：每层都用json.Decoder逐个解码全部键值对，再按key取值
+func eagerGet(p []byte, keys ...string) (json.RawMessage, error) {
+	for _, key := range keys {
+		dec := json.NewDecoder(bytes.NewReader(p))
+		if t, e := dec.Token(); e != nil || t != json.Delim('{') {
+			return nil, errors.New("expect '{'")
+		}
+		var ks []string
+		mp := make(map[string]json.RawMessage)
+		for dec.More() {
+			t, e := dec.Token()
+			if e != nil {
+				return nil, e
+			}
+			k, _ := t.(string)
+			var b json.RawMessage
+			if e = dec.Decode(&b); e != nil {
+				return nil, e
+			}
+			ks = append(ks, k)
+			mp[k] = b
+		}
+		if _, e := dec.Token(); e != nil {
+			return nil, e
+		}
+		p = mp[key]
+	}
+	return p, nil
+}
+
+// 对照：旧实现的整体解码
+func BenchmarkEagerGetFirst(b *testing.B) {
+	doc := bigDoc()
+	b.SetBytes(int64(len(doc)))
+	for i := 0; i < b.N; i++ {
+		raw, err := eagerGet(doc, "a", "b", "c")
+		if err != nil || FromBytes(raw).Int() != 123 {
+			b.Fatal("get:", err)
+		}
+	}
+}
+
+func BenchmarkEagerGetLast(b *testing.B) {
+	doc := bigDoc()
+	b.SetBytes(int64(len(doc)))
+	for i := 0; i < b.N; i++ {
+		raw, err := eagerGet(doc, "z", "b", "c")
+		if err != nil || FromBytes(raw).Int() != 456 {
+			b.Fatal("get:", err)
+		}
+	}
+}
+
+type bindBase struct {
+	ID int64 `json:"id"`
+}
+
+type bindItem struct {
+	bindBase
+	Name  string            `json:"name"`
+	Tags  []string          `json:"tags,omitempty"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+	Sub   *bindItem         `json:"sub,omitempty"`
+	Skip  string            `json:"-"`
+}
+
+func TestDecode(t *testing.T) {
+	g := FromString(`{"item": {"id": 1, "name": "a", "tags": ["x"], "sub": "{\"id\": 2}"}}`)
+	g.Get("item.tags[1]").Set("y")
+	g.Get("item.attrs.k").Set("v")
+
+	var it bindItem
+	if err := g.ObjIdx("item").Decode(&it); err != nil {
+		t.Fatal("decode:", err)
+	}
+	if it.ID != 1 || it.Name != "a" || len(it.Tags) != 2 || it.Tags[1] != "y" ||
+		it.Attrs["k"] != "v" || it.Sub == nil || it.Sub.ID != 2 {
+		t.Fatalf("decode: %+v", it)
+	}
+
+	// 未修改的树同样解码内嵌json字符串，结果与修改历史无关
+	for _, doc := range []string{
+		`{"id": 1, "sub": "{\"id\": 2, \"sub\": \"{\\\"id\\\": 3}\"}"}`,
+		`{"id": 1, "sub": " \n{\"id\": 2, \"sub\": {\"id\": 3}}"}`,
+		`{"id": 1, "sub": {"id": 2, "sub": "{\"id\": 3}"}}`,
+	} {
+		var it bindItem
+		if err := FromString(doc).Decode(&it); err != nil || it.ID != 1 || it.Sub == nil || it.Sub.ID != 2 ||
+			it.Sub.Sub == nil || it.Sub.Sub.ID != 3 {
+			t.Fatalf("decode unmodified %v: %+v, %v", doc, it, err)
+		}
+	}
+	var items []bindItem
+	if err := FromString(`[{"tags": "[\"x\"]", "attrs": "{\"k\": \"v\"}"}]`).Decode(&items); err != nil ||
+		len(items) != 1 || len(items[0].Tags) != 1 || items[0].Attrs["k"] != "v" {
+		t.Fatalf("decode unmodified list: %+v, %v", items, err)
+	}
+	var plain bindItem
+	if err := FromString(`{"name": "{x", "tags": ["[y"]}`).Decode(&plain); err != nil || plain.Name != "{x" ||
+		plain.Tags[0] != "[y" {
+		t.Fatalf("decode plain string: %+v, %v", plain, err)
+	}
+
+	if n, err := As[int](g.Get("item.id")); err != nil || n != 1 {
+		t.Fatal("as:", n, err)
+	}
+	_, err := As[int](g.Get("item.name"))
+	de, ok := err.(DecodeErr)
+	if !ok || de.Path != "item.name" || de.Typ != TypString {
+		t.Fatal("as error:", err)
+	}
+	_, err = As[[]int](g.Get("item.tags"))
+	if de, ok := err.(DecodeErr); !ok || de.Path != "item.tags[0]" {
+		t.Fatal("as error:", err)
+	}
+	if _, err = As[int](g.Get("item.none")); err == nil {
+		t.Fatal("as missing key should fail")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	g := FromString(`{"a": 1}`)
+	err := g.ObjIdx("b").Encode(bindItem{
+		bindBase: bindBase{ID: 3},
+		Name:     "n",
+		Attrs:    map[string]string{"y": "2", "x": "1"},
+		Skip:     "s",
+	})
+	if err != nil {
+		t.Fatal("encode:", err)
+	}
+	if s := g.Get("b.attrs.x").Str(); s != "1" {
+		t.Fatal("encode get:", s)
+	}
+	if p := g.Get("b.attrs.x").Path(); p != "b.attrs.x" {
+		t.Fatal("encode path:", p)
+	}
+	b, _ := g.MarshalJSON()
+	if string(b) != `{"a":1,"b":{"id":3,"name":"n","attrs":{"x":"1","y":"2"}}}` {
+		t.Fatal("encode marshal:", string(b))
+	}
+}
+
+type bindA struct {
+	X int
+	Y int `json:"y"`
+	Z int
+}
+
+type bindB struct {
+	X int
+	Y int
+	Z int `json:"Z"`
+}
+
+type bindQuoted struct {
+	bindA
+	bindB
+	N  int64   `json:"n,string"`
+	F  float64 `json:"f,string"`
+	B  bool    `json:"b,string"`
+	S  string  `json:"s,string"`
+	P  *int    `json:"p,string"`
+	NP *int    `json:"np,string"`
+	L  []int   `json:"l,string"` // 非标量忽略string选项
+}
+
+// 同名字段和",string"选项的处理与encoding/json一致
+func TestBindFields(t *testing.T) {
+	p := 7
+	v := bindQuoted{bindA: bindA{1, 2, 3}, bindB: bindB{4, 5, 6}, N: 1 << 60, F: 1.5, B: true, S: `a"b`, P: &p, L: []int{1}}
+	want, _ := json.Marshal(v)
+	g := FromString(`{}`)
+	if err := g.Encode(v); err != nil {
+		t.Fatal("encode:", err)
+	}
+	if s := marshalString(t, g); s != string(want) {
+		t.Fatalf("encode:\n%s\nwant:\n%s", s, want)
+	}
+
+	var got, exp bindQuoted
+	if err := FromBytes(want).Decode(&got); err != nil {
+		t.Fatal("decode:", err)
+	}
+	json.Unmarshal(want, &exp)
+	if !reflect.DeepEqual(got, exp) || got.N != 1<<60 || *got.P != 7 || got.S != `a"b` || got.bindA.X != 0 {
+		t.Fatalf("decode: %+v, want %+v", got, exp)
+	}
+
+	if strings.Contains(string(want), `"X"`) {
+		t.Fatal("ambiguous field encoded:", string(want))
+	}
+	got = bindQuoted{}
+	if err := FromString(`{"X": 9, "Z": 9}`).Decode(&got); err != nil || got.bindA.X != 0 || got.bindB.X != 0 ||
+		got.bindA.Z != 0 || got.bindB.Z != 9 {
+		t.Fatalf("decode ambiguous: %+v, %v", got, err)
+	}
+
+	err := FromString(`{"n": 1}`).Decode(&got)
+	if de, ok := err.(DecodeErr); !ok || de.Path != "n" || de.Typ != TypNumber {
+		t.Fatal("quoted number:", err)
+	}
+	err = FromString(`{"s": "abc"}`).Decode(&got)
+	if de, ok := err.(DecodeErr); !ok || de.Path != "s" || de.Typ != TypString {
+		t.Fatal("quoted string:", err)
+	}
+
+	sc, err := SchemaFromStruct(bindQuoted{})
+	if err != nil {
+		t.Fatal("schema:", err)
+	}
+	if vs := sc.Validate(FromBytes(want)); len(vs) != 0 || sc.root.Get("properties.X").Type() != TypUnknown {
+		t.Fatal("schema:", vs)
+	}
+}
+
+func marshalString(t *testing.T, g *GSON) string {
+	b, err := g.MarshalJSON()
+	if err != nil {
+		t.Fatal("marshal:", err)
+	}
+	return string(b)
+}
+
+func TestApplyPatch(t *testing.T) {
+	for _, c := range []struct {
+		doc, patch, want string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc"]}]`, `{"foo":["bar",["abc"]]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`},
+		{`{"a/b":{"m~n":1}}`, `[{"op":"copy","from":"/a~1b/m~0n","path":"/c"}]`, `{"a/b":{"m~n":1},"c":1}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+	} {
+		g := FromString(c.doc)
+		p, err := DecodePatch([]byte(c.patch))
+		if err != nil {
+			t.Fatal("decode patch:", err)
+		}
+		if err = g.ApplyPatch(p); err != nil {
+			t.Fatalf("apply %v: %v", c.patch, err)
+		}
+		if s := marshalString(t, g); s != c.want {
+			t.Fatalf("apply %v: %v", c.patch, s)
+		}
+	}
+
+	g := FromString(`{"baz":"qux","foo":"bar"}`)
+	p, _ := DecodePatch([]byte(`[{"op":"remove","path":"/foo"},{"op":"test","path":"/baz","value":"bar"}]`))
+	if err := g.ApplyPatch(p); err == nil {
+		t.Fatal("failed test should return error")
+	}
+	if s := marshalString(t, g); s != `{"baz":"qux","foo":"bar"}` {
+		t.Fatal("patch not rolled back:", s)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	g := FromString(`{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"x"}`)
+	err := g.MergePatch([]byte(`{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`))
+	if err != nil {
+		t.Fatal("merge patch:", err)
+	}
+	want := `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"x","phoneNumber":"+01-123-456-7890"}`
+	if s := marshalString(t, g); s != want {
+		t.Fatal("merge patch:", s)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := `{"a":1,"b":{"c":[1,2,3],"d":"x"},"e":true}`
+	b := `{"a":2,"b":{"c":[1,5],"f":null},"g":[1]}`
+	p := Diff(FromString(a), FromString(b))
+	g := FromString(a)
+	if err := g.ApplyPatch(p); err != nil {
+		t.Fatal("apply diff:", err)
+	}
+	if !Equal(g, FromString(b)) {
+		t.Fatal("apply diff:", marshalString(t, g))
+	}
+	if p := Diff(FromString(a), FromString(a)); len(p) != 0 {
+		t.Fatal("diff of equal documents:", p)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	s, err := NewSchema(FromString(`{
+		"type": "object",
+		"required": ["id", "name"],
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"name": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+			"kind": {"enum": ["a", "b"]},
+			"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}, "maxItems": 2},
+			"price": {"oneOf": [{"type": "integer"}, {"type": "string"}]}
+		},
+		"additionalProperties": false,
+		"$defs": {"tag": {"type": "string", "maxLength": 3}}
+	}`))
+	if err != nil {
+		t.Fatal("new schema:", err)
+	}
+
+	vs := s.Validate(FromString(`{"id": 1, "name": "abc", "kind": "a", "tags": ["x"], "price": 3}`))
+	if len(vs) != 0 {
+		t.Fatal("validate:", vs)
+	}
+
+	vs = s.Validate(FromString(`{"id": 0.5, "name": "A", "kind": "c", "tags": ["long", 1, "x"], "price": 1.5, "x": 1}`))
+	want := map[string]string{
+		"id":      "type",
+		"name":    "pattern",
+		"kind":    "enum",
+		"tags":    "maxItems",
+		"tags[0]": "maxLength",
+		"tags[1]": "type",
+		"price":   "oneOf",
+		"x":       "additionalProperties",
+	}
+	got := make(map[string]string)
+	for _, v := range vs {
+		got[v.Path] = v.Keyword
+	}
+	if len(got) != len(want) {
+		t.Fatal("violations:", vs)
+	}
+	for p, k := range want {
+		if got[p] != k {
+			t.Fatalf("violation of %v: %v, all: %v", p, got[p], vs)
+		}
+	}
+
+	vs = s.Validate(FromString(`{"id": 1}`))
+	if len(vs) != 1 || vs[0].Keyword != "required" {
+		t.Fatal("required:", vs)
+	}
+}
+
+type schemaNode struct {
+	Name     string       `json:"name" schema:"required,minLength=1,pattern=^[a-z]{1,3}$"`
+	Age      int          `json:"age,omitempty" schema:"minimum=0,maximum=150"`
+	Children []schemaNode `json:"children,omitempty"`
+	Parent   *schemaNode  `json:"parent,omitempty"`
+	Score    *float64     `json:"score,omitempty" schema:"minimum=0"`
+	Kind     *string      `json:"kind,omitempty" schema:"enum=a|b"`
+	Tags     []*int       `json:"tags,omitempty"`
+}
+
+// URL 与net/url.URL同名
+type URL struct {
+	Port int `json:"port"`
+}
+
+func TestSchemaFromStruct(t *testing.T) {
+	s, err := SchemaFromStruct(&schemaNode{})
+	if err != nil {
+		t.Fatal("schema from struct:", err)
+	}
+	vs := s.Validate(FromString(`{"name": "a", "children": [{"name": "b", "age": 3}]}`))
+	if len(vs) != 0 {
+		t.Fatal("validate:", vs)
+	}
+	vs = s.Validate(FromString(`{"name": "a", "children": [{"name": "abcd", "age": -1}, {}]}`))
+	if len(vs) != 3 {
+		t.Fatal("validate:", vs)
+	}
+
+	// 指针允许null，约束作用于指向的值
+	vs = s.Validate(FromString(`{"name": "a", "parent": null, "score": null, "kind": null, "tags": [1, null]}`))
+	if len(vs) != 0 {
+		t.Fatal("null pointer:", vs)
+	}
+	vs = s.Validate(FromString(`{"name": "a", "parent": {"name": "b", "parent": {}}, "score": -1, "kind": "c", "tags": ["x"]}`))
+	want := map[string]string{"parent": "anyOf", "score": "minimum", "kind": "enum", "tags[0]": "type"}
+	if len(vs) != len(want) {
+		t.Fatal("pointer:", vs)
+	}
+	for _, v := range vs {
+		if want[v.Path] != v.Keyword {
+			t.Fatal("pointer:", vs)
+		}
+	}
+	if vs = s.Validate(FromString(`{"name": null}`)); len(vs) != 1 || vs[0].Keyword != "type" {
+		t.Fatal("non-pointer null:", vs)
+	}
+
+	// 不同包的同名类型分别定义
+	s, err = SchemaFromStruct(struct {
+		A url.URL `json:"a"`
+		B URL     `json:"b"`
+	}{})
+	if err != nil {
+		t.Fatal("schema from struct:", err)
+	}
+	defs := s.root.Get("$defs")
+	if defs.Get(`["net/url.URL"].properties.Host.type`).Str() != "string" ||
+		defs.Get(`["github.com/eachain/common/gson.URL"].properties.port.type`).Str() != "integer" {
+		t.Fatal("defs:", defs)
+	}
+	if vs = s.Validate(FromString(`{"a": {"Host": "x"}, "b": {"port": 80}}`)); len(vs) != 0 {
+		t.Fatal("defs validate:", vs)
+	}
+	if vs = s.Validate(FromString(`{"a": {"Host": 1}, "b": {"port": "80"}}`)); len(vs) != 2 {
+		t.Fatal("defs validate:", vs)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	g := FromString(`{"a": {"n": 1, "f": 1.5, "s": "x", "b": true, "l": [1]}}`)
+	if i, err := g.Get("a.n").IntE(); err != nil || i != 1 {
+		t.Fatal("int:", i, err)
+	}
+	if _, err := g.Get("a.f").IntE(); err == nil {
+		t.Fatal("float as int should fail")
+	}
+	_, err := g.Get("a.s").IntE()
+	if tm, ok := err.(TypMismatchErr); !ok || tm.Want != TypNumber || tm.Typ != TypString || tm.Path != "a.s" {
+		t.Fatal("type mismatch:", err)
+	}
+	_, err = g.Get("a.x.y").StrE()
+	if kn, ok := err.(KeyNotFoundErr); !ok || kn.Key != "x" || kn.Path != "a" || kn.Expected != TypString {
+		t.Fatal("key not found:", err)
+	}
+	_, err = g.Get("a.l[3]").IntE()
+	if kn, ok := err.(KeyNotFoundErr); !ok || kn.Key != "3" || kn.Path != "a.l" || kn.Expected != TypNumber {
+		t.Fatal("index not found:", err)
+	}
+	_, err = g.Get("a").Any("x", "y").BoolE()
+	if kn, ok := err.(KeyNotFoundErr); !ok || kn.Key != "x|y" || kn.Expected != TypBool ||
+		kn.Error() != "gson: key 'x|y' not found, expected 'bool', path: a" {
+		t.Fatal("any not found:", err)
+	}
+
+	g.Get("a.x.y").Set("z")
+	if s, err := g.Get("a.x").ObjIdx("y").StrE(); err != nil || s != "z" {
+		t.Fatal("inserted:", s, err)
+	}
+
+	var acc Accumulator
+	acc.Int(g.Get("a.n"))
+	acc.Str(g.Get("a.s"))
+	acc.Bool(g.Get("a.s"))
+	acc.Float(g.Get("a.z"))
+	acc.List(g.Get("a.l"))
+	if errs := acc.Errors(); len(errs) != 2 {
+		t.Fatal("accumulated errors:", acc.Err())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustStr should panic")
+		}
+	}()
+	g.Get("a.n").MustStr()
+}
+
+func TestEncoder(t *testing.T) {
+	g := FromString(`{"b": [1, {"y": "<x>", "x": null}], "a": true, "c": {}}`)
+
+	var buf strings.Builder
+	enc := NewEncoder(&buf)
+	enc.Encode(FromString(`{"b": [1, {"y": "x"}], "a": true}`))
+	if s := buf.String(); s != `{"b": [1, {"y": "x"}], "a": true}`+"\n" {
+		t.Fatal("raw:", s)
+	}
+
+	// 需要转义HTML时，包含'<', '>', '&'的子树重新序列化，其它子树原样输出
+	buf.Reset()
+	enc.Encode(FromString(`{"b": [1, {"y": "<x>", "x": null}], "a": true, "c": { }}`))
+	if s := buf.String(); s != `{"b":[1,{"y":"\u003cx\u003e","x":null}],"a":true,"c":{ }}`+"\n" {
+		t.Fatal("escape html:", s)
+	}
+	buf.Reset()
+	enc.SetEscapeHTML(false)
+	enc.Encode(g)
+	if s := buf.String(); s != `{"b": [1, {"y": "<x>", "x": null}], "a": true, "c": {}}`+"\n" {
+		t.Fatal("raw without escaping html:", s)
+	}
+	buf.Reset()
+	enc.Encode(FromString("[\"a\u2028b\", 1]"))
+	if s := buf.String(); s != `["a\u2028b",1]`+"\n" {
+		t.Fatal("line separator:", s)
+	}
+	enc.SetEscapeHTML(true)
+
+	buf.Reset()
+	enc.SetCompact(true)
+	enc.Encode(g)
+	if s := buf.String(); s != `{"b":[1,{"y":"\u003cx\u003e","x":null}],"a":true,"c":{}}`+"\n" {
+		t.Fatal("compact:", s)
+	}
+
+	buf.Reset()
+	enc = NewEncoder(&buf)
+	enc.SetSortKeys(true)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(g)
+	want := `{
+  "a": true,
+  "b": [
+    1,
+    {
+      "x": null,
+      "y": "<x>"
+    }
+  ],
+  "c": {}
+}
+`
+	if s := buf.String(); s != want {
+		t.Fatal("indent:", s)
+	}
+
+	// 原始数据有误的子树返回扫描错误，不输出残缺的结果
+	for _, doc := range []string{`{"x": {"a": 1 "b": 2}}`, `{"y": [1 2]}`} {
+		if b, err := FromString(doc).MarshalIndent("", "  "); err == nil {
+			t.Errorf("indent %v: %s", doc, b)
+		}
+		if b, err := FromString(doc).MarshalCanonical(); err == nil {
+			t.Errorf("canonical %v: %s", doc, b)
+		}
+		buf.Reset()
+		enc := NewEncoder(&buf)
+		enc.SetSortKeys(true)
+		if err := enc.Encode(FromString(doc)); err == nil || buf.Len() != 0 {
+			t.Errorf("sort keys %v: %s", doc, buf.String())
+		}
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	// RFC 8785 3.2.2
+	g := FromString(`{
+		"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		"string": "€$\u000F\u000aA'B\"\\\\\"\/",
+		"literals": [null, true, false]
+	}`)
+	b, err := g.MarshalCanonical()
+	if err != nil {
+		t.Fatal("canonical:", err)
+	}
+	want := `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`
+	if string(b) != want {
+		t.Fatal("canonical:", string(b))
+	}
+
+	a, _ := FromString(`{"b":1,"a":[1.0]}`).MarshalCanonical()
+	c, _ := FromString(`{ "a" : [ 1 ], "b" : 1e0 }`).MarshalCanonical()
+	if string(a) != string(c) {
+		t.Fatal("canonical not stable:", string(a), string(c))
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for _, c := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{`{"a": 1, "b": [1, 2]}`, `{"b":[1,2.0],"a":1e0}`, true},
+		{`{"a": 1}`, `{"a": 1, "b": null}`, false},
+		{`[1, 2]`, `[2, 1]`, false},
+		{`12345678901234567890`, `1.2345678901234567890e19`, true},
+		{`12345678901234567890`, `12345678901234567891`, false},
+		{`"a"`, `"\u0061"`, true},
+		{`null`, `false`, false},
+		{`{"x": {"a": 1 "b": 2}}`, `{"x": {"a": 1 "c": 3}}`, false},
+		{`{"x": {"a": 1 "b": 2}}`, `{"x": {"a": 1 "b": 2}}`, false},
+		{`{"x": {"a": 1 "b": 2}}`, `{"x": {"a": 1}}`, false},
+		{`{"x": {} "y": 1}`, `{"x": {}}`, false},
+		{`[[1 2]]`, `[[1 3]]`, false},
+		{`[[1 2]]`, `[[1]]`, false},
+	} {
+		if Equal(FromString(c.a), FromString(c.b)) != c.equal {
+			t.Fatalf("equal(%v, %v) should be %v", c.a, c.b, c.equal)
+		}
+	}
+
+	g := FromString(`{"a": "{\"b\": 1}"}`)
+	g.Get("a.b").Set(2)
+	if !Equal(g, FromString(`{"a": "{\"b\":2}"}`)) {
+		t.Fatal("equal embedded:", marshalString(t, g))
+	}
+}
+
+func TestClone(t *testing.T) {
+	g := FromString(`{"a": {"b": [1, 2]}}`)
+	c := g.Get("a").Clone()
+	c.Get("b[0]").Set(9)
+	if i := g.Get("a.b[0]").Int(); i != 1 {
+		t.Fatal("clone modified origin:", i)
+	}
+	if p := c.Get("b[0]").Path(); p != "b[0]" {
+		t.Fatal("clone path:", p)
+	}
+	if !Equal(c, FromString(`{"b": [9, 2]}`)) {
+		t.Fatal("clone:", marshalString(t, c))
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dst := `{"a": 1, "o": {"x": 1}, "l": [{"id": 1, "v": 1}, {"id": 2}]}`
+	src := FromString(`{"b": 2, "o": {"y": 2}, "l": [{"id": 1, "v": 2}, {"id": 3}]}`)
+	for _, c := range []struct {
+		strategy MergeStrategy
+		want     string
+	}{
+		{MergeStrategy{}, `{"a":1,"b":2,"o":{"x":1,"y":2},"l":[{"id":1,"v":2},{"id":3}]}`},
+		{MergeStrategy{Array: ArrayAppend},
+			`{"a":1,"b":2,"o":{"x":1,"y":2},"l":[{"id":1,"v":1},{"id":2},{"id":1,"v":2},{"id":3}]}`},
+		{MergeStrategy{Array: ArrayMergeByKey, Key: "id"},
+			`{"a":1,"b":2,"o":{"x":1,"y":2},"l":[{"id":1,"v":2},{"id":2},{"id":3}]}`},
+	} {
+		g := FromString(dst)
+		if err := Merge(g, src, c.strategy); err != nil {
+			t.Fatal("merge:", err)
+		}
+		if !Equal(g, FromString(c.want)) {
+			t.Fatalf("merge %+v: %v", c.strategy, marshalString(t, g))
+		}
+	}
+}
+
+func TestFrozenConcurrent(t *testing.T) {
+	f := FromString(`{"a": {"b": [1, 2.5, "3", "{\"c\": true}"]}, "s": "x"}`).Freeze()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if f.Get("a.b[0]").Int() != 1 || f.Get("a.b[-3]").Float() != 2.5 ||
+					!f.Get("a.b[2]").IsInt() || !f.Get("a.b[3].c").Bool() ||
+					f.Get("s").Str() != "x" || len(f.Keys()) != 2 {
+					t.Error("frozen read")
+					return
+				}
+				if rs, _ := f.Query("$..b[*]"); len(rs) != 4 {
+					t.Error("frozen query")
+					return
+				}
+				f.Get("a.none").Str()
+				f.MarshalJSON()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if f.Get("a.none").Err() == nil {
+		t.Fatal("frozen missing key should carry error")
+	}
+}
+
+func TestSyncDocConcurrent(t *testing.T) {
+	d := NewSyncDoc(FromString(`{"n": 0, "l": []}`))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				d.Set(fmt.Sprintf("k%d", i), j)
+				d.Get(fmt.Sprintf("k%d", (i+1)%8)).Int()
+				d.Do(func(g *GSON) error {
+					return g.ObjIdx("n").Set(g.ObjIdx("n").Int() + 1)
+				})
+				d.Snapshot().Get("l").Len()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := d.Get("n").Int(); n != 8*50 {
+		t.Fatal("sync doc counter:", n)
+	}
+	if k := d.Get("k3").Int(); k != 49 {
+		t.Fatal("sync doc set:", k)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	g := FromString(`{"user": {"name": "a", "password": "x", "cards": [{"no": "1", "password": "y"}, 2]}, "raw": "{\"password\": \"z\", \"k\": 1}"}`)
+	var paths []string
+	g.Walk(func(path string, node *GSON) WalkAction {
+		paths = append(paths, path)
+		if strings.HasSuffix(path, "password") {
+			return WalkDelete
+		}
+		if path == "user.name" {
+			node.Set("***")
+		}
+		return WalkContinue
+	})
+	want := []string{"", "user", "user.name", "user.password", "user.cards", "user.cards[0]",
+		"user.cards[0].no", "user.cards[0].password", "user.cards[1]", "raw", "raw.password", "raw.k"}
+	if !stringsEqual(paths, want) {
+		t.Fatal("walk paths:", paths)
+	}
+	if s := marshalString(t, g); s != `{"user":{"name":"***","cards":[{"no":"1"},2]},"raw":"{\"k\":1}"}` {
+		t.Fatal("walk result:", s)
+	}
+
+	paths = nil
+	g.Walk(func(path string, node *GSON) WalkAction {
+		paths = append(paths, path)
+		switch path {
+		case "user":
+			return WalkSkip
+		case "raw":
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	if !stringsEqual(paths, []string{"", "user", "raw"}) {
+		t.Fatal("walk paths:", paths)
+	}
+
+	// 删除列表元素后，后续元素的下标随之变化
+	g = FromString(`[1, 2, 3]`)
+	paths = nil
+	g.Walk(func(path string, node *GSON) WalkAction {
+		paths = append(paths, path)
+		if node.Type() == TypNumber && node.Int() == 1 {
+			return WalkDelete
+		}
+		return WalkContinue
+	})
+	if !stringsEqual(paths, []string{"", "[0]", "[0]", "[1]"}) {
+		t.Fatal("walk paths:", paths)
+	}
+}
+
+func TestMapFilter(t *testing.T) {
+	g := FromString(`{"a": [1, 2, 3, 4], "b": 1}`)
+	err := g.Get("a").Map(func(i int, node *GSON) interface{} {
+		if i == 0 {
+			return node
+		}
+		return node.Int() * 10
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.Get("a").Filter(func(i int, node *GSON) bool {
+		return node.Int() != 20
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := marshalString(t, g); s != `{"a":[1,30,40],"b":1}` {
+		t.Fatal("map filter:", s)
+	}
+
+	err = g.Get("b").Filter(func(int, *GSON) bool { return true })
+	if _, ok := err.(TypOpErr); !ok {
+		t.Fatal("filter on number:", err)
+	}
+	err = g.Get("c").Map(func(int, *GSON) interface{} { return nil })
+	if _, ok := err.(KeyNotFoundErr); !ok {
+		t.Fatal("map on missing:", err)
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	// 两层编码
+	inner, _ := json.Marshal(`{"a":1,"b":[1,2]}`)
+	outer, _ := json.Marshal(string(inner))
+	g := FromString(`{"data": ` + string(outer) + `, "s": "\"hello\""}`)
+	if i := g.Get("data.a").Int(); i != 1 {
+		t.Fatal("double encoded:", i)
+	}
+	if g.Get("s").IsEmbedded() || !g.Get("data").IsEmbedded() {
+		t.Fatal("is embedded")
+	}
+	g.Get("data.a").Set(2)
+	if s := marshalString(t, g.Get("data")); s != string(mustMarshal(t, string(mustMarshal(t, `{"a":2,"b":[1,2]}`)))) {
+		t.Fatal("double encoded marshal:", s)
+	}
+
+	if err := g.Get("data").Unwrap(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Get("s").Unwrap(); err == nil {
+		t.Fatal("unwrap plain string")
+	}
+	if s := marshalString(t, g); s != `{"data":{"a":2,"b":[1,2]},"s":"\"hello\""}` {
+		t.Fatal("unwrap:", s)
+	}
+	if err := g.Get("data.b").Wrap(); err != nil {
+		t.Fatal(err)
+	}
+	if s := marshalString(t, g); s != `{"data":{"a":2,"b":"[1,2]"},"s":"\"hello\""}` {
+		t.Fatal("wrap:", s)
+	}
+	if i := g.Get("data.b[1]").Int(); i != 2 {
+		t.Fatal("wrapped list:", i)
+	}
+
+	g = FromString(`{"data": "{\"a\":1}"}`)
+	g.SetEmbeddedJSON(false)
+	d := g.Get("data")
+	if _, ok := d.Get("a").Err().(TypOpErr); !ok || d.IsEmbedded() || d.Str() != `{"a":1}` {
+		t.Fatal("embedded json disabled")
+	}
+	if c := g.Clone(); c.Get("data").IsEmbedded() {
+		t.Fatal("clone embedded json disabled")
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestYAML(t *testing.T) {
+	src := `# service config
+name: demo
+version: 1.2
+port: 0x1F90
+debug: false
+nothing: ~
+tags: [web, "api", 'x''y']
+owner: {name: eachain, id: 007}
+defaults: &defaults
+  timeout: 30
+  retries: 3
+servers:
+  - host: a.example.com
+    <<: *defaults
+    timeout: 10
+  - host: b.example.com
+    <<: *defaults
+script: |
+  echo hello
+  echo world
+folded: >-
+  one
+  two
+
+  three
+quoted: "tab\tand \u00e9"
+long: this is
+  a multi-line
+  plain scalar
+list:
+- 1
+- - nested
+  - list
+`
+	g := FromYAML([]byte(src))
+	if err := g.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !stringsEqual(g.Keys(), []string{"name", "version", "port", "debug", "nothing", "tags", "owner",
+		"defaults", "servers", "script", "folded", "quoted", "long", "list"}) {
+		t.Fatal("yaml keys:", g.Keys())
+	}
+	checks := map[string]string{
+		"name":     `"demo"`,
+		"version":  `1.2`,
+		"port":     `8080`,
+		"debug":    `false`,
+		"nothing":  `null`,
+		"tags":     `["web","api","x'y"]`,
+		"owner":    `{"name":"eachain","id":7}`,
+		"servers":  `[{"host":"a.example.com","timeout":10,"retries":3},{"host":"b.example.com","timeout":30,"retries":3}]`,
+		"script":   `"echo hello\necho world\n"`,
+		"folded":   `"one two\nthree"`,
+		"quoted":   `"tab\tand é"`,
+		"long":     `"this is a multi-line plain scalar"`,
+		"list":     `[1,["nested","list"]]`,
+		"owner.id": `7`,
+	}
+	for k, want := range checks {
+		if s := marshalString(t, g.Get(k)); s != want {
+			t.Fatalf("yaml %v: %v, want %v", k, s, want)
+		}
+	}
+
+	g.Get("owner.name").Set("someone")
+	g.Get("tags[0]").Set("true")
+	out, err := g.ToYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := FromYAML(out)
+	if back.Err() != nil || !Equal(g, back) {
+		t.Fatalf("yaml round trip: %v\n%s", back.Err(), out)
+	}
+	if !strings.Contains(string(out), "script: |\n  echo hello\n") || !strings.Contains(string(out), `- "true"`) {
+		t.Fatalf("yaml output:\n%s", out)
+	}
+
+	for _, bad := range []string{"a: 1\n  b: 2\n", "a: [1, 2\n", "a: 1\na: 2\n", "a: *x\n", "a: .inf\n", "a: 1\n---\nb: 2\n"} {
+		if _, ok := FromYAML([]byte(bad)).Err().(FormatErr); !ok {
+			t.Fatalf("yaml %q: %v", bad, FromYAML([]byte(bad)).Err())
+		}
+	}
+	err = FromYAML([]byte("a: 1\nb: [1,\n  2,, 3]\n")).Err()
+	if fe, ok := err.(FormatErr); !ok || fe.Line != 3 {
+		t.Fatal("yaml error position:", err)
+	}
+}
+
+func TestTOML(t *testing.T) {
+	src := `# config
+title = "TOML \"example\""
+count = 1_000
+hex = 0xff
+ratio = 6.5e-1
+enabled = true
+dob = 1979-05-27 07:32:00Z
+site."google.com" = true
+multi = """
+first \
+  second"""
+raw = '''C:\path'''
+
+[owner]
+name = 'Tom'
+tags = [ "a", # comment
+  "b", ]
+point = { x = 1, y.z = 2 }
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+name = "Nail"
+[products.size]
+len = 3
+`
+	g := FromTOML([]byte(src))
+	if err := g.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !stringsEqual(g.Keys(), []string{"title", "count", "hex", "ratio", "enabled", "dob", "site", "multi", "raw", "owner", "products"}) {
+		t.Fatal("toml keys:", g.Keys())
+	}
+	want := `{"title":"TOML \"example\"","count":1000,"hex":255,"ratio":6.5e-1,"enabled":true,` +
+		`"dob":"1979-05-27 07:32:00Z","site":{"google.com":true},"multi":"first second","raw":"C:\\path",` +
+		`"owner":{"name":"Tom","tags":["a","b"],"point":{"x":1,"y":{"z":2}}},` +
+		`"products":[{"name":"Hammer"},{"name":"Nail","size":{"len":3}}]}`
+	if s := marshalString(t, g); s != want {
+		t.Fatal("toml:", s)
+	}
+
+	g.Get("owner.name").Set("Jerry")
+	out, err := g.ToTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := FromTOML(out)
+	if back.Err() != nil || !Equal(g, back) {
+		t.Fatalf("toml round trip: %v\n%s", back.Err(), out)
+	}
+	if !strings.Contains(string(out), "[[products]]\nname = \"Nail\"\n\n[products.size]\nlen = 3\n") {
+		t.Fatalf("toml output:\n%s", out)
+	}
+	if _, err = FromString(`{"a":null}`).ToTOML(); err == nil {
+		t.Fatal("toml null")
+	}
+
+	// 日期时间不加引号输出，往返后不变
+	dates := "odt = 1979-05-27T07:32:00.999-07:00\nldt = 1979-05-27 07:32:00\nld = 1979-05-27\nlt = 00:32:00.5\n"
+	out, err = FromTOML([]byte(dates)).ToTOML()
+	if err != nil || string(out) != dates {
+		t.Fatalf("toml datetime: %v\n%s", err, out)
+	}
+	out, _ = FromString(`{"a":"1979-13-27","b":"25:00:00","c":"1979-05-27x"}`).ToTOML()
+	if string(out) != "a = \"1979-13-27\"\nb = \"25:00:00\"\nc = \"1979-05-27x\"\n" {
+		t.Fatalf("toml non-datetime strings:\n%s", out)
+	}
+
+	for _, bad := range []string{"a = 1\na = 2", "[a]\n[a]", "a = {b = 1}\n[a]", "a = inf", "a = 01", "a = \"x", "a.b = 1\n[a]"} {
+		if _, ok := FromTOML([]byte(bad)).Err().(FormatErr); !ok {
+			t.Fatalf("toml %q: %v", bad, FromTOML([]byte(bad)).Err())
+		}
+	}
+	err = FromTOML([]byte("a = 1\nb = [1,\n  2 3]\n")).Err()
+	if fe, ok := err.(FormatErr); !ok || fe.Line != 3 || fe.Col != 5 {
+		t.Fatal("toml error position:", err)
+	}
+}
+
+func TestJSON5(t *testing.T) {
+	src := `// JSON5
+{
+  unquoted: 'and you can quote me on that',
+  singleQuotes: 'I can use "double quotes" here',
+  lineBreaks: "Look, Mom! \
+No \\n's!",
+  hexadecimal: 0xdecaf,
+  leadingDecimalPoint: .8675309, andTrailing: 8675309.,
+  positiveSign: +1,
+  trailingComma: 'in objects', andIn: ['arrays',],
+  /* block */ "backwardsCompatible": "with JSON",
+}`
+	g := FromJSON5([]byte(src))
+	if err := g.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"unquoted":"and you can quote me on that","singleQuotes":"I can use \"double quotes\" here",` +
+		`"lineBreaks":"Look, Mom! No \\n's!","hexadecimal":912559,"leadingDecimalPoint":0.8675309,` +
+		`"andTrailing":8675309,"positiveSign":1,"trailingComma":"in objects","andIn":["arrays"],` +
+		`"backwardsCompatible":"with JSON"}`
+	if s := marshalString(t, g); s != want {
+		t.Fatal("json5:", s)
+	}
+
+	out, err := g.ToJSON5()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), "{\n  unquoted: \"and you can quote me on that\",\n") {
+		t.Fatalf("json5 output:\n%s", out)
+	}
+	if back := FromJSON5(out); back.Err() != nil || !Equal(g, back) {
+		t.Fatalf("json5 round trip: %v\n%s", back.Err(), out)
+	}
+
+	for _, bad := range []string{"{a: Infinity}", "{a: 01}", "{a: 1", "[1,,]", "{'a' 1}", "/* x"} {
+		if _, ok := FromJSON5([]byte(bad)).Err().(FormatErr); !ok {
+			t.Fatalf("json5 %q: %v", bad, FromJSON5([]byte(bad)).Err())
+		}
+	}
+	err = FromJSON5([]byte("{\n  a: 1,\n  b: x,\n}")).Err()
+	if fe, ok := err.(FormatErr); !ok || fe.Line != 3 || fe.Col != 6 {
+		t.Fatal("json5 error position:", err)
+	}
+
+	// 原始数据有误的子树返回扫描错误，不输出残缺的结果
+	for _, doc := range []string{`{"x": {"a": 1 "b": 2}}`, `{"y": [1 2]}`, `{"z": {"y": [1 2]}}`, `{"w": [{"a": 1 "b": 2}]}`} {
+		for name, to := range map[string]func(*GSON) ([]byte, error){
+			"json5": (*GSON).ToJSON5, "yaml": (*GSON).ToYAML, "toml": (*GSON).ToTOML,
+		} {
+			if b, err := to(FromString(doc)); err == nil {
+				t.Errorf("%v %v:\n%s", name, doc, b)
+			}
+		}
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	r := NewReader(strings.NewReader("{\"a\":1}\n\n{\"a\":\n[2]\n"))
+	var lines []int
+	for {
+		g, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if le, ok := err.(LineErr); ok {
+			lines = append(lines, -le.Line)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, r.Line())
+		_ = g
+	}
+	if fmt.Sprint(lines) != "[1 -3 4]" {
+		t.Fatal("ndjson lines:", lines)
+	}
+
+	r = NewStreamReader(strings.NewReader("{\"a\":\n 1}[1,2]\"s\"\n\n  3 true\n{\"b\"}"))
+	var out []string
+	for {
+		g, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if le, ok := err.(LineErr); !ok || le.Line != 5 {
+				t.Fatal("stream error:", err)
+			}
+			if _, err2 := r.Next(); err2 != err {
+				t.Fatal("stream error not sticky:", err2)
+			}
+			break
+		}
+		b := &bytes.Buffer{}
+		json.Compact(b, []byte(marshalString(t, g)))
+		out = append(out, fmt.Sprintf("%v:%s", r.Line(), b))
+	}
+	if s := strings.Join(out, " "); s != `1:{"a":1} 2:[1,2] 2:"s" 4:3 4:true` {
+		t.Fatal("stream values:", s)
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	if err := w.Write(FromString("{\n  \"a\": [1, 2]\n}")); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if buf.String() != "{\"a\":[1,2]}\n" {
+		t.Fatalf("ndjson writer: %q", buf.String())
+	}
+
+	in := &strings.Builder{}
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(in, "{\"i\":%v}\n", i)
+	}
+	buf.Reset()
+	err := Process(NewReader(strings.NewReader(in.String())), NewWriter(buf), 4, func(g *GSON) (*GSON, error) {
+		i := g.Get("i").Int()
+		if i%2 == 1 {
+			return nil, nil
+		}
+		g.Get("j").Set(i * i)
+		return g, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines2 := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines2) != 50 || lines2[0] != `{"i":0,"j":0}` || lines2[49] != `{"i":98,"j":9604}` {
+		t.Fatalf("process output: %v %q", len(lines2), buf.String())
+	}
+
+	errStop := errors.New("stop")
+	err = Process(NewReader(strings.NewReader(in.String())), NewWriter(io.Discard), 4, func(g *GSON) (*GSON, error) {
+		if g.Get("i").Int() == 10 {
+			return nil, errStop
+		}
+		return g, nil
+	})
+	if le, ok := err.(LineErr); !ok || le.Line != 11 || !errors.Is(err, errStop) {
+		t.Fatal("process error:", err)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	g := FromString(`{"a":1,"b":{"c":[1,2]},"d":"x"}`)
+	g.Track()
+	var got []string
+	cancel := g.Subscribe("b", func(c Change) {
+		got = append(got, fmt.Sprintf("%v %v %s %s", c.Op, c.Path, c.Old, c.New))
+	})
+
+	snap := g.Snapshot()
+	g.Get("a").Set(2)
+	g.Get("b.c[1]").Set(3)
+	g.Get("b.e.f").Set(true)
+	g.Get("b.c").Index(2).Set(4)
+	g.Get("a").Remove()
+	if s := marshalString(t, g); s != `{"b":{"c":[1,3,4],"e":{"f":true}},"d":"x"}` {
+		t.Fatal("track edit:", s)
+	}
+
+	var cs []string
+	for _, c := range g.Changes()[snap:] {
+		cs = append(cs, fmt.Sprintf("%v %v %s %s", c.Op, c.Path, c.Old, c.New))
+	}
+	want := []string{
+		"set a 1 2",
+		"set b.c[1] 2 3",
+		`insert b.e null {"f":true}`,
+		"insert b.c[2] null 4",
+		"remove a 2 null",
+	}
+	if strings.Join(cs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("changes:\n%v", strings.Join(cs, "\n"))
+	}
+	if strings.Join(got, "\n") != strings.Join(want[1:4], "\n") {
+		t.Fatalf("subscribed:\n%v", strings.Join(got, "\n"))
+	}
+
+	cancel()
+	if err := g.Rollback(snap); err != nil {
+		t.Fatal(err)
+	}
+	if s := marshalString(t, g); s != `{"a":1,"b":{"c":[1,2]},"d":"x"}` {
+		t.Fatal("rollback:", s)
+	}
+	if len(g.Changes()) != 0 || len(got) != 3 {
+		t.Fatal("rollback log:", g.Changes(), got)
+	}
+
+	g.Get("d").Remove()
+	snap = g.Snapshot()
+	g.Get("b").Set([]int{1})
+	g.Get("b").Index(0).Remove()
+	g.Rollback(snap)
+	if s := marshalString(t, g); s != `{"a":1,"b":{"c":[1,2]}}` || len(g.Changes()) != 1 {
+		t.Fatal("rollback to snapshot:", s)
+	}
+	if err := g.Rollback(5); err == nil {
+		t.Fatal("rollback to invalid snapshot")
+	}
+}
+
+func TestMsgpack(t *testing.T) {
+	for hx, want := range map[string]string{
+		"9301a161c0":             `[1,"a",null]`,
+		"d080":                   `-128`,
+		"d1ff00":                 `-256`,
+		"cfffffffffffffffff":     `18446744073709551615`,
+		"ca3fc00000":             `1.5`,
+		"c403010203":             `"AQID"`,
+		"82a16101a162c3":         `{"a":1,"b":true}`,
+		"de0001a16190":           `{"a":[]}`,
+		"cb3fb999999999999a":     `0.1`,
+		"d903616263":             `"abc"`,
+		"81a16183a17a01a16102a1": ``,
+	} {
+		b, _ := hex.DecodeString(hx)
+		g := FromMsgpack(b)
+		if want == "" {
+			if _, ok := g.Err().(BinaryErr); !ok {
+				t.Fatalf("msgpack %v: %v", hx, g.Err())
+			}
+			continue
+		}
+		if s := marshalString(t, g); s != want {
+			t.Fatalf("msgpack %v: %v, want %v", hx, s, want)
+		}
+	}
+
+	for hx, off := range map[string]int{"c1": 0, "d401ff": 0, "810101": 1, "9201": 1, "ddffffffff": 5, "0101": 1, "cb7ff8000000000000": 9} {
+		b, _ := hex.DecodeString(hx)
+		if be, ok := FromMsgpack(b).Err().(BinaryErr); !ok || be.Offset != off {
+			t.Fatalf("msgpack %v error: %v", hx, FromMsgpack(b).Err())
+		}
+	}
+
+	b, err := FromString(`{"a":[1,-1,300,"x"]}`).ToMsgpack()
+	if err != nil || hex.EncodeToString(b) != "81a1619401ffcd012ca178" {
+		t.Fatalf("msgpack encode: %x %v", b, err)
+	}
+	doc := `{"z":1,"a":[-1,-33,1.5,0.1,"sé",true,false,null,{}],"u":18446744073709551615,"i":-9223372036854775808}`
+	b, err = FromString(doc).ToMsgpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := marshalString(t, FromMsgpack(b)); s != `{"z":1,"a":[-1,-33,1.5,0.1,"sé",true,false,null,{}],"u":18446744073709551615,"i":-9223372036854775808}` {
+		t.Fatal("msgpack round trip:", s)
+	}
+	for _, doc := range []string{`{"x": {"a": 1 "b": 2}}`, `{"y": [1 2]}`} {
+		if b, err := FromString(doc).ToMsgpack(); err == nil {
+			t.Errorf("msgpack encode malformed %v: %x", doc, b)
+		}
+	}
+}
+
+func TestCBOR(t *testing.T) {
+	// RFC 8949 附录A
+	for hx, want := range map[string]string{
+		"17":                     `23`,
+		"1903e8":                 `1000`,
+		"1bffffffffffffffff":     `18446744073709551615`,
+		"c249010000000000000000": `18446744073709551616`,
+		"3bffffffffffffffff":     `-18446744073709551616`,
+		"c349010000000000000000": `-18446744073709551617`,
+		"3903e7":                 `-1000`,
+		"f98000":                 `-0`,
+		"f93e00":                 `1.5`,
+		"f97bff":                 `65504`,
+		"fa47c35000":             `100000`,
+		"f90001":                 `5.960464477539063e-08`,
+		"fb3ff199999999999a":     `1.1`,
+		"f7":                     `null`,
+		"c48221196ab3":           `27315e-2`,
+		"4401020304":             `"AQIDBA=="`,
+		"c074323031332d30332d32315432303a30343a30305a": `"2013-03-21T20:04:00Z"`,
+		"9f018202039f0405ffff":                         `[1,[2,3],[4,5]]`,
+		"bf61610161629f0203ffff":                       `{"a":1,"b":[2,3]}`,
+		"7f657374726561646d696e67ff":                   `"streaming"`,
+		"a56161614161626142616361436164614461656145":   `{"a":"A","b":"B","c":"C","d":"D","e":"E"}`,
+	} {
+		b, _ := hex.DecodeString(hx)
+		if s := marshalString(t, FromCBOR(b)); s != want {
+			t.Fatalf("cbor %v: %v, want %v", hx, s, want)
+		}
+	}
+
+	for hx, off := range map[string]int{"ff": 0, "1c": 0, "a10101": 1, "8201": 1, "f97e00": 3, "f820": 0, "7f6161ff00": 4, "7f01ff": 1, "c241": 2} {
+		b, _ := hex.DecodeString(hx)
+		if be, ok := FromCBOR(b).Err().(BinaryErr); !ok || be.Offset != off {
+			t.Fatalf("cbor %v error: %v", hx, FromCBOR(b).Err())
+		}
+	}
+
+	b, err := FromString(`{"a":[1,-1,1000,1.5,1.1]}`).ToCBOR()
+	if err != nil || hex.EncodeToString(b) != "a161618501201903e8fa3fc00000fb3ff199999999999a" {
+		t.Fatalf("cbor encode: %x %v", b, err)
+	}
+	doc := `{"z":1,"a":[-1,1.5,0.1,"sé",true,false,null,{}],"big":18446744073709551616,"neg":-18446744073709551617,"u":18446744073709551615}`
+	b, err = FromString(doc).ToCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := marshalString(t, FromCBOR(b)); s != doc {
+		t.Fatal("cbor round trip:", s)
+	}
+	for _, doc := range []string{`{"x": {"a": 1 "b": 2}}`, `{"y": [1 2]}`} {
+		if b, err := FromString(doc).ToCBOR(); err == nil {
+			t.Errorf("cbor encode malformed %v: %x", doc, b)
+		}
+	}
+}
+
+// binRoundTrip 检查b作为json和作为二进制输入时，编码后再解码与json的结果一致
+func binRoundTrip(t *testing.T, b []byte, enc func(*GSON) ([]byte, error), dec func([]byte) *GSON) {
+	check := func(g *GSON) {
+		want, err := g.MarshalCanonical()
+		if err != nil {
+			return // 超出float64范围的数字等
+		}
+		p, err := enc(g)
+		if err != nil {
+			t.Fatalf("encode %s: %v", want, err)
+		}
+		back := dec(p)
+		if back.Err() != nil {
+			t.Fatalf("decode %s: %v", want, back.Err())
+		}
+		got, err := back.MarshalCanonical()
+		if err != nil || string(got) != string(want) {
+			t.Fatalf("round trip %s: %s %v", want, got, err)
+		}
+	}
+
+	if json.Valid(b) {
+		check(FromBytes(b))
+	}
+	if g := dec(b); g.Err() == nil {
+		j, err := g.MarshalJSON()
+		if err != nil || !json.Valid(j) {
+			t.Fatalf("decoded %x to invalid json %s: %v", b, j, err)
+		}
+		check(g)
+	}
+}
+
+var binSeeds = []string{
+	`{"a":[1,-1,1.5,"x",true,null],"b":{"c":{}}}`,
+	`[18446744073709551616,-9223372036854775809,1e3,0.1,1e-7]`,
+	`"sé😀"`,
+	`{"a":1,"a":2}`,
+	"\x93\x01\xa1a\xc0",
+	"\x9f\x01\x82\x02\x03\x9f\x04\x05\xff\xff",
+	"\xc4\x82\x21\x19\x6a\xb3",
+}
+
+func FuzzMsgpack(f *testing.F) {
+	for _, s := range binSeeds {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		binRoundTrip(t, b, (*GSON).ToMsgpack, FromMsgpack)
+	})
+}
+
+func FuzzCBOR(f *testing.F) {
+	for _, s := range binSeeds {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		binRoundTrip(t, b, (*GSON).ToCBOR, FromCBOR)
+	})
+}
@@ -1,9 +1,9 @@
 package gson
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"unsafe"
@@ -28,19 +28,26 @@ type GSON struct {
 	v value
 	u bool  // updated
 	e error // if any error
+
+	tr *tracker // 变更跟踪
+
+	noEmbed bool // 不解析内嵌json字符串，对子节点同样生效
 }
 
 type value struct {
-	s string
-	i int64
-	f float64
-	o *object
-	l *list
-	b bool
-	u func() // on update
+	s     string
+	i     int64
+	f     float64
+	o     *object
+	l     *list
+	b     bool
+	u     func()   // on update
+	miss  *missing // 不存在的节点
+	embed int      // 内嵌json字符串的编码层数
 
-	set   bool
-	isInt bool
+	set    bool // s或b已缓存
+	numSet bool // i, f, isInt已缓存
+	isInt  bool
 }
 
 func FromBytes(b []byte) *GSON {
@@ -66,11 +73,15 @@ func (to TypOpErr) Error() string {
 }
 
 type KeyNotFoundErr struct {
-	Key  string
-	Path string
+	Key      string
+	Path     string
+	Expected Type // 严格取值时期望的类型，其它情况为空
 }
 
 func (kn KeyNotFoundErr) Error() string {
+	if kn.Expected != "" {
+		return fmt.Sprintf("gson: key '%v' not found, expected '%v', path: %v", kn.Key, kn.Expected, kn.Path)
+	}
 	return fmt.Sprintf("gson: key '%v' not found, path: %v", kn.Key, kn.Path)
 }
 
@@ -85,36 +96,40 @@ func (g *GSON) Type() Type {
 	return g.t
 }
 
+// guess 只看第一个非空白字符，不解码
 func guess(p []byte) Type {
-	if len(p) == 0 {
+	i := skipSpace(p, 0)
+	if i >= len(p) {
 		return TypUnknown
 	}
-	t, err := json.NewDecoder(bytes.NewReader(p)).Token()
-	if err != nil {
-		return TypUnknown
-	}
-	switch x := t.(type) {
-	case json.Delim:
-		switch x {
-		case '{', '}':
-			return TypObject
-		case '[', ']':
-			return TypList
-		}
-	case string:
+	switch c := p[i]; {
+	case c == '{':
+		return TypObject
+	case c == '[':
+		return TypList
+	case c == '"':
 		return TypString
-	case float64:
-		return TypNumber
-	case bool:
-		return TypBool
-	case nil:
-		return TypNull
+	case c == '-' || ('0' <= c && c <= '9'):
+		if e, err := scanNumber(p, i); err == nil && skipSpace(p, e) == len(p) {
+			return TypNumber
+		}
+	case c == 't', c == 'f':
+		if _, e := scanValue(p, i); e == nil {
+			return TypBool
+		}
+	case c == 'n':
+		if _, e := scanValue(p, i); e == nil {
+			return TypNull
+		}
 	}
 	return TypUnknown
 }
 
 func (g *GSON) Str() string {
 	if g.Type() == TypString {
+		if g.u { // 内嵌json已修改
+			g.MarshalJSON()
+		}
 		if !g.v.set {
 			e := json.Unmarshal(g.b, &g.v.s)
 			if e == nil {
@@ -133,6 +148,7 @@ func (g *GSON) Str() string {
 
 var isInt = regexp.MustCompile("^0$|^[1-9][0-9]*$|^-[1-9][0-9]*$")
 
+// IsInt 数值为整数且在int64范围内，1e3、1.0同样视为整数
 func (g *GSON) IsInt() bool {
 	switch g.Type() {
 	default:
@@ -140,29 +156,36 @@ func (g *GSON) IsInt() bool {
 	case TypNumber, TypString:
 	}
 
-	if g.v.set {
-		return g.v.isInt
+	if !g.v.numSet {
+		g.parseNum(g.numStr())
+		g.v.numSet = true
 	}
+	return g.v.isInt
+}
 
-	s := g.Str()
-
-	if !isInt.MatchString(s) {
-		f, e := strconv.ParseFloat(s, 64)
+func (g *GSON) parseNum(s string) {
+	if isInt.MatchString(s) {
+		i, e := strconv.ParseInt(s, 10, 64)
 		if e == nil {
-			g.v.f = f
-			g.v.set = true
+			g.v.i = i
+			g.v.isInt = true
+			return
 		}
-		return false
 	}
 
-	i, e := strconv.ParseInt(s, 10, 64)
-	if e != nil {
-		return false
+	f, e := strconv.ParseFloat(s, 64)
+	if e != nil && f == 0 {
+		return
+	}
+	g.v.f = f
+	if f != math.Trunc(f) || f < math.MinInt64 || f > math.MaxInt64 || !isNumber.MatchString(s) {
+		return
+	}
+	// 浮点数可能有误差，按字面量精确判断
+	if r := parseRat(s); r != nil && r.IsInt() && r.Num().IsInt64() {
+		g.v.i = r.Num().Int64()
+		g.v.isInt = true
 	}
-	g.v.i = i
-	g.v.isInt = true
-	g.v.set = true
-	return true
 }
 
 func (g *GSON) Int() int64 {
@@ -180,21 +203,17 @@ func (g *GSON) Float() float64 {
 }
 
 // object keys
+// 对象按需扫描，只有访问到的key之前的部分会被索引
 func (g *GSON) objInit() {
 	if g.v.o == nil {
-		var b []byte
-		if g.Type() == TypObject {
-			b = g.b
-		} else if g.Type() == TypString {
-			b = []byte(g.Str())
-		} else {
-			return
-		}
-
-		o := object{p: g}
-		e := json.Unmarshal(b, &o)
-		if e == nil {
-			g.v.o = &o
+		switch g.Type() {
+		case TypObject:
+			g.v.o = newObject(g, g.b)
+		case TypString:
+			if b, n := g.embedded(); first(b) == '{' {
+				g.v.o = newObject(g, b)
+				g.v.embed = n
+			}
 		}
 	}
 }
@@ -207,10 +226,21 @@ func (g *GSON) Keys() []string {
 	return g.v.o.Keys()
 }
 
+// keys 同Keys，但原始数据有误时返回扫描错误，而不是已扫描的部分，
+// 序列化、比较等需要完整数据的地方须使用keys
+func (g *GSON) keys() ([]string, error) {
+	g.objInit()
+	if g.v.o == nil {
+		return nil, nil
+	}
+	ks := g.v.o.Keys()
+	return ks, g.v.o.err
+}
+
 // get object value of the key
 func (g *GSON) ObjIdx(key string) *GSON {
 	if g.e != nil {
-		return g
+		return &GSON{e: g.e}
 	}
 
 	g.objInit()
@@ -219,27 +249,26 @@ func (g *GSON) ObjIdx(key string) *GSON {
 			return &GSON{e: TypOpErr{Op: "objidx", Typ: g.Type(), Path: g.Path()}}
 		}
 
-		g.v.o = &object{p: g}
+		g.v.o = &object{p: g, done: true}
 		g.t = TypObject
 	}
-	return g.v.o.Index(key)
+	c := g.v.o.Index(key)
+	if g.e != nil { // 扫描时发现数据有误
+		return &GSON{e: g.e}
+	}
+	return c
 }
 
 func (g *GSON) listInit() {
 	if g.v.l == nil {
-		var b []byte
-		if g.Type() == TypList {
-			b = g.b
-		} else if g.Type() == TypString {
-			b = []byte(g.Str())
-		} else {
-			return
-		}
-
-		l := list{p: g}
-		e := json.Unmarshal(b, &l)
-		if e == nil {
-			g.v.l = &l
+		switch g.Type() {
+		case TypList:
+			g.v.l = newList(g, g.b)
+		case TypString:
+			if b, n := g.embedded(); first(b) == '[' {
+				g.v.l = newList(g, b)
+				g.v.embed = n
+			}
 		}
 	}
 }
@@ -256,7 +285,7 @@ func (g *GSON) Len() int {
 // list index
 func (g *GSON) Index(i int) *GSON {
 	if g.e != nil {
-		return g
+		return &GSON{e: g.e}
 	}
 
 	g.listInit()
@@ -264,10 +293,14 @@ func (g *GSON) Index(i int) *GSON {
 		if g.Type() != TypUnknown || len(g.b) > 0 {
 			return &GSON{e: TypOpErr{Op: "index", Typ: g.Type(), Path: g.Path()}}
 		}
-		g.v.l = &list{p: g}
+		g.v.l = &list{p: g, done: true}
 		g.t = TypList
 	}
-	return g.v.l.Index(i)
+	c := g.v.l.Index(i)
+	if g.e != nil {
+		return &GSON{e: g.e}
+	}
+	return c
 }
 
 // bool value
@@ -312,12 +345,17 @@ func (g *GSON) update(set bool) {
 	if set && g.v.u != nil {
 		g.v.u()
 		g.v.u = nil
+		g.v.miss = nil
 	}
 	g.u = true
 	g.p.update(set)
 }
 
 func (g *GSON) reset(b []byte) {
+	g.change(func() { g.replace(b) })
+}
+
+func (g *GSON) replace(b []byte) {
 	if len(b) > 0 {
 		g.b = make([]byte, len(b))
 		copy(g.b, b)
@@ -340,6 +378,9 @@ func (g *GSON) reset(b []byte) {
 }
 
 func (g *GSON) Set(v interface{}) error {
+	if g.e != nil && g.p == nil && g.b == nil { // 查找失败返回的错误节点
+		return g.e
+	}
 	b, e := json.Marshal(v)
 	if e != nil {
 		return e
@@ -353,6 +394,15 @@ func (g *GSON) Remove() {
 		return
 	}
 
+	root := g.tracked()
+	if root == g || root != nil && root.tr.busy {
+		root = nil
+	}
+	var c Change
+	if root != nil {
+		c = Change{Op: OpRemove, Path: relPath(root, g), Old: rawOf(g), idx: g.p.keyIndex(g)}
+	}
+
 	if g.p.v.o != nil {
 		g.p.v.o.Remove(g)
 	}
@@ -361,6 +411,9 @@ func (g *GSON) Remove() {
 	}
 	g.p.update(false)
 	g.p = nil
+	if root != nil {
+		root.tr.record(c)
+	}
 }
 
 // usage: g.Index(100).Exists()
@@ -369,6 +422,9 @@ func (g *GSON) Exists() bool {
 }
 
 func (g *GSON) MarshalJSON() ([]byte, error) {
+	if g.e != nil {
+		return nil, g.e
+	}
 	if !g.u {
 		b := make([]byte, len(g.b))
 		copy(b, g.b)
@@ -400,22 +456,22 @@ func (g *GSON) MarshalJSON() ([]byte, error) {
 		return g.b, e
 
 	case TypString:
+		var b []byte
 		if g.v.o != nil {
-			b, e := g.v.o.MarshalJSON()
-			if e != nil {
-				return nil, e
-			}
-			g.b, e = json.Marshal(string(b))
-			return g.b, e
+			b, e = g.v.o.MarshalJSON()
+		} else if g.v.l != nil {
+			b, e = g.v.l.MarshalJSON()
+		} else {
+			return g.b, nil
 		}
-		if g.v.l != nil {
-			b, e := g.v.l.MarshalJSON()
-			if e != nil {
-				return nil, e
-			}
-			g.b, e = json.Marshal(string(b))
-			return g.b, e
+		for i := 0; i < g.v.embed && e == nil; i++ {
+			b, e = json.Marshal(string(b))
+		}
+		if e != nil {
+			return nil, e
 		}
+		g.b = b
+		g.v.set = false // Str()缓存失效
 		return g.b, nil
 	}
 }
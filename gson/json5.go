@@ -0,0 +1,139 @@
+package gson
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/eachain/common/internal/json5"
+)
+
+// FromJSON5 解析JSON5(https://json5.org)：注释、单引号字符串、不加引号的key、
+// 尾随逗号、十六进制、+号及省略整数或小数部分的数字。
+// Infinity和NaN无法用json表示，返回错误。与jcon共用同一个转换器。
+func FromJSON5(b []byte) *GSON {
+	p, err := json5.Convert(b)
+	if err != nil {
+		if se, ok := err.(*json5.SyntaxError); ok {
+			err = FormatErr{Format: "json5", Line: se.Line, Col: se.Col, Msg: se.Msg}
+		}
+		return &GSON{e: err}
+	}
+	buf := &bytes.Buffer{} // 转换结果保留了原文的换行和空白
+	if err = json.Compact(buf, p); err != nil {
+		return &GSON{e: err}
+	}
+	return FromBytes(buf.Bytes())
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r) ||
+		unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r) || unicode.Is(unicode.Pc, r) ||
+		r == '\u200C' || r == '\u200D'
+}
+
+// - - - - - - - - - - emitter - - - - - - - - - -
+
+// ToJSON5 以两个空格缩进输出JSON5，合法标识符的key不加引号。注释无法保留。
+func (g *GSON) ToJSON5() ([]byte, error) {
+	if g.e != nil {
+		return nil, g.e
+	}
+	buf := &bytes.Buffer{}
+	if err := writeJSON5(buf, g, 0); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func writeJSON5(buf *bytes.Buffer, g *GSON, depth int) error {
+	indent := func(d int) {
+		buf.WriteByte('\n')
+		for i := 0; i < d; i++ {
+			buf.WriteString("  ")
+		}
+	}
+	switch g.Type() {
+	case TypObject:
+		ks, err := g.keys()
+		if err != nil {
+			return err
+		}
+		if len(ks) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteByte('{')
+		for _, k := range ks {
+			indent(depth + 1)
+			if isJSON5Ident(k) {
+				buf.WriteString(k)
+			} else {
+				writeCanonicalString(buf, k)
+			}
+			buf.WriteString(": ")
+			if err := writeJSON5(buf, g.ObjIdx(k), depth+1); err != nil {
+				return err
+			}
+			buf.WriteByte(',')
+		}
+		indent(depth)
+		buf.WriteByte('}')
+		return nil
+
+	case TypList:
+		els, err := g.items()
+		if err != nil {
+			return err
+		}
+		if len(els) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteByte('[')
+		for _, c := range els {
+			indent(depth + 1)
+			if err := writeJSON5(buf, c, depth+1); err != nil {
+				return err
+			}
+			buf.WriteByte(',')
+		}
+		indent(depth)
+		buf.WriteByte(']')
+		return nil
+
+	case TypString:
+		writeCanonicalString(buf, g.Str())
+		return nil
+
+	case TypNumber, TypBool, TypNull:
+		buf.WriteString(strings.TrimSpace(g.Str()))
+		return nil
+	}
+	return TypOpErr{Op: "json5", Typ: g.Type(), Path: g.Path()}
+}
+
+// json5Reserved ES5保留字作为key时加引号，兼容旧的解析器
+var json5Reserved = func() []string {
+	ws := strings.Fields(`break case catch class const continue debugger default delete do
+		else enum export extends false finally for function if import in instanceof new null
+		return super switch this throw true try typeof var void while with`)
+	sort.Strings(ws)
+	return ws
+}()
+
+func isJSON5Ident(k string) bool {
+	if k == "" {
+		return false
+	}
+	for i, r := range k {
+		if !isIdentRune(r) || (i == 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	i := sort.SearchStrings(json5Reserved, k)
+	return i >= len(json5Reserved) || json5Reserved[i] != k
+}
@@ -10,22 +10,74 @@ type L []interface{}
 type list struct {
 	els []*GSON
 	p   *GSON
+
+	// 按需扫描的原始数据
+	raw  []byte
+	pos  int
+	n    int // 已扫描的元素数
+	done bool
+	err  error
 }
 
-func (l *list) UnmarshalJSON(b []byte) error {
-	var raws []json.RawMessage
-	err := json.Unmarshal(b, &raws)
+// newList 在raw上建立惰性索引，raw须以'['开头
+func newList(p *GSON, raw []byte) *list {
+	i := skipSpace(raw, 0)
+	if i >= len(raw) || raw[i] != '[' {
+		return nil
+	}
+	return &list{p: p, raw: raw, pos: i + 1}
+}
+
+// next 扫描下一个元素，没有更多时返回false
+func (l *list) next() bool {
+	if l.done {
+		return false
+	}
+	b := l.raw
+	i := skipSpace(b, l.pos)
+	if i < len(b) && b[i] == ']' {
+		l.done = true
+		l.raw = nil
+		return false
+	}
+	if l.n > 0 {
+		if i >= len(b) || b[i] != ',' {
+			return l.fail(syntaxErr(b, i, "after array element"))
+		}
+		i = skipSpace(b, i+1)
+	}
+	end, err := scanValue(b, i)
 	if err != nil {
-		return err
+		return l.fail(err)
+	}
+	l.pos = end
+	l.n++
+	l.els = append(l.els, &GSON{b: b[i:end:end], p: l.p})
+	return true
+}
+
+// fail 记录扫描错误，所属节点成为错误节点
+func (l *list) fail(err error) bool {
+	l.done = true
+	l.raw = nil
+	l.err = err
+	if l.p != nil {
+		l.p.e = err
 	}
-	l.els = make([]*GSON, len(raws))
-	for i, raw := range raws {
-		l.els[i] = &GSON{b: raw, p: l.p}
+	return false
+}
+
+// complete 扫描剩余全部元素
+func (l *list) complete() {
+	for l.next() {
 	}
-	return nil
 }
 
 func (l *list) MarshalJSON() ([]byte, error) {
+	l.complete()
+	if l.err != nil {
+		return nil, l.err
+	}
 	var err error
 	raws := make([]json.RawMessage, len(l.els))
 	for i, g := range l.els {
@@ -38,19 +90,32 @@ func (l *list) MarshalJSON() ([]byte, error) {
 }
 
 func (l *list) Len() int {
+	l.complete()
+	if l.err != nil {
+		return 0
+	}
 	return len(l.els)
 }
 
 func (l *list) Index(i int) *GSON {
+	for i >= len(l.els) && l.next() {
+	}
 	if 0 <= i && i < len(l.els) {
 		return l.els[i]
 	}
+	return l.stub(i)
+}
+
+// stub 返回插入到i处的占位节点，赋值时才插入
+func (l *list) stub(i int) *GSON {
 	g := &GSON{}
+	g.v.miss = &missing{p: l.p, idx: i, isIdx: true}
 	g.v.u = func() { l.Insert(i, g) }
 	return g
 }
 
 func (l *list) Insert(i int, g *GSON) {
+	l.complete()
 	g.p = l.p
 	gs := make([]*GSON, len(l.els)+1)
 	if i <= 0 {
@@ -68,6 +133,7 @@ func (l *list) Insert(i int, g *GSON) {
 }
 
 func (l *list) Remove(c *GSON) {
+	l.complete()
 	for i, g := range l.els {
 		if g == c {
 			l.els = append(l.els[:i], l.els[i+1:]...)
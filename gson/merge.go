@@ -0,0 +1,172 @@
+package gson
+
+import (
+	"bytes"
+	"math/big"
+)
+
+// Equal 比较a, b是否语义相等：忽略空白和key顺序，数字按数值比较(1 == 1.0 == 1e0)
+// 任一方是错误节点或原始数据有误时返回false
+func Equal(a, b *GSON) bool {
+	if a.e != nil || b.e != nil {
+		return false
+	}
+	at, bt := a.Type(), b.Type()
+	if at != bt {
+		return false
+	}
+	switch at {
+	case TypObject:
+		ak, err1 := a.keys()
+		bk, err2 := b.keys()
+		if err1 != nil || err2 != nil || len(ak) != len(bk) {
+			return false
+		}
+		if a.v.o == nil || b.v.o == nil {
+			return a.v.o == b.v.o
+		}
+		for _, k := range ak {
+			bc, ok := b.v.o.lookup(k)
+			if !ok || !Equal(a.v.o.mp[k], bc) {
+				return false
+			}
+		}
+		return true
+
+	case TypList:
+		ae, err1 := a.items()
+		be, err2 := b.items()
+		if err1 != nil || err2 != nil || len(ae) != len(be) {
+			return false
+		}
+		for i := range ae {
+			if !Equal(ae[i], be[i]) {
+				return false
+			}
+		}
+		return true
+
+	case TypString:
+		return a.Str() == b.Str()
+
+	case TypNumber:
+		x, ok1 := new(big.Rat).SetString(string(bytes.TrimSpace(a.b)))
+		y, ok2 := new(big.Rat).SetString(string(bytes.TrimSpace(b.b)))
+		return ok1 && ok2 && x.Cmp(y) == 0
+
+	case TypBool:
+		return a.Bool() == b.Bool()
+
+	case TypNull:
+		return true
+	}
+	return bytes.Equal(bytes.TrimSpace(a.b), bytes.TrimSpace(b.b))
+}
+
+// Clone 返回独立的副本，修改副本不影响原文档
+func (g *GSON) Clone() *GSON {
+	if g.e != nil {
+		return &GSON{e: g.e}
+	}
+	b, err := g.MarshalJSON()
+	if err != nil {
+		return &GSON{e: err}
+	}
+	c := &GSON{b: b, noEmbed: g.embedOff()}
+	if len(b) == 0 {
+		c.b = nil
+	}
+	return c
+}
+
+type ArrayMerge int
+
+const (
+	ArrayReplace    ArrayMerge = iota // src列表替换dst列表
+	ArrayAppend                       // src元素追加到dst末尾
+	ArrayMergeByKey                   // 按Key字段匹配元素并递归合并，未匹配的追加
+)
+
+type MergeStrategy struct {
+	Array ArrayMerge
+	Key   string // ArrayMergeByKey时用于匹配元素的字段
+}
+
+// Merge 将src深度合并到dst：对象逐key递归合并，列表按strategy处理，
+// 其它值(包括null)直接覆盖。src不会被修改。
+func Merge(dst, src *GSON, strategy MergeStrategy) error {
+	if dst.e != nil {
+		return dst.e
+	}
+	if src.e != nil {
+		return src.e
+	}
+
+	switch src.Type() {
+	case TypObject:
+		if dst.Type() != TypObject {
+			break
+		}
+		dst.objInit()
+		for _, k := range src.Keys() {
+			if err := Merge(dst.v.o.Index(k), src.ObjIdx(k), strategy); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case TypList:
+		if dst.Type() != TypList || strategy.Array == ArrayReplace {
+			break
+		}
+		for _, c := range src.elems() {
+			if strategy.Array == ArrayMergeByKey && c.Type() == TypObject {
+				if d := findByKey(dst, strategy.Key, c); d != nil {
+					if err := Merge(d, c, strategy); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			b, err := c.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			dst.Index(dst.Len()).reset(b)
+		}
+		return nil
+	}
+
+	b, err := src.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	dst.reset(b)
+	return nil
+}
+
+// findByKey 在列表l中查找key字段与c相等的元素
+func findByKey(l *GSON, key string, c *GSON) *GSON {
+	src, ok := c.lookupKey(key)
+	if !ok {
+		return nil
+	}
+	for _, d := range l.elems() {
+		if d.Type() != TypObject {
+			continue
+		}
+		if v, ok := d.lookupKey(key); ok && Equal(v, src) {
+			return d
+		}
+	}
+	return nil
+}
+
+// lookupKey 查找已存在的key，不创建占位节点
+func (g *GSON) lookupKey(k string) (*GSON, bool) {
+	g.objInit()
+	if g.v.o == nil {
+		return nil, false
+	}
+	return g.v.o.lookup(k)
+}
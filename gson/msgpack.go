@@ -0,0 +1,305 @@
+package gson
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+/*
+FromMsgpack 解码MessagePack，map的key须为字符串，保持原始顺序。
+bin转为base64字符串；ext(包括timestamp)没有对应的json类型，返回错误。
+*/
+func FromMsgpack(b []byte) *GSON {
+	r := &binReader{format: "msgpack", b: b}
+	n, err := r.msgpack()
+	if err == nil && r.i < len(b) {
+		err = r.fail("unexpected data after top-level value")
+	}
+	if err != nil {
+		return &GSON{e: err}
+	}
+	return n.gson()
+}
+
+func (r *binReader) msgpack() (*node, error) {
+	p, err := r.read(1)
+	if err != nil {
+		return nil, err
+	}
+	switch c := p[0]; {
+	case c <= 0x7f:
+		return rawNode(TypNumber, strconv.Itoa(int(c))), nil
+	case c >= 0xe0:
+		return rawNode(TypNumber, strconv.Itoa(int(int8(c)))), nil
+	case c&0xf0 == 0x80:
+		return r.msgpackMap(uint64(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return r.msgpackArray(uint64(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return r.msgpackStr(uint64(c & 0x1f))
+	}
+
+	switch c := p[0]; c {
+	case 0xc0:
+		return rawNode(TypNull, "null"), nil
+	case 0xc2:
+		return rawNode(TypBool, "false"), nil
+	case 0xc3:
+		return rawNode(TypBool, "true"), nil
+	case 0xc4, 0xc5, 0xc6: // bin 8/16/32
+		n, err := r.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		p, err := r.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return bytesNode(p), nil
+	case 0xca:
+		u, err := r.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return r.floatNode(float64(math.Float32frombits(uint32(u))))
+	case 0xcb:
+		u, err := r.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return r.floatNode(math.Float64frombits(u))
+	case 0xcc, 0xcd, 0xce, 0xcf: // uint 8/16/32/64
+		u, err := r.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return rawNode(TypNumber, strconv.FormatUint(u, 10)), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3: // int 8/16/32/64
+		size := 1 << (c - 0xd0)
+		u, err := r.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		i := int64(u<<(64-8*size)) >> (64 - 8*size) // 符号扩展
+		return rawNode(TypNumber, strconv.FormatInt(i, 10)), nil
+	case 0xd9, 0xda, 0xdb: // str 8/16/32
+		n, err := r.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return r.msgpackStr(n)
+	case 0xdc, 0xdd: // array 16/32
+		n, err := r.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.msgpackArray(n)
+	case 0xde, 0xdf: // map 16/32
+		n, err := r.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.msgpackMap(n)
+	}
+	r.i--
+	return nil, r.fail("unsupported type 0x%02x", p[0])
+}
+
+func (r *binReader) msgpackStr(n uint64) (*node, error) {
+	p, err := r.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return strNode(string(p)), nil
+}
+
+func (r *binReader) msgpackArray(n uint64) (*node, error) {
+	cnt, err := r.count(n)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.enter(); err != nil {
+		return nil, err
+	}
+	a := newNode(TypList)
+	a.els = make([]*node, 0, cnt)
+	for i := 0; i < cnt; i++ {
+		v, err := r.msgpack()
+		if err != nil {
+			return nil, err
+		}
+		a.els = append(a.els, v)
+	}
+	r.depth--
+	return a, nil
+}
+
+func (r *binReader) msgpackMap(n uint64) (*node, error) {
+	cnt, err := r.count(n)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.enter(); err != nil {
+		return nil, err
+	}
+	m := newNode(TypObject)
+	for i := 0; i < cnt; i++ {
+		at := r.i
+		k, err := r.msgpack()
+		if err != nil {
+			return nil, err
+		}
+		if k.t != TypString {
+			r.i = at
+			return nil, r.fail("map key must be a string")
+		}
+		v, err := r.msgpack()
+		if err != nil {
+			return nil, err
+		}
+		m.set(k.s, v)
+	}
+	r.depth--
+	return m, nil
+}
+
+// ToMsgpack 编码为MessagePack，整数使用最短的int/uint格式，超出int64和uint64的数字及小数使用float64
+func (g *GSON) ToMsgpack() ([]byte, error) {
+	if g.e != nil {
+		return nil, g.e
+	}
+	buf := &bytes.Buffer{}
+	if err := writeMsgpack(buf, g); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeMsgpack(buf *bytes.Buffer, g *GSON) error {
+	switch g.Type() {
+	case TypObject:
+		ks, err := g.keys()
+		if err != nil {
+			return err
+		}
+		writeMsgpackHeader(buf, len(ks), 0x80, 0xde)
+		for _, k := range ks {
+			writeMsgpackStr(buf, k)
+			if err := writeMsgpack(buf, g.ObjIdx(k)); err != nil {
+				return err
+			}
+		}
+	case TypList:
+		els, err := g.items()
+		if err != nil {
+			return err
+		}
+		writeMsgpackHeader(buf, len(els), 0x90, 0xdc)
+		for _, c := range els {
+			if err := writeMsgpack(buf, c); err != nil {
+				return err
+			}
+		}
+	case TypString:
+		writeMsgpackStr(buf, g.Str())
+	case TypNumber:
+		n, err := parseBinNumber(g.numStr())
+		if err != nil {
+			return err
+		}
+		switch {
+		case n.isU:
+			buf.WriteByte(0xcf)
+			writeBigEndian(buf, n.u, 8)
+		case n.isInt:
+			writeMsgpackInt(buf, n.i)
+		case math.IsInf(n.f, 0):
+			return TypOpErr{Op: "msgpack", Typ: TypNumber, Path: g.Path()}
+		default:
+			buf.WriteByte(0xcb)
+			writeBigEndian(buf, math.Float64bits(n.f), 8)
+		}
+	case TypBool:
+		if g.Bool() {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case TypNull:
+		buf.WriteByte(0xc0)
+	default:
+		return TypOpErr{Op: "msgpack", Typ: g.Type(), Path: g.Path()}
+	}
+	return nil
+}
+
+// writeMsgpackHeader 输出map或array的头部，fix为fixmap/fixarray，ext16为16位长度格式，其后为32位
+func writeMsgpackHeader(buf *bytes.Buffer, n int, fix, ext16 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(ext16)
+		writeBigEndian(buf, uint64(n), 2)
+	default:
+		buf.WriteByte(ext16 + 1)
+		writeBigEndian(buf, uint64(n), 4)
+	}
+}
+
+func writeMsgpackStr(buf *bytes.Buffer, s string) {
+	switch n := len(s); {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		writeBigEndian(buf, uint64(n), 2)
+	default:
+		buf.WriteByte(0xdb)
+		writeBigEndian(buf, uint64(n), 4)
+	}
+	buf.WriteString(s)
+}
+
+func writeMsgpackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 0x7f, i < 0 && i >= -32:
+		buf.WriteByte(byte(i))
+	case i >= 0 && i <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(i))
+	case i >= 0 && i <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		writeBigEndian(buf, uint64(i), 2)
+	case i >= 0 && i <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		writeBigEndian(buf, uint64(i), 4)
+	case i >= 0:
+		buf.WriteByte(0xcf)
+		writeBigEndian(buf, uint64(i), 8)
+	case i >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(i))
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		writeBigEndian(buf, uint64(i), 2)
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		writeBigEndian(buf, uint64(i), 4)
+	default:
+		buf.WriteByte(0xd3)
+		writeBigEndian(buf, uint64(i), 8)
+	}
+}
+
+// writeBigEndian 输出u的低n字节
+func writeBigEndian(buf *bytes.Buffer, u uint64, n int) {
+	var p [8]byte
+	binary.BigEndian.PutUint64(p[:], u)
+	buf.Write(p[8-n:])
+}
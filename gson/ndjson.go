@@ -0,0 +1,270 @@
+package gson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// JSON Lines / NDJSON：每行一个json值。
+
+type LineErr struct {
+	Line int
+	Err  error
+}
+
+func (le LineErr) Error() string {
+	return fmt.Sprintf("gson: line %v: %v", le.Line, le.Err)
+}
+
+func (le LineErr) Unwrap() error {
+	return le.Err
+}
+
+// Reader 逐个读取json值
+type Reader struct {
+	br     *bufio.Reader
+	stream bool
+
+	buf  []byte // stream模式下未处理的数据
+	line int    // 下一个值之前已读取的行数
+	cur  int    // 上一个值的起始行号
+	eof  bool
+	rerr error // 读取错误
+	err  error // stream模式下的错误不可恢复
+}
+
+// NewReader 读取NDJSON，每行一个值，空行被忽略。
+// 某一行格式错误时Next返回LineErr，之后可以继续读取下一行。
+func NewReader(r io.Reader) *Reader {
+	return &Reader{br: bufio.NewReaderSize(r, 64<<10)}
+}
+
+// NewStreamReader 读取连续拼接的json值，值之间以任意空白分隔，一个值可以跨多行。
+// 格式错误时无法确定下一个值的起始位置，之后的Next都返回同一错误。
+func NewStreamReader(r io.Reader) *Reader {
+	return &Reader{br: bufio.NewReaderSize(r, 64<<10), stream: true}
+}
+
+// Line 返回上一个值的起始行号，从1开始
+func (r *Reader) Line() int {
+	return r.cur
+}
+
+// Next 返回下一个值，没有更多值时返回io.EOF
+func (r *Reader) Next() (*GSON, error) {
+	if r.stream {
+		return r.nextValue()
+	}
+	for {
+		b, err := r.readLine()
+		if len(b) == 0 && err != nil {
+			return nil, err
+		}
+		r.line++
+		b = bytes.TrimSpace(b)
+		if len(b) == 0 {
+			continue
+		}
+		r.cur = r.line
+		if !json.Valid(b) {
+			return nil, LineErr{Line: r.line, Err: validErr(b)}
+		}
+		return FromBytes(b), nil
+	}
+}
+
+// readLine 读取一行，返回的数据在下次读取前有效
+func (r *Reader) readLine() ([]byte, error) {
+	b, err := r.br.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		return b, err
+	}
+	line := append([]byte(nil), b...)
+	for err == bufio.ErrBufferFull {
+		b, err = r.br.ReadSlice('\n')
+		line = append(line, b...)
+	}
+	return line, err
+}
+
+// validErr 返回b不是单个合法json值的原因
+func validErr(b []byte) error {
+	var v json.RawMessage
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return errors.New("invalid json")
+}
+
+func (r *Reader) nextValue() (*GSON, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for {
+		i := 0
+		for i < len(r.buf) && isSpace(r.buf[i]) {
+			if r.buf[i] == '\n' {
+				r.line++
+			}
+			i++
+		}
+		r.buf = r.buf[i:]
+		if len(r.buf) == 0 {
+			if r.rerr != nil {
+				return nil, r.rerr
+			}
+			if r.eof {
+				return nil, io.EOF
+			}
+			r.fill(0)
+			continue
+		}
+
+		end, err := scanValue(r.buf, 0)
+		if err == errUnexpectedEnd && !r.eof {
+			r.fill(len(r.buf)) // 至少读取同样多的数据，避免反复扫描
+			continue
+		}
+		r.cur = r.line + 1
+		if err == nil && !json.Valid(r.buf[:end]) {
+			err = validErr(r.buf[:end])
+		}
+		if err != nil {
+			r.err = LineErr{Line: r.cur, Err: err}
+			return nil, r.err
+		}
+		v := append([]byte(nil), r.buf[:end]...)
+		r.line += bytes.Count(v, []byte{'\n'})
+		r.buf = r.buf[end:]
+		return FromBytes(v), nil
+	}
+}
+
+// fill 按行读取至少n字节，只在行尾截断以免拆开数字等字面量
+func (r *Reader) fill(n int) {
+	got := 0
+	for !r.eof && (got == 0 || got < n) {
+		b, err := r.readLine()
+		r.buf = append(r.buf, b...)
+		got += len(b)
+		if err != nil {
+			r.eof = true
+			if err != io.EOF {
+				r.rerr = err
+			}
+		}
+	}
+}
+
+// Writer 按NDJSON格式输出，每个值一行。
+// 输出经过缓冲，结束时须调用Flush，同csv.Writer。
+type Writer struct {
+	bw *bufio.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{bw: bufio.NewWriterSize(w, 64<<10)}
+}
+
+// Write 紧凑输出g并换行，原始数据中的换行会被去除
+func (w *Writer) Write(g *GSON) error {
+	b, err := g.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	b = bytes.TrimSpace(b)
+	if bytes.IndexByte(b, '\n') >= 0 || bytes.IndexByte(b, '\r') >= 0 {
+		buf := &bytes.Buffer{}
+		if err = json.Compact(buf, b); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	}
+	if _, err = w.bw.Write(b); err != nil {
+		return err
+	}
+	return w.bw.WriteByte('\n')
+}
+
+func (w *Writer) Flush() error {
+	return w.bw.Flush()
+}
+
+// Process 用最多n个goroutine并发执行f处理r中的每个值，结果按输入顺序写入w。
+// f返回nil时不输出该值。读取、f或写入出错时停止并返回第一个错误，
+// 读取和f的错误为带行号的LineErr。
+func Process(r *Reader, w *Writer, n int, f func(g *GSON) (*GSON, error)) error {
+	if n < 1 {
+		n = 1
+	}
+	type result struct {
+		g   *GSON
+		err error
+	}
+	pending := make(chan chan result, n) // 按输入顺序排队
+	sem := make(chan struct{}, n)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	go func() {
+		defer close(pending)
+		for {
+			g, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			ch := make(chan result, 1)
+			select {
+			case pending <- ch:
+			case <-done:
+				return
+			}
+			if err != nil {
+				ch <- result{err: err}
+				return
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-done:
+				ch <- result{}
+				return
+			}
+			line := r.Line()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				v, err := f(g)
+				if err != nil {
+					err = LineErr{Line: line, Err: err}
+				}
+				ch <- result{g: v, err: err}
+			}()
+		}
+	}()
+
+	var err error
+	for ch := range pending {
+		if err != nil {
+			continue // 等待已启动的任务结束
+		}
+		res := <-ch
+		err = res.err
+		if err == nil && res.g != nil {
+			err = w.Write(res.g)
+		}
+		if err != nil {
+			close(done)
+		}
+	}
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
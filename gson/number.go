@@ -0,0 +1,98 @@
+package gson
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 精确数值：超出int64/float64范围或精度的数字按原始字面量读写。
+
+var isNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// maxExp 限制指数大小，避免1e1000000000这类数字展开时占用大量内存
+const maxExp = 10000
+
+// numStr 数值的原始字面量，数值字符串返回字符串内容
+func (g *GSON) numStr() string {
+	if g.Type() == TypNumber {
+		return strings.TrimSpace(g.Str())
+	}
+	return g.Str()
+}
+
+// parseRat 解析JSON数字字面量，非法或指数过大时返回nil
+func parseRat(s string) *big.Rat {
+	if !isNumber.MatchString(s) {
+		return nil
+	}
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil || exp > maxExp || exp < -maxExp {
+			return nil
+		}
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil
+	}
+	return r
+}
+
+// Number 返回数值的原始字面量，不是数值时返回""
+func (g *GSON) Number() json.Number {
+	switch g.Type() {
+	case TypNumber, TypString:
+		if s := g.numStr(); isNumber.MatchString(s) {
+			return json.Number(s)
+		}
+	}
+	return ""
+}
+
+// Uint 返回uint64值，用于超出int64范围的ID等；负数、小数或超出范围时返回0
+func (g *GSON) Uint() uint64 {
+	s := g.Number()
+	if s == "" {
+		return 0
+	}
+	if u, err := strconv.ParseUint(string(s), 10, 64); err == nil {
+		return u
+	}
+	if n := g.BigInt(); n != nil && n.IsUint64() {
+		return n.Uint64()
+	}
+	return 0
+}
+
+// BigInt 返回任意精度整数，1e3等整数值同样支持；不是整数时返回nil
+func (g *GSON) BigInt() *big.Int {
+	r := g.Decimal()
+	if r == nil || !r.IsInt() {
+		return nil
+	}
+	return new(big.Int).Set(r.Num())
+}
+
+// Decimal 返回数值的精确值，0.1不会变为0.1000000000000000055511151231257827；
+// 不是数值或指数过大时返回nil
+func (g *GSON) Decimal() *big.Rat {
+	s := g.Number()
+	if s == "" {
+		return nil
+	}
+	return parseRat(string(s))
+}
+
+// SetNumber 按原样写入数字字面量，不经过float64转换。
+// Set(json.Number)和Set(*big.Int)同样保留字面量。
+func (g *GSON) SetNumber(n json.Number) error {
+	if !isNumber.MatchString(string(n)) {
+		return fmt.Errorf("gson: invalid number literal %q", string(n))
+	}
+	g.reset([]byte(n))
+	return nil
+}
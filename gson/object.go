@@ -3,7 +3,7 @@ package gson
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
+	"strings"
 )
 
 type M map[string]interface{}
@@ -12,48 +12,105 @@ type object struct {
 	ks []string
 	mp map[string]*GSON
 	p  *GSON
+
+	// 按需扫描的原始数据，重复key以最后一个为准，与encoding/json一致
+	raw  []byte
+	pos  int
+	n    int // 已扫描的键值对数
+	done bool
+	err  error
 }
 
-func (o *object) UnmarshalJSON(p []byte) error {
-	dec := json.NewDecoder(bytes.NewReader(p))
-	t, e := dec.Token()
-	if e != nil {
-		return e
+// newObject 在raw上建立惰性索引，raw须以'{'开头
+func newObject(p *GSON, raw []byte) *object {
+	i := skipSpace(raw, 0)
+	if i >= len(raw) || raw[i] != '{' {
+		return nil
 	}
-	if d, ok := t.(json.Delim); !ok || d != '{' {
-		return errors.New("gson: object expect '{'")
+	return &object{p: p, raw: raw, pos: i + 1}
+}
+
+// next 扫描下一个键值对，没有更多时返回false
+func (o *object) next() bool {
+	if o.done {
+		return false
 	}
-	for dec.More() {
-		t, e := dec.Token()
-		if e != nil {
-			return e
-		}
-		k, ok := t.(string)
-		if !ok {
-			return errors.New("gson: object expect key type of string")
-		}
-		var b json.RawMessage
-		e = dec.Decode(&b)
-		if e != nil {
-			return e
+	b := o.raw
+	i := skipSpace(b, o.pos)
+	if i < len(b) && b[i] == '}' {
+		o.done = true
+		o.raw = nil
+		return false
+	}
+	if o.n > 0 {
+		if i >= len(b) || b[i] != ',' {
+			return o.fail(syntaxErr(b, i, "after object key:value pair"))
 		}
+		i = skipSpace(b, i+1)
+	}
+	if i >= len(b) || b[i] != '"' {
+		return o.fail(syntaxErr(b, i, "looking for beginning of object key string"))
+	}
+	end, err := scanString(b, i)
+	if err != nil {
+		return o.fail(err)
+	}
+	k, err := unquote(b[i:end])
+	if err != nil {
+		return o.fail(err)
+	}
+	i = skipSpace(b, end)
+	if i >= len(b) || b[i] != ':' {
+		return o.fail(syntaxErr(b, i, "after object key"))
+	}
+	i = skipSpace(b, i+1)
+	end, err = scanValue(b, i)
+	if err != nil {
+		return o.fail(err)
+	}
+	o.pos = end
+	o.n++
+
+	if o.mp == nil {
+		o.mp = make(map[string]*GSON)
+	}
+	if _, exists := o.mp[k]; !exists {
 		o.ks = append(o.ks, k)
-		if o.mp == nil {
-			o.mp = make(map[string]*GSON)
-		}
-		o.mp[k] = &GSON{b: b, p: o.p}
 	}
-	t, e = dec.Token() // ignore '}'
-	if e != nil {
-		return e
+	o.mp[k] = &GSON{b: b[i:end:end], p: o.p}
+	return true
+}
+
+// fail 记录扫描错误，所属节点成为错误节点
+func (o *object) fail(err error) bool {
+	o.done = true
+	o.raw = nil
+	o.err = err
+	if o.p != nil {
+		o.p.e = err
 	}
-	if d, ok := t.(json.Delim); !ok || d != '}' {
-		return errors.New("gson: object expect '}'")
+	return false
+}
+
+// complete 扫描剩余全部键值对
+func (o *object) complete() {
+	for o.next() {
 	}
-	return nil
+}
+
+// lookup 查找k。重复key以最后一个为准，所以需要扫描完本层，
+// 但各个值只匹配括号，不展开。
+func (o *object) lookup(k string) (*GSON, bool) {
+	o.complete()
+	g, ok := o.mp[k]
+	return g, ok
 }
 
 func (o *object) MarshalJSON() ([]byte, error) {
+	o.complete()
+	if o.err != nil {
+		return nil, o.err
+	}
 	buf := &bytes.Buffer{}
 	enc := json.NewEncoder(buf)
 	buf.WriteByte('{')
@@ -82,16 +139,22 @@ func (o *object) MarshalJSON() ([]byte, error) {
 }
 
 func (o *object) Keys() []string {
+	o.complete()
+	if o.err != nil {
+		return nil
+	}
 	ks := make([]string, len(o.ks))
 	copy(ks, o.ks)
 	return ks
 }
 
 func (o *object) Index(k string) *GSON {
-	g := o.mp[k]
+	g, _ := o.lookup(k)
 	if g == nil {
 		g = &GSON{}
+		g.v.miss = &missing{p: o.p, key: k}
 		g.v.u = func() {
+			o.complete()
 			if o.mp == nil {
 				o.mp = make(map[string]*GSON)
 			}
@@ -105,7 +168,28 @@ func (o *object) Index(k string) *GSON {
 	return g
 }
 
+// insert 在第i个位置插入k，i越界时追加到末尾
+func (o *object) insert(i int, k string, g *GSON) {
+	o.complete()
+	if o.mp == nil {
+		o.mp = make(map[string]*GSON)
+	}
+	g.p = o.p
+	if _, exists := o.mp[k]; exists {
+		o.mp[k] = g
+		return
+	}
+	o.mp[k] = g
+	if i < 0 || i >= len(o.ks) {
+		o.ks = append(o.ks, k)
+		return
+	}
+	o.ks = append(o.ks[:i+1], o.ks[i:]...)
+	o.ks[i] = k
+}
+
 func (o *object) Remove(c *GSON) {
+	o.complete()
 	var key string
 	var found bool
 	for k, g := range o.mp {
@@ -132,16 +216,24 @@ func (o *object) Remove(c *GSON) {
 func (o *object) routeOf(c *GSON) string {
 	for k, g := range o.mp {
 		if g == c {
-			r := o.p.p.routeOf(o.p)
-			if r == "" {
-				return k
-			}
-			return r + "." + k
+			return joinKey(o.p.p.routeOf(o.p), k)
 		}
 	}
 	return ""
 }
 
+// joinKey 拼接路径，与Get语法兼容
+func joinKey(r, k string) string {
+	if strings.ContainsAny(k, ".[]") {
+		q, _ := json.Marshal(k)
+		return r + "[" + string(q) + "]"
+	}
+	if r == "" {
+		return k
+	}
+	return r + "." + k
+}
+
 func (o *object) orphan() {
 	for _, g := range o.mp {
 		g.p = nil
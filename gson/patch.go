@@ -0,0 +1,325 @@
+package gson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RFC 6902 JSON Patch 和 RFC 7386 JSON Merge Patch。
+
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type Patch []Operation
+
+type PatchErr struct {
+	Op   string
+	Path string
+	Msg  string
+}
+
+func (pe PatchErr) Error() string {
+	return fmt.Sprintf("gson: patch %v '%v': %v", pe.Op, pe.Path, pe.Msg)
+}
+
+// DecodePatch 解析JSON Patch文档
+func DecodePatch(b []byte) (Patch, error) {
+	var p Patch
+	err := json.Unmarshal(b, &p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ApplyPatch 依次执行p中的操作。
+// 任一操作失败时，g恢复为执行前的内容并返回错误。
+func (g *GSON) ApplyPatch(p Patch) error {
+	if g.e != nil {
+		return g.e
+	}
+	orig, err := g.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	for _, op := range p {
+		if err = g.applyOp(op); err != nil {
+			g.reset(orig)
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GSON) applyOp(op Operation) error {
+	fail := func(format string, a ...interface{}) error {
+		return PatchErr{Op: op.Op, Path: op.Path, Msg: fmt.Sprintf(format, a...)}
+	}
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return fail("missing value")
+		}
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return fail("%v", err)
+		}
+		src, err := g.locate(from)
+		if err != nil {
+			return fail("from '%v': %v", op.From, err)
+		}
+		if op.Op == "move" {
+			if op.From == op.Path {
+				return nil
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return fail("cannot move into its own child")
+			}
+		}
+		op.Value, err = src.MarshalJSON()
+		if err != nil {
+			return fail("%v", err)
+		}
+		if op.Op == "move" {
+			src.Remove()
+		}
+		op.Op = "add"
+	case "remove":
+	default:
+		return fail("unknown operation")
+	}
+
+	switch op.Op {
+	case "add":
+		if err = g.add(path, op.Value); err != nil {
+			return fail("%v", err)
+		}
+		return nil
+	}
+
+	dst, err := g.locate(path)
+	if err != nil {
+		return fail("%v", err)
+	}
+	switch op.Op {
+	case "remove":
+		if dst == g {
+			return fail("cannot remove root")
+		}
+		dst.Remove()
+	case "replace":
+		dst.reset(op.Value)
+	case "test":
+		if !Equal(dst, FromBytes(op.Value)) {
+			return fail("test failed")
+		}
+	}
+	return nil
+}
+
+// add 实现add操作：对象上新增或替换key，列表上插入
+func (g *GSON) add(path []string, value []byte) error {
+	if len(path) == 0 {
+		g.reset(value)
+		return nil
+	}
+	parent, err := g.locate(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1]
+	switch parent.Type() {
+	case TypObject:
+		parent.objInit()
+		parent.v.o.Index(last).reset(value)
+		return nil
+	case TypList:
+		parent.listInit()
+		l := parent.v.l
+		l.complete()
+		i := len(l.els)
+		if last != "-" {
+			i, err = pointerIndex(last, len(l.els))
+			if err != nil {
+				return err
+			}
+		}
+		l.stub(i).reset(value)
+		return nil
+	}
+	return fmt.Errorf("cannot add to '%v'", parent.Type())
+}
+
+// locate 查找JSON Pointer指向的已存在节点
+func (g *GSON) locate(path []string) (*GSON, error) {
+	for i, tok := range path {
+		switch g.Type() {
+		case TypObject:
+			g.objInit()
+			c, ok := g.v.o.lookup(tok)
+			if !ok {
+				return nil, fmt.Errorf("key '%v' not found", tok)
+			}
+			g = c
+		case TypList:
+			els := g.elems()
+			j, err := pointerIndex(tok, len(els)-1)
+			if err != nil {
+				return nil, err
+			}
+			g = els[j]
+		default:
+			return nil, fmt.Errorf("cannot index '%v' at '%v'", g.Type(), formatPointer(path[:i]))
+		}
+	}
+	return g, nil
+}
+
+// pointerIndex 解析列表下标，允许范围[0, max]
+func pointerIndex(tok string, max int) (int, error) {
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') || tok[0] == '-' || tok[0] == '+' {
+		return 0, fmt.Errorf("invalid index '%v'", tok)
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil {
+		return 0, fmt.Errorf("invalid index '%v'", tok)
+	}
+	if i > max {
+		return 0, fmt.Errorf("index %v out of range", i)
+	}
+	return i, nil
+}
+
+// parsePointer 解析RFC 6901 JSON Pointer
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if ptr[0] != '/' {
+		return nil, errors.New("json pointer must start with '/'")
+	}
+	toks := strings.Split(ptr[1:], "/")
+	for i, tok := range toks {
+		if strings.IndexByte(tok, '~') >= 0 {
+			toks[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		}
+	}
+	return toks, nil
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func formatPointer(toks []string) string {
+	var sb strings.Builder
+	for _, tok := range toks {
+		sb.WriteByte('/')
+		sb.WriteString(pointerEscaper.Replace(tok))
+	}
+	return sb.String()
+}
+
+// - - - - - - - - - - merge patch - - - - - - - - - -
+
+// MergePatch 按RFC 7386合并patch：
+// null删除对应key，对象递归合并，其它值直接替换。
+func (g *GSON) MergePatch(patch []byte) error {
+	if g.e != nil {
+		return g.e
+	}
+	p := FromBytes(patch)
+	if p.Type() == TypUnknown || !json.Valid(patch) {
+		return errors.New("gson: invalid merge patch")
+	}
+	g.mergePatch(p)
+	return nil
+}
+
+func (g *GSON) mergePatch(p *GSON) {
+	if p.Type() != TypObject {
+		b, _ := p.MarshalJSON()
+		g.reset(b)
+		return
+	}
+	if g.Type() != TypObject {
+		g.reset([]byte{'{', '}'})
+	}
+	g.objInit()
+	for _, k := range p.Keys() {
+		v := p.ObjIdx(k)
+		if v.IsNull() {
+			if c, ok := g.v.o.lookup(k); ok {
+				c.Remove()
+			}
+			continue
+		}
+		g.v.o.Index(k).mergePatch(v)
+	}
+}
+
+// - - - - - - - - - - diff - - - - - - - - - -
+
+// Diff 计算将a变为b的JSON Patch
+func Diff(a, b *GSON) Patch {
+	var p Patch
+	diff(nil, a, b, &p)
+	return p
+}
+
+func diff(path []string, a, b *GSON, p *Patch) {
+	at, bt := a.Type(), b.Type()
+	switch {
+	case at == TypObject && bt == TypObject:
+		a.objInit()
+		b.objInit()
+		for _, k := range a.Keys() {
+			sub := append(path[:len(path):len(path)], k)
+			if bc, ok := b.v.o.lookup(k); ok {
+				diff(sub, a.v.o.mp[k], bc, p)
+			} else {
+				*p = append(*p, Operation{Op: "remove", Path: formatPointer(sub)})
+			}
+		}
+		for _, k := range b.Keys() {
+			if _, ok := a.v.o.mp[k]; !ok {
+				v, _ := b.v.o.mp[k].MarshalJSON()
+				sub := append(path[:len(path):len(path)], k)
+				*p = append(*p, Operation{Op: "add", Path: formatPointer(sub), Value: v})
+			}
+		}
+		return
+
+	case at == TypList && bt == TypList:
+		ae, be := a.elems(), b.elems()
+		for i := 0; i < len(ae) && i < len(be); i++ {
+			diff(append(path[:len(path):len(path)], strconv.Itoa(i)), ae[i], be[i], p)
+		}
+		for i := len(ae) - 1; i >= len(be); i-- {
+			sub := append(path[:len(path):len(path)], strconv.Itoa(i))
+			*p = append(*p, Operation{Op: "remove", Path: formatPointer(sub)})
+		}
+		for i := len(ae); i < len(be); i++ {
+			v, _ := be[i].MarshalJSON()
+			sub := append(path[:len(path):len(path)], "-")
+			*p = append(*p, Operation{Op: "add", Path: formatPointer(sub), Value: v})
+		}
+		return
+	}
+
+	if !Equal(a, b) {
+		y, _ := b.MarshalJSON()
+		*p = append(*p, Operation{Op: "replace", Path: formatPointer(path), Value: y})
+	}
+}
@@ -0,0 +1,571 @@
+package gson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 路径语法解析，Get和Query共用。
+//
+// Get(smart key)只支持单值路径：
+//	key.list[0][-1]["a.b"]['c'].k
+// Query额外支持(JSONPath子集)：
+//	$.items[*].id          通配符
+//	$..id                  递归下降
+//	$.list[1:3], [::-1]    切片
+//	$.list[0,2], ['a','b'] 联合
+//	$.items[?(@.price > 10 && @.tags[0] == "x")] 过滤
+
+type PathSyntaxErr struct {
+	Path   string
+	Offset int
+	Msg    string
+}
+
+func (ps PathSyntaxErr) Error() string {
+	return fmt.Sprintf("gson: path '%v' invalid at offset %v: %v", ps.Path, ps.Offset, ps.Msg)
+}
+
+type selector interface {
+	// selectFrom 将g中匹配的节点追加到dst
+	selectFrom(root, g *GSON, dst []*GSON) []*GSON
+}
+
+type keySel string
+
+type indexSel int
+
+type wildcardSel struct{}
+
+type sliceSel struct {
+	start, end *int
+	step       int
+}
+
+type unionSel []selector
+
+type descentSel struct {
+	s selector
+}
+
+type filterSel struct {
+	e expr
+}
+
+type pathParser struct {
+	s     string
+	i     int
+	query bool // JSONPath mode
+}
+
+func (p *pathParser) errorf(format string, a ...interface{}) error {
+	return PathSyntaxErr{Path: p.s, Offset: p.i, Msg: fmt.Sprintf(format, a...)}
+}
+
+func (p *pathParser) eof() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *pathParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *pathParser) skipSpace() {
+	for !p.eof() && isSpace(p.s[p.i]) {
+		p.i++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c == '$' || c == '-' || c >= utf8.RuneSelf ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// parseSmartPath 解析Get使用的路径，'.'分隔key，允许空key。
+func parseSmartPath(path string) ([]selector, error) {
+	p := &pathParser{s: path}
+	var sels []selector
+	for part := 0; ; part++ {
+		start := p.i
+		for !p.eof() && p.s[p.i] != '.' && p.s[p.i] != '[' {
+			if p.s[p.i] == ']' {
+				return nil, p.errorf("unexpected ']'")
+			}
+			p.i++
+		}
+		if p.i > start || p.peek() != '[' {
+			sels = append(sels, keySel(path[start:p.i]))
+		} else if part > 0 { // "a.[0]"
+			return nil, p.errorf("expect key before '['")
+		}
+		for p.peek() == '[' {
+			s, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			sels = append(sels, s)
+		}
+		if p.eof() {
+			return sels, nil
+		}
+		if p.s[p.i] != '.' {
+			return nil, p.errorf("expect '.' or '['")
+		}
+		p.i++
+	}
+}
+
+// SplitPath 按Get的语法拆分路径，key为string，下标为int，
+// 供其它包实现与Get一致的路径。
+func SplitPath(path string) ([]interface{}, error) {
+	sels, err := parseSmartPath(path)
+	if err != nil {
+		return nil, err
+	}
+	segs := make([]interface{}, len(sels))
+	for i, sel := range sels {
+		switch s := sel.(type) {
+		case keySel:
+			segs[i] = string(s)
+		case indexSel:
+			segs[i] = int(s)
+		}
+	}
+	return segs, nil
+}
+
+// parseQueryPath 解析Query使用的JSONPath。
+func parseQueryPath(path string) ([]selector, error) {
+	p := &pathParser{s: path, query: true}
+	p.skipSpace()
+	var sels []selector
+	switch p.peek() {
+	case '$':
+		p.i++
+	case '.', '[', 0:
+	default: // "items[*]" 等同于 "$.items[*]"
+		s, err := p.parseDotMember(false)
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, s)
+	}
+	more, err := p.parseSegments(false)
+	if err != nil {
+		return nil, err
+	}
+	sels = append(sels, more...)
+	p.skipSpace()
+	if !p.eof() {
+		return nil, p.errorf("unexpected '%c'", p.s[p.i])
+	}
+	return sels, nil
+}
+
+// parseSegments 解析'.name', '..name', '[...]'序列。
+// inFilter为true时，name只允许标识符字符。
+func (p *pathParser) parseSegments(inFilter bool) ([]selector, error) {
+	var sels []selector
+	for !p.eof() {
+		switch p.s[p.i] {
+		case '.':
+			p.i++
+			if p.peek() == '.' {
+				p.i++
+				var s selector
+				var err error
+				if p.peek() == '[' {
+					s, err = p.parseBracket()
+				} else {
+					s, err = p.parseDotMember(inFilter)
+				}
+				if err != nil {
+					return nil, err
+				}
+				sels = append(sels, descentSel{s: s})
+				continue
+			}
+			s, err := p.parseDotMember(inFilter)
+			if err != nil {
+				return nil, err
+			}
+			sels = append(sels, s)
+		case '[':
+			s, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			sels = append(sels, s)
+		default:
+			return sels, nil
+		}
+	}
+	return sels, nil
+}
+
+func (p *pathParser) parseDotMember(inFilter bool) (selector, error) {
+	if p.peek() == '*' {
+		p.i++
+		return wildcardSel{}, nil
+	}
+	start := p.i
+	for !p.eof() {
+		c := p.s[p.i]
+		if inFilter && !isNameByte(c) {
+			break
+		}
+		if c == '.' || c == '[' {
+			break
+		}
+		if c == ']' {
+			return nil, p.errorf("unexpected ']'")
+		}
+		p.i++
+	}
+	if p.i == start {
+		return nil, p.errorf("expect member name")
+	}
+	return keySel(p.s[start:p.i]), nil
+}
+
+func (p *pathParser) parseBracket() (selector, error) {
+	p.i++ // skip '['
+	var sels []selector
+	for {
+		p.skipSpace()
+		s, err := p.parseBracketItem()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, s)
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("expect ']'")
+		}
+		if p.s[p.i] == ']' {
+			p.i++
+			break
+		}
+		if p.s[p.i] != ',' || !p.query {
+			return nil, p.errorf("expect ']'")
+		}
+		p.i++
+	}
+	if len(sels) == 1 {
+		return sels[0], nil
+	}
+	return unionSel(sels), nil
+}
+
+func (p *pathParser) parseBracketItem() (selector, error) {
+	c := p.peek()
+	switch {
+	case c == '"' || c == '\'':
+		s, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return keySel(s), nil
+
+	case c == '*' && p.query:
+		p.i++
+		return wildcardSel{}, nil
+
+	case c == '?' && p.query:
+		p.i++
+		p.skipSpace()
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return filterSel{e: e}, nil
+
+	case c == ':' && p.query:
+		return p.parseSlice(nil)
+
+	case c == '-' || ('0' <= c && c <= '9'):
+		n, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.peek() == ':' && p.query {
+			return p.parseSlice(&n)
+		}
+		return indexSel(n), nil
+	}
+	return nil, p.errorf("invalid selector")
+}
+
+func (p *pathParser) parseInt() (int, error) {
+	start := p.i
+	if p.peek() == '-' {
+		p.i++
+	}
+	for !p.eof() && '0' <= p.s[p.i] && p.s[p.i] <= '9' {
+		p.i++
+	}
+	n, err := strconv.Atoi(p.s[start:p.i])
+	if err != nil {
+		p.i = start
+		return 0, p.errorf("invalid index")
+	}
+	return n, nil
+}
+
+// parseSlice 解析 start:end:step，start已解析。
+func (p *pathParser) parseSlice(start *int) (selector, error) {
+	s := sliceSel{start: start, step: 1}
+	for part := 0; part < 2 && p.peek() == ':'; part++ {
+		p.i++
+		p.skipSpace()
+		c := p.peek()
+		if c != '-' && (c < '0' || c > '9') {
+			continue
+		}
+		n, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if part == 0 {
+			s.end = &n
+		} else {
+			s.step = n
+		}
+	}
+	return s, nil
+}
+
+// parseQuoted 解析单引号或双引号字符串，支持json转义。
+func (p *pathParser) parseQuoted() (string, error) {
+	q := p.s[p.i]
+	start := p.i
+	p.i++
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.s[p.i]
+		if c == q {
+			p.i++
+			return sb.String(), nil
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			p.i++
+			continue
+		}
+		p.i++
+		if p.eof() {
+			break
+		}
+		c = p.s[p.i]
+		p.i++
+		switch c {
+		case '"', '\'', '\\', '/':
+			sb.WriteByte(c)
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'u':
+			if p.i+4 > len(p.s) {
+				return "", p.errorf("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(p.s[p.i:p.i+4], 16, 32)
+			if err != nil {
+				return "", p.errorf("invalid unicode escape")
+			}
+			sb.WriteRune(rune(r))
+			p.i += 4
+		default:
+			p.i -= 2
+			return "", p.errorf("invalid escape '\\%c'", c)
+		}
+	}
+	p.i = start
+	return "", p.errorf("unterminated string")
+}
+
+// - - - - - - - - - - selectors - - - - - - - - - -
+
+// each 按顺序遍历对象或列表的子节点，包括内嵌json字符串。
+func (g *GSON) each(f func(c *GSON)) {
+	switch g.Type() {
+	case TypObject, TypList, TypString:
+	default:
+		return
+	}
+	g.objInit()
+	if g.v.o != nil {
+		g.v.o.complete()
+		for _, k := range g.v.o.ks {
+			f(g.v.o.mp[k])
+		}
+		return
+	}
+	g.listInit()
+	if g.v.l != nil {
+		g.v.l.complete()
+		for _, c := range g.v.l.els {
+			f(c)
+		}
+	}
+}
+
+func (g *GSON) elems() []*GSON {
+	els, _ := g.items()
+	return els
+}
+
+// items 同elems，但原始数据有误时返回扫描错误，而不是已扫描的部分，
+// 序列化、比较等需要完整数据的地方须使用items
+func (g *GSON) items() ([]*GSON, error) {
+	switch g.Type() {
+	case TypList, TypString:
+	default:
+		return nil, nil
+	}
+	g.listInit()
+	if g.v.l == nil {
+		return nil, nil
+	}
+	g.v.l.complete()
+	if g.v.l.err != nil {
+		return nil, g.v.l.err
+	}
+	return g.v.l.els, nil
+}
+
+func (s keySel) selectFrom(root, g *GSON, dst []*GSON) []*GSON {
+	switch g.Type() {
+	case TypObject, TypString:
+	default:
+		return dst
+	}
+	g.objInit()
+	if g.v.o == nil {
+		return dst
+	}
+	if c, ok := g.v.o.lookup(string(s)); ok {
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+func (s indexSel) selectFrom(root, g *GSON, dst []*GSON) []*GSON {
+	els := g.elems()
+	i := int(s)
+	if i < 0 {
+		i += len(els)
+	}
+	if 0 <= i && i < len(els) {
+		dst = append(dst, els[i])
+	}
+	return dst
+}
+
+func (wildcardSel) selectFrom(root, g *GSON, dst []*GSON) []*GSON {
+	g.each(func(c *GSON) { dst = append(dst, c) })
+	return dst
+}
+
+func (s sliceSel) selectFrom(root, g *GSON, dst []*GSON) []*GSON {
+	els := g.elems()
+	n := len(els)
+	if s.step == 0 || n == 0 {
+		return dst
+	}
+	norm := func(i int) int {
+		if i < 0 {
+			return n + i
+		}
+		return i
+	}
+	clamp := func(i, lo, hi int) int {
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+		return i
+	}
+	if s.step > 0 {
+		start, end := 0, n
+		if s.start != nil {
+			start = clamp(norm(*s.start), 0, n)
+		}
+		if s.end != nil {
+			end = clamp(norm(*s.end), 0, n)
+		}
+		for i := start; i < end; i += s.step {
+			dst = append(dst, els[i])
+		}
+		return dst
+	}
+	start, end := n-1, -1
+	if s.start != nil {
+		start = clamp(norm(*s.start), -1, n-1)
+	}
+	if s.end != nil {
+		end = clamp(norm(*s.end), -1, n-1)
+	}
+	for i := start; i > end; i += s.step {
+		dst = append(dst, els[i])
+	}
+	return dst
+}
+
+func (u unionSel) selectFrom(root, g *GSON, dst []*GSON) []*GSON {
+	for _, s := range u {
+		dst = s.selectFrom(root, g, dst)
+	}
+	return dst
+}
+
+func (d descentSel) selectFrom(root, g *GSON, dst []*GSON) []*GSON {
+	dst = d.s.selectFrom(root, g, dst)
+	g.each(func(c *GSON) {
+		dst = d.selectFrom(root, c, dst)
+	})
+	return dst
+}
+
+func (f filterSel) selectFrom(root, g *GSON, dst []*GSON) []*GSON {
+	g.each(func(c *GSON) {
+		if f.e.eval(root, c).truth() {
+			dst = append(dst, c)
+		}
+	})
+	return dst
+}
+
+func selectAll(root *GSON, sels []selector) []*GSON {
+	gs := []*GSON{root}
+	for _, s := range sels {
+		var next []*GSON
+		for _, g := range gs {
+			next = s.selectFrom(root, g, next)
+		}
+		gs = next
+		if len(gs) == 0 {
+			break
+		}
+	}
+	return gs
+}
@@ -0,0 +1,406 @@
+package gson
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+// Result 是Query返回的结果集，可遍历、计数并原地修改。
+type Result struct {
+	gs []*GSON
+	e  error
+}
+
+// Query 按JSONPath语法查询，返回所有匹配节点。
+// 不存在的key或越界下标不会被自动创建。
+func (g *GSON) Query(path string) *Result {
+	if g.e != nil {
+		return &Result{e: g.e}
+	}
+	sels, err := parseQueryPath(path)
+	if err != nil {
+		return &Result{e: err}
+	}
+	return &Result{gs: selectAll(g, sels)}
+}
+
+func (r *Result) Err() error {
+	return r.e
+}
+
+// Len 返回匹配节点数
+func (r *Result) Len() int {
+	return len(r.gs)
+}
+
+// Index 返回第i个节点，越界时返回带错误的GSON
+func (r *Result) Index(i int) *GSON {
+	if r.e != nil {
+		return &GSON{e: r.e}
+	}
+	if i < 0 || i >= len(r.gs) {
+		return &GSON{e: KeyNotFoundErr{Key: strconv.Itoa(i)}}
+	}
+	return r.gs[i]
+}
+
+// First 等同于Index(0)
+func (r *Result) First() *GSON {
+	return r.Index(0)
+}
+
+// Nodes 返回所有匹配节点
+func (r *Result) Nodes() []*GSON {
+	gs := make([]*GSON, len(r.gs))
+	copy(gs, r.gs)
+	return gs
+}
+
+// Each 顺序遍历匹配节点，f返回false时停止
+func (r *Result) Each(f func(i int, g *GSON) bool) {
+	for i, g := range r.gs {
+		if !f(i, g) {
+			return
+		}
+	}
+}
+
+// Paths 返回所有匹配节点的Path()
+func (r *Result) Paths() []string {
+	ps := make([]string, len(r.gs))
+	for i, g := range r.gs {
+		ps[i] = g.Path()
+	}
+	return ps
+}
+
+// Set 将所有匹配节点设置为v
+func (r *Result) Set(v interface{}) error {
+	if r.e != nil {
+		return r.e
+	}
+	b, e := json.Marshal(v)
+	if e != nil {
+		return e
+	}
+	for _, g := range r.gs {
+		g.reset(b)
+	}
+	return nil
+}
+
+// Remove 删除所有匹配节点
+func (r *Result) Remove() {
+	for _, g := range r.gs {
+		g.Remove()
+	}
+}
+
+// - - - - - - - - - - filter expression - - - - - - - - - -
+
+type expr interface {
+	eval(root, cur *GSON) operand
+}
+
+// operand 是过滤表达式中的值。
+// nothing表示路径不存在。
+type operand struct {
+	nothing bool
+	logical bool
+	isLogic bool
+	g       *GSON
+	lit     interface{} // nil, bool, float64, string
+}
+
+func (o operand) truth() bool {
+	if o.isLogic {
+		return o.logical
+	}
+	return !o.nothing
+}
+
+// value 将操作数统一为nil, bool, float64, string或复合类型的interface{}
+func (o operand) value() interface{} {
+	if o.g == nil {
+		return o.lit
+	}
+	switch o.g.Type() {
+	case TypNull:
+		return nil
+	case TypBool:
+		return o.g.Bool()
+	case TypNumber:
+		return o.g.Float()
+	case TypString:
+		return o.g.Str()
+	}
+	b, err := o.g.MarshalJSON()
+	if err != nil {
+		return nil
+	}
+	var v interface{}
+	json.Unmarshal(b, &v)
+	return v
+}
+
+type logicExpr struct {
+	op   string // "&&", "||"
+	l, r expr
+}
+
+type notExpr struct {
+	e expr
+}
+
+type cmpExpr struct {
+	op   string
+	l, r expr
+}
+
+type litExpr struct {
+	v interface{}
+}
+
+type pathExpr struct {
+	root bool // '$' or '@'
+	sels []selector
+}
+
+func (e logicExpr) eval(root, cur *GSON) operand {
+	l := e.l.eval(root, cur).truth()
+	if e.op == "&&" && !l {
+		return operand{isLogic: true}
+	}
+	if e.op == "||" && l {
+		return operand{isLogic: true, logical: true}
+	}
+	return operand{isLogic: true, logical: e.r.eval(root, cur).truth()}
+}
+
+func (e notExpr) eval(root, cur *GSON) operand {
+	return operand{isLogic: true, logical: !e.e.eval(root, cur).truth()}
+}
+
+func (e litExpr) eval(root, cur *GSON) operand {
+	return operand{lit: e.v}
+}
+
+func (e pathExpr) eval(root, cur *GSON) operand {
+	from := cur
+	if e.root {
+		from = root
+	}
+	gs := selectAll(from, e.sels)
+	if len(gs) == 0 {
+		return operand{nothing: true}
+	}
+	return operand{g: gs[0]}
+}
+
+func (e cmpExpr) eval(root, cur *GSON) operand {
+	l, r := e.l.eval(root, cur), e.r.eval(root, cur)
+	return operand{isLogic: true, logical: compare(e.op, l, r)}
+}
+
+func compare(op string, l, r operand) bool {
+	if l.nothing || r.nothing {
+		eq := l.nothing && r.nothing
+		switch op {
+		case "==", "<=", ">=":
+			return eq
+		case "!=":
+			return !eq
+		}
+		return false
+	}
+
+	lv, rv := l.value(), r.value()
+	switch op {
+	case "==":
+		return reflect.DeepEqual(lv, rv)
+	case "!=":
+		return !reflect.DeepEqual(lv, rv)
+	}
+
+	switch x := lv.(type) {
+	case float64:
+		y, ok := rv.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "<":
+			return x < y
+		case "<=":
+			return x <= y
+		case ">":
+			return x > y
+		case ">=":
+			return x >= y
+		}
+	case string:
+		y, ok := rv.(string)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "<":
+			return x < y
+		case "<=":
+			return x <= y
+		case ">":
+			return x > y
+		case ">=":
+			return x >= y
+		}
+	case bool, nil:
+		if op == "<=" || op == ">=" {
+			return reflect.DeepEqual(lv, rv)
+		}
+	}
+	return false
+}
+
+// - - - - - - - - - - expression parser - - - - - - - - - -
+
+// parseExpr 解析过滤表达式：
+//
+//	expr := or
+//	or   := and ('||' and)*
+//	and  := not ('&&' not)*
+//	not  := '!' not | cmp
+//	cmp  := primary (('=='|'!='|'<'|'<='|'>'|'>=') primary)?
+//	primary := '(' expr ')' | '@'path | '$'path | number | string | true | false | null
+func (p *pathParser) parseExpr() (expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.hasPrefix("||"); p.skipSpace() {
+		p.i += 2
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = logicExpr{op: "||", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *pathParser) parseAnd() (expr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.hasPrefix("&&"); p.skipSpace() {
+		p.i += 2
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = logicExpr{op: "&&", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *pathParser) parseNot() (expr, error) {
+	p.skipSpace()
+	if p.peek() == '!' && !p.hasPrefix("!=") {
+		p.i++
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e: e}, nil
+	}
+	return p.parseCmp()
+}
+
+var cmpOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (p *pathParser) parseCmp() (expr, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range cmpOps {
+		if p.hasPrefix(op) {
+			p.i += len(op)
+			p.skipSpace()
+			r, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return cmpExpr{op: op, l: l, r: r}, nil
+		}
+	}
+	return l, nil
+}
+
+func (p *pathParser) parsePrimary() (expr, error) {
+	p.skipSpace()
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.i++
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.peek() != ')' {
+			return nil, p.errorf("expect ')'")
+		}
+		p.i++
+		return e, nil
+
+	case c == '@' || c == '$':
+		p.i++
+		sels, err := p.parseSegments(true)
+		if err != nil {
+			return nil, err
+		}
+		return pathExpr{root: c == '$', sels: sels}, nil
+
+	case c == '"' || c == '\'':
+		s, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return litExpr{v: s}, nil
+
+	case c == '-' || ('0' <= c && c <= '9'):
+		start := p.i
+		p.i++
+		for !p.eof() && isNumberByte(p.s[p.i]) {
+			p.i++
+		}
+		f, err := strconv.ParseFloat(p.s[start:p.i], 64)
+		if err != nil {
+			p.i = start
+			return nil, p.errorf("invalid number")
+		}
+		return litExpr{v: f}, nil
+	}
+
+	for _, kw := range []struct {
+		s string
+		v interface{}
+	}{{"true", true}, {"false", false}, {"null", nil}} {
+		if p.hasPrefix(kw.s) {
+			p.i += len(kw.s)
+			return litExpr{v: kw.v}, nil
+		}
+	}
+	return nil, p.errorf("invalid filter expression")
+}
+
+func (p *pathParser) hasPrefix(s string) bool {
+	return len(p.s)-p.i >= len(s) && p.s[p.i:p.i+len(s)] == s
+}
+
+func isNumberByte(c byte) bool {
+	return ('0' <= c && c <= '9') || c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-'
+}
@@ -0,0 +1,157 @@
+package gson
+
+import (
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+)
+
+// 单遍扫描器：只定位值的起止位置，不解码。
+// object和list据此按需建立索引，避免整块json.Unmarshal。
+
+var errUnexpectedEnd = errors.New("gson: unexpected end of json input")
+
+func syntaxErr(b []byte, i int, msg string) error {
+	if i >= len(b) {
+		return errUnexpectedEnd
+	}
+	return errors.New("gson: invalid character '" + string(b[i]) + "' " + msg)
+}
+
+func skipSpace(b []byte, i int) int {
+	for i < len(b) && isSpace(b[i]) {
+		i++
+	}
+	return i
+}
+
+// scanValue 返回从b[i]开始的值的结束位置
+func scanValue(b []byte, i int) (int, error) {
+	if i >= len(b) {
+		return i, errUnexpectedEnd
+	}
+	switch c := b[i]; {
+	case c == '"':
+		return scanString(b, i)
+	case c == '{' || c == '[':
+		return scanComposite(b, i)
+	case c == 't':
+		return scanLiteral(b, i, "true")
+	case c == 'f':
+		return scanLiteral(b, i, "false")
+	case c == 'n':
+		return scanLiteral(b, i, "null")
+	case c == '-' || ('0' <= c && c <= '9'):
+		return scanNumber(b, i)
+	}
+	return i, syntaxErr(b, i, "looking for beginning of value")
+}
+
+// scanString 返回字符串结束引号之后的位置
+func scanString(b []byte, i int) (int, error) {
+	for i++; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return i, errUnexpectedEnd
+}
+
+// scanComposite 跳过对象或列表，只匹配括号，不校验内部结构
+func scanComposite(b []byte, i int) (int, error) {
+	depth := 0
+	for ; i < len(b); i++ {
+		switch b[i] {
+		case '"':
+			end, err := scanString(b, i)
+			if err != nil {
+				return end, err
+			}
+			i = end - 1
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return i, errUnexpectedEnd
+}
+
+func scanLiteral(b []byte, i int, lit string) (int, error) {
+	if len(b)-i < len(lit) || string(b[i:i+len(lit)]) != lit {
+		return i, syntaxErr(b, i, "in literal "+lit)
+	}
+	return i + len(lit), nil
+}
+
+// scanNumber 按json数字语法扫描：-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?
+func scanNumber(b []byte, i int) (int, error) {
+	if i < len(b) && b[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(b) && b[i] == '0':
+		i++
+	case i < len(b) && '1' <= b[i] && b[i] <= '9':
+		i = scanDigits(b, i)
+	default:
+		return i, syntaxErr(b, i, "in numeric literal")
+	}
+	if i < len(b) && b[i] == '.' {
+		j := scanDigits(b, i+1)
+		if j == i+1 {
+			return j, syntaxErr(b, j, "after decimal point in numeric literal")
+		}
+		i = j
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		j := scanDigits(b, i)
+		if j == i {
+			return j, syntaxErr(b, j, "in exponent of numeric literal")
+		}
+		i = j
+	}
+	return i, nil
+}
+
+func scanDigits(b []byte, i int) int {
+	for i < len(b) && '0' <= b[i] && b[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// unquote 解码json字符串，无转义时直接转换
+func unquote(raw []byte) (string, error) {
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return "", errors.New("gson: invalid string literal")
+	}
+	s := raw[1 : len(raw)-1]
+	for _, c := range s {
+		if c == '\\' || c < ' ' || c >= utf8.RuneSelf {
+			var v string
+			err := json.Unmarshal(raw, &v)
+			return v, err
+		}
+	}
+	return string(s), nil
+}
+
+// first 返回第一个非空白字符
+func first(b []byte) byte {
+	i := skipSpace(b, 0)
+	if i < len(b) {
+		return b[i]
+	}
+	return 0
+}
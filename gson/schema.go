@@ -0,0 +1,507 @@
+package gson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// JSON Schema (draft 2020-12) 子集校验，支持的关键字：
+//	type, enum, const, $ref(仅文档内), $defs
+//	properties, required, additionalProperties, minProperties, maxProperties
+//	items, prefixItems, minItems, maxItems
+//	minimum, maximum, exclusiveMinimum, exclusiveMaximum
+//	minLength, maxLength, pattern
+//	allOf, anyOf, oneOf, not
+
+type Schema struct {
+	root *GSON
+	res  sync.Map // pattern -> *regexp.Regexp
+}
+
+// Violation 是一条校验失败记录，Path为实例节点的Path()
+type Violation struct {
+	Path    string
+	Keyword string
+	Msg     string
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("gson: schema %v: %v, path: %v", v.Keyword, v.Msg, v.Path)
+}
+
+// NewSchema 使用g作为schema文档
+func NewSchema(g *GSON) (*Schema, error) {
+	if g.e != nil {
+		return nil, g.e
+	}
+	switch g.Type() {
+	case TypObject, TypBool:
+	default:
+		return nil, TypOpErr{Op: "schema", Typ: g.Type(), Path: g.Path()}
+	}
+	return &Schema{root: g}, nil
+}
+
+// SchemaFromFile 从文件加载schema
+func SchemaFromFile(name string) (*Schema, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(b) {
+		return nil, fmt.Errorf("gson: schema file '%v' is not valid json", name)
+	}
+	return NewSchema(FromBytes(b))
+}
+
+// Validate 校验g，返回全部失败项，通过时返回nil
+func (s *Schema) Validate(g *GSON) []Violation {
+	var vs []Violation
+	s.validate(s.root, g, make(map[[2]*GSON]bool), &vs)
+	return vs
+}
+
+func (s *Schema) regexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := s.res.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	s.res.Store(pattern, re)
+	return re, nil
+}
+
+// schemaType 返回实例对应的JSON Schema类型
+func schemaType(g *GSON) string {
+	switch g.Type() {
+	case TypObject:
+		return "object"
+	case TypList:
+		return "array"
+	case TypString:
+		return "string"
+	case TypNumber:
+		f := g.Float()
+		if f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	case TypBool:
+		return "boolean"
+	case TypNull:
+		return "null"
+	}
+	return "unknown"
+}
+
+func (s *Schema) validate(sc, g *GSON, visiting map[[2]*GSON]bool, vs *[]Violation) {
+	add := func(keyword, format string, a ...interface{}) {
+		*vs = append(*vs, Violation{Path: g.Path(), Keyword: keyword, Msg: fmt.Sprintf(format, a...)})
+	}
+
+	if sc.Type() == TypBool {
+		if !sc.Bool() {
+			add("false", "no value allowed")
+		}
+		return
+	}
+	if sc.Type() != TypObject {
+		return
+	}
+	key := [2]*GSON{sc, g}
+	if visiting[key] {
+		return // $ref循环
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	sc.objInit()
+	kw := func(k string) *GSON {
+		c, _ := sc.v.o.lookup(k)
+		return c
+	}
+
+	if ref := kw("$ref"); ref != nil {
+		target, err := s.resolve(ref.Str())
+		if err != nil {
+			add("$ref", "%v", err)
+		} else {
+			s.validate(target, g, visiting, vs)
+		}
+	}
+
+	typ := schemaType(g)
+	if t := kw("type"); t != nil {
+		var want []string
+		if t.Type() == TypList {
+			for _, c := range t.elems() {
+				want = append(want, c.Str())
+			}
+		} else {
+			want = append(want, t.Str())
+		}
+		ok := false
+		for _, w := range want {
+			if w == typ || (w == "number" && typ == "integer") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			add("type", "expected %v, got %v", strings.Join(want, " or "), typ)
+			return
+		}
+	}
+
+	if e := kw("enum"); e != nil {
+		ok := false
+		for _, c := range e.elems() {
+			if Equal(c, g) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			b, _ := e.MarshalJSON()
+			add("enum", "value must be one of %s", b)
+		}
+	}
+	if c := kw("const"); c != nil && !Equal(c, g) {
+		b, _ := c.MarshalJSON()
+		add("const", "value must be %s", b)
+	}
+
+	switch typ {
+	case "object":
+		s.validateObject(kw, g, visiting, vs, add)
+	case "array":
+		s.validateArray(kw, g, visiting, vs, add)
+	case "string":
+		n := utf8.RuneCountInString(g.Str())
+		if m := kw("minLength"); m != nil && n < int(m.Int()) {
+			add("minLength", "length %v less than %v", n, m.Int())
+		}
+		if m := kw("maxLength"); m != nil && n > int(m.Int()) {
+			add("maxLength", "length %v greater than %v", n, m.Int())
+		}
+		if p := kw("pattern"); p != nil {
+			re, err := s.regexp(p.Str())
+			if err != nil {
+				add("pattern", "invalid pattern: %v", err)
+			} else if !re.MatchString(g.Str()) {
+				add("pattern", "does not match '%v'", p.Str())
+			}
+		}
+	case "integer", "number":
+		f := g.Float()
+		if m := kw("minimum"); m != nil && f < m.Float() {
+			add("minimum", "%v less than %v", f, m.Float())
+		}
+		if m := kw("maximum"); m != nil && f > m.Float() {
+			add("maximum", "%v greater than %v", f, m.Float())
+		}
+		if m := kw("exclusiveMinimum"); m != nil && f <= m.Float() {
+			add("exclusiveMinimum", "%v not greater than %v", f, m.Float())
+		}
+		if m := kw("exclusiveMaximum"); m != nil && f >= m.Float() {
+			add("exclusiveMaximum", "%v not less than %v", f, m.Float())
+		}
+	}
+
+	if all := kw("allOf"); all != nil {
+		for _, c := range all.elems() {
+			s.validate(c, g, visiting, vs)
+		}
+	}
+	if anyOf := kw("anyOf"); anyOf != nil {
+		ok := false
+		for _, c := range anyOf.elems() {
+			if s.passes(c, g, visiting) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			add("anyOf", "does not match any schema")
+		}
+	}
+	if oneOf := kw("oneOf"); oneOf != nil {
+		n := 0
+		for _, c := range oneOf.elems() {
+			if s.passes(c, g, visiting) {
+				n++
+			}
+		}
+		if n != 1 {
+			add("oneOf", "matches %v schemas, expected exactly one", n)
+		}
+	}
+	if not := kw("not"); not != nil && s.passes(not, g, visiting) {
+		add("not", "must not match schema")
+	}
+}
+
+func (s *Schema) passes(sc, g *GSON, visiting map[[2]*GSON]bool) bool {
+	var vs []Violation
+	s.validate(sc, g, visiting, &vs)
+	return len(vs) == 0
+}
+
+func (s *Schema) validateObject(kw func(string) *GSON, g *GSON,
+	visiting map[[2]*GSON]bool, vs *[]Violation, add func(string, string, ...interface{})) {
+	g.objInit()
+	ks := g.Keys()
+	if m := kw("minProperties"); m != nil && len(ks) < int(m.Int()) {
+		add("minProperties", "%v properties less than %v", len(ks), m.Int())
+	}
+	if m := kw("maxProperties"); m != nil && len(ks) > int(m.Int()) {
+		add("maxProperties", "%v properties greater than %v", len(ks), m.Int())
+	}
+	if req := kw("required"); req != nil {
+		for _, c := range req.elems() {
+			if _, ok := g.v.o.lookup(c.Str()); !ok {
+				add("required", "missing property '%v'", c.Str())
+			}
+		}
+	}
+
+	props := kw("properties")
+	if props != nil {
+		props.objInit()
+	}
+	for _, k := range ks {
+		child := g.v.o.mp[k]
+		if props != nil && props.v.o != nil {
+			if p, ok := props.v.o.lookup(k); ok {
+				s.validate(p, child, visiting, vs)
+				continue
+			}
+		}
+		if ap := kw("additionalProperties"); ap != nil {
+			if ap.Type() == TypBool && !ap.Bool() {
+				*vs = append(*vs, Violation{Path: child.Path(), Keyword: "additionalProperties",
+					Msg: fmt.Sprintf("property '%v' not allowed", k)})
+				continue
+			}
+			s.validate(ap, child, visiting, vs)
+		}
+	}
+}
+
+func (s *Schema) validateArray(kw func(string) *GSON, g *GSON,
+	visiting map[[2]*GSON]bool, vs *[]Violation, add func(string, string, ...interface{})) {
+	els := g.elems()
+	if m := kw("minItems"); m != nil && len(els) < int(m.Int()) {
+		add("minItems", "%v items less than %v", len(els), m.Int())
+	}
+	if m := kw("maxItems"); m != nil && len(els) > int(m.Int()) {
+		add("maxItems", "%v items greater than %v", len(els), m.Int())
+	}
+	n := 0
+	if prefix := kw("prefixItems"); prefix != nil {
+		for i, p := range prefix.elems() {
+			if i >= len(els) {
+				break
+			}
+			s.validate(p, els[i], visiting, vs)
+			n++
+		}
+	}
+	if items := kw("items"); items != nil {
+		for _, c := range els[n:] {
+			s.validate(items, c, visiting, vs)
+		}
+	}
+}
+
+// resolve 解析文档内引用，如"#", "#/$defs/name"
+func (s *Schema) resolve(ref string) (*GSON, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("only local reference is supported: '%v'", ref)
+	}
+	toks, err := parsePointer(ref[1:])
+	if err != nil {
+		return nil, err
+	}
+	return s.root.locate(toks)
+}
+
+// - - - - - - - - - - schema from struct - - - - - - - - - -
+
+// SchemaFromStruct 根据Go类型生成schema，v可以是值或指针。
+// 字段名取自json tag，约束取自schema tag，例如：
+//
+//	Name string `json:"name" schema:"required,minLength=1,maxLength=32"`
+//	Age  int    `json:"age" schema:"minimum=0,maximum=150"`
+//	Kind string `json:"kind" schema:"enum=a|b|c"`
+//	Code string `json:"code" schema:"pattern=^[a-z]+$"`
+//
+// pattern会取到tag结尾，须放在最后。
+// 指针允许null，具名struct放在$defs中，以包路径加类型名为key。
+func SchemaFromStruct(v interface{}) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return nil, fmt.Errorf("gson: SchemaFromStruct(nil)")
+	}
+	sg := &schemaGen{root: t, defs: make(M)}
+	root := sg.typeSchema(t)
+	if len(sg.defs) > 0 {
+		root["$defs"] = sg.defs
+	}
+	b, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchema(FromBytes(b))
+}
+
+type schemaGen struct {
+	root   reflect.Type
+	inRoot bool
+	defs   M
+}
+
+func (sg *schemaGen) typeSchema(t reflect.Type) M {
+	if t.Kind() == reflect.Ptr {
+		return nullable(sg.typeSchema(t.Elem()))
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return M{}
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return M{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return M{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return M{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return M{"type": "number"}
+	case reflect.String:
+		return M{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return M{"type": "string"}
+		}
+		return M{"type": "array", "items": sg.typeSchema(t.Elem())}
+	case reflect.Map:
+		return M{"type": "object", "additionalProperties": sg.typeSchema(t.Elem())}
+	case reflect.Struct:
+		return sg.structSchema(t)
+	}
+	return M{}
+}
+
+func (sg *schemaGen) structSchema(t reflect.Type) M {
+	if t != sg.root && t.Name() != "" {
+		name := t.PkgPath() + "." + t.Name() // 不同包的同名类型不能共用
+		if _, ok := sg.defs[name]; !ok {
+			sg.defs[name] = M{} // 占位，防止递归
+			sg.defs[name] = sg.structBody(t)
+		}
+		return M{"$ref": "#/$defs/" + pointerEscaper.Replace(name)}
+	}
+	if t == sg.root && !sg.inRoot {
+		sg.inRoot = true
+		return sg.structBody(t)
+	}
+	return M{"$ref": "#"}
+}
+
+func (sg *schemaGen) structBody(t reflect.Type) M {
+	props := make(M)
+	var required []string
+	for _, f := range cachedFields(t).list {
+		sf := t.FieldByIndex(f.index)
+		ft, ptr := sf.Type, false
+		for ft.Kind() == reflect.Ptr {
+			ft, ptr = ft.Elem(), true
+		}
+		ps := sg.typeSchema(ft)
+		if f.quoted {
+			ps = M{"type": "string"}
+		}
+		if applySchemaTag(ps, sf.Tag.Get("schema")) {
+			required = append(required, f.name)
+		}
+		if ptr { // 约束作用于指向的值
+			ps = nullable(ps)
+		}
+		props[f.name] = ps
+	}
+	s := M{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// nullable 使s同时接受null
+func nullable(s M) M {
+	switch t := s["type"].(type) {
+	case string:
+		s["type"] = []string{t, "null"}
+		if enum, ok := s["enum"].([]interface{}); ok {
+			s["enum"] = append(enum, nil)
+		}
+	case nil:
+		if _, ok := s["$ref"]; ok {
+			return M{"anyOf": []M{s, {"type": "null"}}}
+		}
+	}
+	return s
+}
+
+// applySchemaTag 将tag中的约束写入s，返回是否required
+func applySchemaTag(s M, tag string) bool {
+	required := false
+	for tag != "" {
+		var item string
+		if strings.HasPrefix(tag, "pattern=") {
+			item, tag = tag, ""
+		} else {
+			item, tag, _ = strings.Cut(tag, ",")
+		}
+		k, v, _ := strings.Cut(item, "=")
+		switch k {
+		case "required":
+			required = true
+		case "pattern":
+			s[k] = v
+		case "enum":
+			var enum []interface{}
+			for _, e := range strings.Split(v, "|") {
+				if s["type"] == "integer" || s["type"] == "number" {
+					if f, err := strconv.ParseFloat(e, 64); err == nil {
+						enum = append(enum, f)
+						continue
+					}
+				}
+				enum = append(enum, e)
+			}
+			s[k] = enum
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum",
+			"minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties":
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				s[k] = f
+			}
+		}
+	}
+	return required
+}
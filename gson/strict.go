@@ -0,0 +1,213 @@
+package gson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 严格取值：类型不符或key不存在时返回错误，而不是零值。
+
+type TypMismatchErr struct {
+	Want Type
+	Typ  Type
+	Path string
+}
+
+func (tm TypMismatchErr) Error() string {
+	return fmt.Sprintf("gson: want '%v' but got '%v', path: %v", tm.Want, tm.Typ, tm.Path)
+}
+
+type missing struct {
+	p     *GSON
+	key   string
+	idx   int
+	isIdx bool
+}
+
+// wantPath 返回节点路径，不存在的节点返回其期望路径
+func (g *GSON) wantPath() string {
+	m := g.v.miss
+	if m == nil {
+		return g.Path()
+	}
+	if m.isIdx {
+		return fmt.Sprintf("%v[%v]", m.p.wantPath(), m.idx)
+	}
+	return joinKey(m.p.wantPath(), m.key)
+}
+
+// check 返回节点的访问错误：链式访问中的错误、key不存在或类型不符
+func (g *GSON) check(want Type) error {
+	if kn, ok := g.e.(KeyNotFoundErr); ok && kn.Expected == "" {
+		kn.Expected = want
+		return kn
+	}
+	if g.e != nil {
+		return g.e
+	}
+	if m := g.v.miss; m != nil {
+		for m.p.v.miss != nil { // 报告第一个不存在的key
+			m = m.p.v.miss
+		}
+		key := m.key
+		if m.isIdx {
+			key = strconv.Itoa(m.idx)
+		}
+		return KeyNotFoundErr{Key: key, Path: m.p.wantPath(), Expected: want}
+	}
+	if t := g.Type(); t != want {
+		return TypMismatchErr{Want: want, Typ: t, Path: g.Path()}
+	}
+	return nil
+}
+
+// IntE 返回整数值，非整数时返回错误
+func (g *GSON) IntE() (int64, error) {
+	if err := g.check(TypNumber); err != nil {
+		return 0, err
+	}
+	if !g.IsInt() {
+		return 0, fmt.Errorf("gson: number %v is not an int64, path: %v", g.Str(), g.Path())
+	}
+	return g.v.i, nil
+}
+
+func (g *GSON) FloatE() (float64, error) {
+	if err := g.check(TypNumber); err != nil {
+		return 0, err
+	}
+	return g.Float(), nil
+}
+
+func (g *GSON) StrE() (string, error) {
+	if err := g.check(TypString); err != nil {
+		return "", err
+	}
+	return g.Str(), nil
+}
+
+func (g *GSON) BoolE() (bool, error) {
+	if err := g.check(TypBool); err != nil {
+		return false, err
+	}
+	return g.Bool(), nil
+}
+
+func (g *GSON) MustInt() int64 {
+	i, err := g.IntE()
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+func (g *GSON) MustFloat() float64 {
+	f, err := g.FloatE()
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func (g *GSON) MustStr() string {
+	s, err := g.StrE()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (g *GSON) MustBool() bool {
+	b, err := g.BoolE()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+/*
+Accumulator 收集一次解析过程中所有失败的访问，例如：
+
+	var acc gson.Accumulator
+	name := acc.Str(g.Get("user.name"))
+	age := acc.Int(g.Get("user.age"))
+	tags := acc.List(g.Get("user.tags"))
+	if err := acc.Err(); err != nil {
+		return http.StatusBadRequest, err
+	}
+*/
+type Accumulator struct {
+	errs Errors
+}
+
+// Errors 是多个访问错误的集合
+type Errors []error
+
+func (es Errors) Error() string {
+	ss := make([]string, len(es))
+	for i, e := range es {
+		ss[i] = strings.TrimPrefix(e.Error(), "gson: ")
+	}
+	return fmt.Sprintf("gson: %v error(s): %v", len(es), strings.Join(ss, "; "))
+}
+
+func (acc *Accumulator) add(err error) {
+	if err != nil {
+		acc.errs = append(acc.errs, err)
+	}
+}
+
+// Err 没有失败时返回nil，否则返回Errors
+func (acc *Accumulator) Err() error {
+	if len(acc.errs) == 0 {
+		return nil
+	}
+	return acc.errs
+}
+
+// Errors 返回所有失败记录
+func (acc *Accumulator) Errors() []error {
+	return append([]error(nil), acc.errs...)
+}
+
+func (acc *Accumulator) Int(g *GSON) int64 {
+	i, err := g.IntE()
+	acc.add(err)
+	return i
+}
+
+func (acc *Accumulator) Float(g *GSON) float64 {
+	f, err := g.FloatE()
+	acc.add(err)
+	return f
+}
+
+func (acc *Accumulator) Str(g *GSON) string {
+	s, err := g.StrE()
+	acc.add(err)
+	return s
+}
+
+func (acc *Accumulator) Bool(g *GSON) bool {
+	b, err := g.BoolE()
+	acc.add(err)
+	return b
+}
+
+// Object 校验g为对象，返回g本身便于链式访问
+func (acc *Accumulator) Object(g *GSON) *GSON {
+	acc.add(g.check(TypObject))
+	return g
+}
+
+// List 校验g为列表，返回g本身便于链式访问
+func (acc *Accumulator) List(g *GSON) *GSON {
+	acc.add(g.check(TypList))
+	return g
+}
+
+// Decode 同g.Decode，记录失败
+func (acc *Accumulator) Decode(g *GSON, v interface{}) {
+	acc.add(g.Decode(v))
+}
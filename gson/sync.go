@@ -0,0 +1,187 @@
+package gson
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync"
+)
+
+// GSON在读取时也会更新内部缓存(惰性扫描、类型和数值缓存)，
+// 因此同一文档不能被多个goroutine同时访问。
+// Frozen是完全展开的只读快照，可并发读取；
+// SyncDoc是带锁的文档，可并发读写。
+
+// Frozen 只读快照，所有方法可并发调用
+type Frozen struct {
+	g *GSON
+}
+
+// Freeze 复制g并完全展开，之后的读操作不再修改内部状态
+func (g *GSON) Freeze() *Frozen {
+	if g.e != nil {
+		return &Frozen{g: &GSON{e: g.e}}
+	}
+	if g.v.miss != nil {
+		return &Frozen{g: &GSON{e: g.check(TypUnknown)}}
+	}
+	c := g.Clone()
+	c.materialize()
+	return &Frozen{g: c}
+}
+
+// materialize 展开整棵树并填充全部缓存
+func (g *GSON) materialize() {
+	switch g.Type() {
+	case TypObject:
+		g.objInit()
+		if g.v.o != nil {
+			g.v.o.complete()
+			for _, c := range g.v.o.mp {
+				c.materialize()
+			}
+		}
+	case TypList:
+		for _, c := range g.elems() {
+			c.materialize()
+		}
+	case TypString:
+		g.IsInt() // 同时缓存Str()
+		g.objInit()
+		if g.v.o != nil {
+			g.v.o.complete()
+			for _, c := range g.v.o.mp {
+				c.materialize()
+			}
+			break
+		}
+		g.listInit()
+		if g.v.l != nil {
+			g.v.l.complete()
+			for _, c := range g.v.l.els {
+				c.materialize()
+			}
+		}
+	case TypNumber:
+		g.IsInt()
+	case TypBool:
+		g.Bool()
+	}
+}
+
+// wrap 包装子节点，不存在的节点转为带KeyNotFoundErr的节点
+func (f *Frozen) wrap(g *GSON) *Frozen {
+	if g == f.g {
+		return f
+	}
+	if g.v.miss != nil {
+		return &Frozen{g: &GSON{e: g.check(TypUnknown)}}
+	}
+	return &Frozen{g: g}
+}
+
+func (f *Frozen) Err() error                   { return f.g.Err() }
+func (f *Frozen) Type() Type                   { return f.g.Type() }
+func (f *Frozen) Str() string                  { return f.g.Str() }
+func (f *Frozen) IsInt() bool                  { return f.g.IsInt() }
+func (f *Frozen) Int() int64                   { return f.g.Int() }
+func (f *Frozen) Float() float64               { return f.g.Float() }
+func (f *Frozen) Uint() uint64                 { return f.g.Uint() }
+func (f *Frozen) BigInt() *big.Int             { return f.g.BigInt() }
+func (f *Frozen) Decimal() *big.Rat            { return f.g.Decimal() }
+func (f *Frozen) Number() json.Number          { return f.g.Number() }
+func (f *Frozen) Bool() bool                   { return f.g.Bool() }
+func (f *Frozen) IsNull() bool                 { return f.g.IsNull() }
+func (f *Frozen) Exists() bool                 { return f.g.Exists() }
+func (f *Frozen) Keys() []string               { return f.g.Keys() }
+func (f *Frozen) Len() int                     { return f.g.Len() }
+func (f *Frozen) Path() string                 { return f.g.Path() }
+func (f *Frozen) MarshalJSON() ([]byte, error) { return f.g.MarshalJSON() }
+func (f *Frozen) Decode(v interface{}) error   { return f.g.Decode(v) }
+
+func (f *Frozen) ObjIdx(key string) *Frozen {
+	return f.wrap(f.g.ObjIdx(key))
+}
+
+func (f *Frozen) Index(i int) *Frozen {
+	return f.wrap(f.g.Index(i))
+}
+
+func (f *Frozen) Get(smartKey string) *Frozen {
+	return f.wrap(f.g.Get(smartKey))
+}
+
+func (f *Frozen) Any(keys ...string) *Frozen {
+	return f.wrap(f.g.Any(keys...))
+}
+
+// Query 同GSON.Query，结果只读
+func (f *Frozen) Query(path string) ([]*Frozen, error) {
+	r := f.g.Query(path)
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+	fs := make([]*Frozen, len(r.gs))
+	for i, g := range r.gs {
+		fs[i] = &Frozen{g: g}
+	}
+	return fs, nil
+}
+
+// Thaw 返回可修改的副本
+func (f *Frozen) Thaw() *GSON {
+	return f.g.Clone()
+}
+
+// SyncDoc 带锁的文档，所有方法可并发调用
+type SyncDoc struct {
+	mu sync.Mutex
+	g  *GSON
+}
+
+// NewSyncDoc 接管g，之后不应再直接访问g
+func NewSyncDoc(g *GSON) *SyncDoc {
+	return &SyncDoc{g: g}
+}
+
+// Do 在锁内访问文档，f返回后不应再持有其中的节点
+func (d *SyncDoc) Do(f func(g *GSON) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return f(d.g)
+}
+
+// Get 返回smartKey对应子树的只读快照
+func (d *SyncDoc) Get(smartKey string) *Frozen {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.g.Get(smartKey).Freeze()
+}
+
+func (d *SyncDoc) Set(smartKey string, v interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	g := d.g.Get(smartKey)
+	if g.e != nil {
+		return g.e
+	}
+	return g.Set(v)
+}
+
+func (d *SyncDoc) Remove(smartKey string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.g.Get(smartKey).Remove()
+}
+
+// Snapshot 返回整个文档的只读快照
+func (d *SyncDoc) Snapshot() *Frozen {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.g.Freeze()
+}
+
+func (d *SyncDoc) MarshalJSON() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.g.MarshalJSON()
+}
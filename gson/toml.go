@@ -0,0 +1,848 @@
+package gson
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+FromTOML 解析TOML v1.0，key保持原始顺序。
+日期时间按原样转为字符串，ToTOML时不加引号输出，因此可以往返转换；
+inf和nan无法用json表示，返回错误。
+*/
+func FromTOML(b []byte) *GSON {
+	p := &tomlParser{b: b, root: newNode(TypObject)}
+	if err := p.parse(); err != nil {
+		return &GSON{e: err}
+	}
+	return p.root.gson()
+}
+
+type tomlParser struct {
+	b     []byte
+	i     int
+	root  *node
+	cur   *node // 当前表
+	depth int
+}
+
+func (p *tomlParser) errorf(msg string, a ...interface{}) error {
+	return formatErr("toml", p.b, p.i, msg, a...)
+}
+
+func (p *tomlParser) skipWS() {
+	for p.i < len(p.b) && (p.b[p.i] == ' ' || p.b[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// skipComment 跳过注释，注释中不允许出现控制字符
+func (p *tomlParser) skipComment() error {
+	if p.i >= len(p.b) || p.b[p.i] != '#' {
+		return nil
+	}
+	for ; p.i < len(p.b) && p.b[p.i] != '\n'; p.i++ {
+		if c := p.b[p.i]; (c < ' ' && c != '\t' && c != '\r') || c == 0x7f {
+			return p.errorf("control character in comment")
+		}
+	}
+	return nil
+}
+
+// newline 跳过换行，当前位置不是换行时返回false
+func (p *tomlParser) newline() bool {
+	if p.i < len(p.b) && p.b[p.i] == '\n' {
+		p.i++
+		return true
+	}
+	if p.i+1 < len(p.b) && p.b[p.i] == '\r' && p.b[p.i+1] == '\n' {
+		p.i += 2
+		return true
+	}
+	return false
+}
+
+// endLine 当前行剩余部分只能是空白和注释
+func (p *tomlParser) endLine() error {
+	p.skipWS()
+	if err := p.skipComment(); err != nil {
+		return err
+	}
+	if p.i < len(p.b) && !p.newline() {
+		return p.errorf("expected newline, found %q", p.b[p.i])
+	}
+	return nil
+}
+
+func (p *tomlParser) parse() error {
+	p.cur = p.root
+	if bytes.HasPrefix(p.b, []byte("\xef\xbb\xbf")) {
+		p.i = 3
+	}
+	for p.i < len(p.b) {
+		p.skipWS()
+		if err := p.skipComment(); err != nil {
+			return err
+		}
+		if p.newline() || p.i >= len(p.b) {
+			continue
+		}
+		var err error
+		if p.b[p.i] == '[' {
+			err = p.header()
+		} else {
+			err = p.keyval(p.cur)
+		}
+		if err != nil {
+			return err
+		}
+		if err = p.endLine(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keys 解析key，a."b.c".'d'解析为[a b.c d]
+func (p *tomlParser) keys() ([]string, error) {
+	var ks []string
+	for {
+		p.skipWS()
+		if p.i >= len(p.b) {
+			return nil, p.errorf("unexpected end of input, expected a key")
+		}
+		var k string
+		switch c := p.b[p.i]; {
+		case c == '"':
+			if bytes.HasPrefix(p.b[p.i:], []byte(`"""`)) {
+				return nil, p.errorf("multi-line strings are not allowed as keys")
+			}
+			s, err := p.basicString()
+			if err != nil {
+				return nil, err
+			}
+			k = s
+		case c == '\'':
+			if bytes.HasPrefix(p.b[p.i:], []byte(`'''`)) {
+				return nil, p.errorf("multi-line strings are not allowed as keys")
+			}
+			s, err := p.literalString()
+			if err != nil {
+				return nil, err
+			}
+			k = s
+		case isBareKey(c):
+			start := p.i
+			for p.i < len(p.b) && isBareKey(p.b[p.i]) {
+				p.i++
+			}
+			k = string(p.b[start:p.i])
+		default:
+			return nil, p.errorf("invalid character %q in key", c)
+		}
+		ks = append(ks, k)
+		p.skipWS()
+		if p.i < len(p.b) && p.b[p.i] == '.' {
+			p.i++
+			continue
+		}
+		return ks, nil
+	}
+}
+
+func isBareKey(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c == '_' || c == '-'
+}
+
+// header 解析[table]或[[array.table]]
+func (p *tomlParser) header() error {
+	start := p.i
+	array := bytes.HasPrefix(p.b[p.i:], []byte("[["))
+	p.i++
+	if array {
+		p.i++
+	}
+	ks, err := p.keys()
+	if err != nil {
+		return err
+	}
+	if p.i >= len(p.b) || p.b[p.i] != ']' || (array && (p.i+1 >= len(p.b) || p.b[p.i+1] != ']')) {
+		return p.errorf("expected ']' at end of table header")
+	}
+	p.i++
+	if array {
+		p.i++
+	}
+
+	fail := func(msg string) error {
+		p.i = start
+		return p.errorf(msg, strings.Join(ks, "."))
+	}
+	t := p.root
+	for _, k := range ks[:len(ks)-1] {
+		c, ok := t.mp[k]
+		if !ok {
+			c = newNode(TypObject)
+			t.set(k, c)
+		}
+		switch {
+		case c.t == TypObject && !c.inline:
+			t = c
+		case c.t == TypList && c.arrTable:
+			t = c.els[len(c.els)-1]
+		default:
+			return fail("cannot define table %q: key already has a value")
+		}
+	}
+
+	last := ks[len(ks)-1]
+	c, ok := t.mp[last]
+	if array {
+		if !ok {
+			c = newNode(TypList)
+			c.arrTable = true
+			t.set(last, c)
+		} else if c.t != TypList || !c.arrTable {
+			return fail("cannot define array of tables %q: key already has a value")
+		}
+		e := newNode(TypObject)
+		e.header = true
+		c.els = append(c.els, e)
+		p.cur = e
+		return nil
+	}
+	if !ok {
+		c = newNode(TypObject)
+		t.set(last, c)
+	} else if c.t != TypObject || c.inline || c.header || c.dotted {
+		return fail("table %q already defined")
+	}
+	c.header = true
+	p.cur = c
+	return nil
+}
+
+// keyval 解析key = value并写入表t
+func (p *tomlParser) keyval(t *node) error {
+	start := p.i
+	ks, err := p.keys()
+	if err != nil {
+		return err
+	}
+	if p.i >= len(p.b) || p.b[p.i] != '=' {
+		return p.errorf("expected '=' after key")
+	}
+	p.i++
+	p.skipWS()
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+
+	fail := func(msg string) error {
+		p.i = start
+		return p.errorf(msg, strings.Join(ks, "."))
+	}
+	for _, k := range ks[:len(ks)-1] {
+		c, ok := t.mp[k]
+		if !ok {
+			c = newNode(TypObject)
+			c.dotted = true
+			t.set(k, c)
+		} else if c.t != TypObject || c.inline || c.header {
+			return fail("cannot define key %q: table already defined")
+		}
+		t = c
+	}
+	last := ks[len(ks)-1]
+	if _, ok := t.mp[last]; ok {
+		return fail("duplicate key %q")
+	}
+	t.set(last, v)
+	return nil
+}
+
+func (p *tomlParser) value() (*node, error) {
+	if p.i >= len(p.b) {
+		return nil, p.errorf("unexpected end of input, expected a value")
+	}
+	switch c := p.b[p.i]; c {
+	case '"':
+		var s string
+		var err error
+		if bytes.HasPrefix(p.b[p.i:], []byte(`"""`)) {
+			s, err = p.multiBasicString()
+		} else {
+			s, err = p.basicString()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &node{t: TypString, s: s}, nil
+	case '\'':
+		var s string
+		var err error
+		if bytes.HasPrefix(p.b[p.i:], []byte(`'''`)) {
+			s, err = p.multiLiteralString()
+		} else {
+			s, err = p.literalString()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &node{t: TypString, s: s}, nil
+	case '[':
+		return p.array()
+	case '{':
+		return p.inlineTable()
+	}
+	for _, lit := range []string{"true", "false"} {
+		if bytes.HasPrefix(p.b[p.i:], []byte(lit)) && (p.i+len(lit) >= len(p.b) || !isBareKey(p.b[p.i+len(lit)])) {
+			p.i += len(lit)
+			return &node{t: TypBool, raw: lit}, nil
+		}
+	}
+	return p.scalar()
+}
+
+var (
+	tomlDateTime = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})?)?$`)
+	tomlDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	tomlTime     = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?$`)
+	tomlInt      = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+	tomlHex      = regexp.MustCompile(`^0x[0-9A-Fa-f](_?[0-9A-Fa-f])*$`)
+	tomlOct      = regexp.MustCompile(`^0o[0-7](_?[0-7])*$`)
+	tomlBin      = regexp.MustCompile(`^0b[01](_?[01])*$`)
+	tomlFloat    = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)((\.[0-9](_?[0-9])*)([eE][+-]?[0-9](_?[0-9])*)?|[eE][+-]?[0-9](_?[0-9])*)$`)
+	tomlSpecial  = regexp.MustCompile(`^[+-]?(inf|nan)$`)
+)
+
+// scalar 解析数字和日期时间
+func (p *tomlParser) scalar() (*node, error) {
+	start := p.i
+	for p.i < len(p.b) && strings.IndexByte("0123456789abcdefABCDEFxonitTzZ_:.+-", p.b[p.i]) >= 0 {
+		p.i++
+	}
+	tok := string(p.b[start:p.i])
+	// 日期和时间之间可以用空格分隔
+	if tomlDate.MatchString(tok) && p.i+1 < len(p.b) &&
+		p.b[p.i] == ' ' && '0' <= p.b[p.i+1] && p.b[p.i+1] <= '9' {
+		p.i++
+		for p.i < len(p.b) && strings.IndexByte("0123456789zZ:.+-", p.b[p.i]) >= 0 {
+			p.i++
+		}
+		tok = string(p.b[start:p.i])
+	}
+
+	fail := func(msg string) (*node, error) {
+		p.i = start
+		return nil, p.errorf(msg, tok)
+	}
+	if tok == "" {
+		return nil, p.errorf("invalid value")
+	}
+	switch {
+	case tomlDateTime.MatchString(tok), tomlTime.MatchString(tok):
+		return &node{t: TypString, s: tok}, nil
+	case tomlSpecial.MatchString(tok):
+		return fail("%v cannot be represented in json")
+	case tomlInt.MatchString(tok):
+		n, _ := new(big.Int).SetString(strings.ReplaceAll(strings.TrimPrefix(tok, "+"), "_", ""), 10)
+		return &node{t: TypNumber, raw: n.String()}, nil
+	case tomlHex.MatchString(tok), tomlOct.MatchString(tok), tomlBin.MatchString(tok):
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[tok[1]]
+		n, _ := new(big.Int).SetString(strings.ReplaceAll(tok[2:], "_", ""), base)
+		return &node{t: TypNumber, raw: n.String()}, nil
+	case tomlFloat.MatchString(tok):
+		return &node{t: TypNumber, raw: strings.ReplaceAll(strings.TrimPrefix(tok, "+"), "_", "")}, nil
+	}
+	return fail("invalid value %q")
+}
+
+func (p *tomlParser) nest() error {
+	p.depth++
+	if p.depth > maxNesting {
+		return p.errorf("exceeded max depth")
+	}
+	return nil
+}
+
+// skipArrayWS 跳过数组中的空白、换行和注释
+func (p *tomlParser) skipArrayWS() error {
+	for {
+		p.skipWS()
+		if err := p.skipComment(); err != nil {
+			return err
+		}
+		if !p.newline() {
+			return nil
+		}
+	}
+}
+
+func (p *tomlParser) array() (*node, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	start := p.i
+	p.i++ // '['
+	n := newNode(TypList)
+	n.inline = true
+	for {
+		if err := p.skipArrayWS(); err != nil {
+			return nil, err
+		}
+		if p.i >= len(p.b) {
+			p.i = start
+			return nil, p.errorf("unterminated array")
+		}
+		if p.b[p.i] == ']' {
+			break
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		n.els = append(n.els, v)
+		if err = p.skipArrayWS(); err != nil {
+			return nil, err
+		}
+		if p.i < len(p.b) && p.b[p.i] == ',' {
+			p.i++
+			continue
+		}
+		if p.i < len(p.b) && p.b[p.i] == ']' {
+			break
+		}
+		return nil, p.errorf("expected ',' or ']' in array")
+	}
+	p.i++ // ']'
+	return n, nil
+}
+
+func (p *tomlParser) inlineTable() (*node, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	p.i++ // '{'
+	n := newNode(TypObject)
+	p.skipWS()
+	if p.i < len(p.b) && p.b[p.i] == '}' {
+		p.i++
+		n.inline = true
+		return n, nil
+	}
+	for {
+		if err := p.keyval(n); err != nil {
+			return nil, err
+		}
+		p.skipWS()
+		if p.i < len(p.b) && p.b[p.i] == ',' {
+			p.i++
+			p.skipWS()
+			if p.i < len(p.b) && p.b[p.i] == '}' {
+				return nil, p.errorf("trailing comma is not allowed in inline table")
+			}
+			continue
+		}
+		if p.i < len(p.b) && p.b[p.i] == '}' {
+			break
+		}
+		return nil, p.errorf("expected ',' or '}' in inline table")
+	}
+	p.i++ // '}'
+	freeze(n)
+	return n, nil
+}
+
+// freeze 内联表及其中由点分key定义的子表不能再被修改
+func freeze(n *node) {
+	if n.t != TypObject || n.inline {
+		return
+	}
+	n.inline = true
+	for _, c := range n.mp {
+		freeze(c)
+	}
+}
+
+func (p *tomlParser) checkChar(c byte, multiline bool) error {
+	if (c < ' ' && c != '\t' && !(multiline && (c == '\n' || c == '\r'))) || c == 0x7f {
+		if c == '\n' || c == '\r' {
+			return p.errorf("newline in string")
+		}
+		return p.errorf("control character %q in string", c)
+	}
+	return nil
+}
+
+func (p *tomlParser) basicString() (string, error) {
+	start := p.i
+	p.i++ // '"'
+	var buf []byte
+	for p.i < len(p.b) {
+		c := p.b[p.i]
+		switch {
+		case c == '"':
+			p.i++
+			return string(buf), nil
+		case c == '\\':
+			var err error
+			if buf, err = p.escape(buf); err != nil {
+				return "", err
+			}
+		default:
+			if err := p.checkChar(c, false); err != nil {
+				return "", err
+			}
+			buf = append(buf, c)
+			p.i++
+		}
+	}
+	p.i = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *tomlParser) multiBasicString() (string, error) {
+	start := p.i
+	p.i += 3
+	p.newline() // 紧跟的换行被忽略
+	var buf []byte
+	for p.i < len(p.b) {
+		c := p.b[p.i]
+		switch {
+		case bytes.HasPrefix(p.b[p.i:], []byte(`"""`)):
+			n := 3
+			for n < 5 && p.i+n < len(p.b) && p.b[p.i+n] == '"' {
+				n++
+			}
+			buf = append(buf, p.b[p.i+3:p.i+n]...)
+			p.i += n
+			return string(buf), nil
+		case c == '\\':
+			// 行尾的'\'删除其后所有空白和换行
+			j := p.i + 1
+			for j < len(p.b) && (p.b[j] == ' ' || p.b[j] == '\t') {
+				j++
+			}
+			if j < len(p.b) && (p.b[j] == '\n' || p.b[j] == '\r') {
+				p.i = j
+				for p.i < len(p.b) && (p.b[p.i] == ' ' || p.b[p.i] == '\t' || p.b[p.i] == '\n' || p.b[p.i] == '\r') {
+					p.i++
+				}
+				continue
+			}
+			var err error
+			if buf, err = p.escape(buf); err != nil {
+				return "", err
+			}
+		default:
+			if c == '\r' && !bytes.HasPrefix(p.b[p.i:], []byte("\r\n")) {
+				return "", p.errorf("control character %q in string", c)
+			}
+			if err := p.checkChar(c, true); err != nil {
+				return "", err
+			}
+			buf = append(buf, c)
+			p.i++
+		}
+	}
+	p.i = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *tomlParser) escape(buf []byte) ([]byte, error) {
+	p.i++ // '\\'
+	if p.i >= len(p.b) {
+		return nil, p.errorf("unterminated string")
+	}
+	e := p.b[p.i]
+	p.i++
+	switch e {
+	case 'b':
+		return append(buf, '\b'), nil
+	case 't':
+		return append(buf, '\t'), nil
+	case 'n':
+		return append(buf, '\n'), nil
+	case 'f':
+		return append(buf, '\f'), nil
+	case 'r':
+		return append(buf, '\r'), nil
+	case 'e':
+		return append(buf, 0x1b), nil
+	case '"', '\\':
+		return append(buf, e), nil
+	case 'u', 'U':
+		n := 4
+		if e == 'U' {
+			n = 8
+		}
+		if p.i+n <= len(p.b) {
+			v, err := strconv.ParseUint(string(p.b[p.i:p.i+n]), 16, 32)
+			if err == nil && utf8.ValidRune(rune(v)) {
+				p.i += n
+				return utf8.AppendRune(buf, rune(v)), nil
+			}
+		}
+	}
+	p.i -= 2
+	return nil, p.errorf("invalid escape sequence")
+}
+
+func (p *tomlParser) literalString() (string, error) {
+	start := p.i
+	p.i++ // '\''
+	for i := p.i; i < len(p.b); i++ {
+		c := p.b[i]
+		if c == '\'' {
+			s := string(p.b[p.i:i])
+			p.i = i + 1
+			return s, nil
+		}
+		if c != '\t' && (c < ' ' || c == 0x7f) {
+			p.i = i
+			return "", p.checkChar(c, false)
+		}
+	}
+	p.i = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *tomlParser) multiLiteralString() (string, error) {
+	start := p.i
+	p.i += 3
+	p.newline()
+	end := bytes.Index(p.b[p.i:], []byte(`'''`))
+	if end < 0 {
+		p.i = start
+		return "", p.errorf("unterminated string")
+	}
+	end += p.i
+	n := 3 // 最多两个引号可以紧贴在结束符之前
+	for n < 5 && end+n < len(p.b) && p.b[end+n] == '\'' {
+		n++
+	}
+	end += n - 3
+	for i := p.i; i < end; i++ {
+		c := p.b[i]
+		if c == '\r' && i+1 < end && p.b[i+1] == '\n' {
+			continue
+		}
+		if c != '\t' && c != '\n' && (c < ' ' || c == 0x7f) {
+			p.i = i
+			return "", p.checkChar(c, true)
+		}
+	}
+	s := string(p.b[p.i:end])
+	p.i = end + 3
+	return s, nil
+}
+
+// - - - - - - - - - - emitter - - - - - - - - - -
+
+/*
+ToTOML 输出TOML，g必须是对象。
+每个表中先输出普通键值，再输出子表([table])和对象数组([[table]])。
+符合TOML日期时间格式的字符串作为日期时间输出，不加引号。
+TOML没有null，遇到null返回错误。注释无法保留。
+*/
+func (g *GSON) ToTOML() ([]byte, error) {
+	if g.e != nil {
+		return nil, g.e
+	}
+	if g.Type() != TypObject {
+		return nil, TypOpErr{Op: "toml", Typ: g.Type(), Path: g.Path()}
+	}
+	buf := &bytes.Buffer{}
+	if err := writeTOMLTable(buf, g, nil); err != nil {
+		return nil, err
+	}
+	return bytes.TrimLeft(buf.Bytes(), "\n"), nil
+}
+
+func isTOMLArrayTable(g *GSON) bool {
+	if g.Type() != TypList {
+		return false
+	}
+	els := g.elems()
+	for _, c := range els {
+		if c.Type() != TypObject {
+			return false
+		}
+	}
+	return len(els) > 0
+}
+
+func writeTOMLTable(buf *bytes.Buffer, g *GSON, path []string) error {
+	ks, err := g.keys()
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for _, k := range ks {
+		c := g.ObjIdx(k)
+		if c.Type() == TypObject || isTOMLArrayTable(c) {
+			tables = append(tables, k)
+			continue
+		}
+		buf.WriteString(tomlKey(k))
+		buf.WriteString(" = ")
+		if err := writeTOMLValue(buf, c); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+	}
+
+	for _, k := range tables {
+		c := g.ObjIdx(k)
+		sub := append(path[:len(path):len(path)], k)
+		if c.Type() == TypObject {
+			fmt.Fprintf(buf, "\n[%v]\n", tomlPath(sub))
+			if err := writeTOMLTable(buf, c, sub); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, e := range c.elems() {
+			fmt.Fprintf(buf, "\n[[%v]]\n", tomlPath(sub))
+			if err := writeTOMLTable(buf, e, sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeTOMLValue(buf *bytes.Buffer, g *GSON) error {
+	switch g.Type() {
+	case TypObject:
+		ks, err := g.keys()
+		if err != nil {
+			return err
+		}
+		if len(ks) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{ ")
+		for i, k := range ks {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(tomlKey(k))
+			buf.WriteString(" = ")
+			if err := writeTOMLValue(buf, g.ObjIdx(k)); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(" }")
+		return nil
+
+	case TypList:
+		els, err := g.items()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte('[')
+		for i, c := range els {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := writeTOMLValue(buf, c); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+
+	case TypString:
+		if s := g.Str(); isTOMLDateTime(s) {
+			buf.WriteString(s)
+		} else {
+			writeTOMLString(buf, s)
+		}
+		return nil
+
+	case TypNumber, TypBool:
+		buf.WriteString(strings.TrimSpace(g.Str()))
+		return nil
+	}
+	return TypOpErr{Op: "toml", Typ: g.Type(), Path: g.Path()}
+}
+
+// isTOMLDateTime s是否为合法的TOML日期时间，如1979-05-27T07:32:00Z, 1979-05-27, 07:32:00
+func isTOMLDateTime(s string) bool {
+	validClock := func(hms string) bool {
+		_, err := time.Parse("15:04:05", hms)
+		return err == nil
+	}
+	if tomlTime.MatchString(s) {
+		return validClock(s[:8])
+	}
+	if !tomlDateTime.MatchString(s) {
+		return false
+	}
+	if _, err := time.Parse("2006-01-02", s[:10]); err != nil {
+		return false
+	}
+	return len(s) == 10 || validClock(s[11:19])
+}
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(k string) string {
+	if tomlBareKey.MatchString(k) {
+		return k
+	}
+	buf := &bytes.Buffer{}
+	writeTOMLString(buf, k)
+	return buf.String()
+}
+
+func tomlPath(ks []string) string {
+	ss := make([]string, len(ks))
+	for i, k := range ks {
+		ss[i] = tomlKey(k)
+	}
+	return strings.Join(ss, ".")
+}
+
+func writeTOMLString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			if r < ' ' || r == 0x7f {
+				fmt.Fprintf(buf, `\u%04X`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,268 @@
+package gson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 变更跟踪：记录文档上的每次Set, Remove及隐式插入，支持订阅和回滚。
+// 每次修改都会序列化修改前后的值，只应在需要时开启。
+
+type Op string
+
+const (
+	OpSet    Op = "set"    // 修改已存在的节点
+	OpInsert Op = "insert" // 新增节点，包括对不存在的路径赋值时隐式创建的父节点
+	OpRemove Op = "remove" // 删除节点
+)
+
+// Change 一次变更，Path相对于开启跟踪的节点
+type Change struct {
+	Op   Op
+	Path string
+	Old  json.RawMessage // OpInsert时为nil
+	New  json.RawMessage // OpRemove时为nil
+
+	idx int // OpRemove时在父对象中的位置，用于回滚时恢复key顺序
+}
+
+type tracker struct {
+	log  []Change
+	subs []*subscriber
+	busy bool // 正在记录一次修改，内部的修改不再单独记录
+}
+
+type subscriber struct {
+	prefix string
+	f      func(Change)
+}
+
+// Track 开启g的变更跟踪，之后g及其子节点上的修改都会被记录
+func (g *GSON) Track() {
+	if g.tr == nil {
+		g.tr = &tracker{}
+	}
+}
+
+// Changes 返回开启跟踪以来的全部变更
+func (g *GSON) Changes() []Change {
+	if g.tr == nil {
+		return nil
+	}
+	cs := make([]Change, len(g.tr.log))
+	copy(cs, g.tr.log)
+	return cs
+}
+
+// Subscribe 订阅path及其子节点上的变更，path的父节点被整体替换时同样通知。
+// f在修改完成后同步调用；返回的函数用于取消订阅。
+func (g *GSON) Subscribe(path string, f func(Change)) (cancel func()) {
+	g.Track()
+	s := &subscriber{prefix: path, f: f}
+	g.tr.subs = append(g.tr.subs, s)
+	return func() {
+		for i, x := range g.tr.subs {
+			if x == s {
+				g.tr.subs = append(g.tr.subs[:i:i], g.tr.subs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Snapshot 返回当前变更日志的位置，用于Rollback
+func (g *GSON) Snapshot() int {
+	g.Track()
+	return len(g.tr.log)
+}
+
+// Rollback 撤销snap之后的全部变更，并从日志中删除。
+// 撤销产生的逆向变更会通知订阅者。
+func (g *GSON) Rollback(snap int) error {
+	if g.tr == nil || snap < 0 || snap > len(g.tr.log) {
+		return fmt.Errorf("gson: invalid snapshot %v", snap)
+	}
+	for len(g.tr.log) > snap {
+		c := g.tr.log[len(g.tr.log)-1]
+		if err := g.undo(c); err != nil {
+			return err
+		}
+		g.tr.log = g.tr.log[:len(g.tr.log)-1]
+
+		switch c.Op {
+		case OpSet:
+			c.Old, c.New = c.New, c.Old
+		case OpInsert:
+			c.Op, c.Old, c.New = OpRemove, c.New, nil
+		case OpRemove:
+			c.Op, c.Old, c.New = OpInsert, nil, c.Old
+		}
+		g.tr.notify(c)
+	}
+	return nil
+}
+
+func (g *GSON) undo(c Change) error {
+	g.tr.busy = true
+	defer func() { g.tr.busy = false }()
+
+	n := g
+	if c.Path != "" {
+		n = g.Get(c.Path)
+	}
+	switch c.Op {
+	case OpSet:
+		if err := n.Err(); err != nil {
+			return err
+		}
+		n.reset(c.Old)
+	case OpInsert:
+		if err := n.Err(); err != nil {
+			return err
+		}
+		n.Remove()
+	case OpRemove:
+		sels, err := parseSmartPath(c.Path)
+		if err != nil {
+			return err
+		}
+		p := g
+		if len(sels) > 1 {
+			p = g.Get(pathPrefix(c.Path, sels[len(sels)-1]))
+		}
+		if err = p.Err(); err != nil {
+			return err
+		}
+		n = FromBytes(c.Old)
+		switch s := sels[len(sels)-1].(type) {
+		case keySel:
+			p.objInit()
+			if p.v.o == nil {
+				return TypOpErr{Op: "rollback", Typ: p.Type(), Path: p.Path()}
+			}
+			p.v.o.insert(c.idx, string(s), n)
+		case indexSel:
+			p.listInit()
+			if p.v.l == nil {
+				return TypOpErr{Op: "rollback", Typ: p.Type(), Path: p.Path()}
+			}
+			p.v.l.Insert(int(s), n)
+		}
+		p.update(true)
+	}
+	return nil
+}
+
+// pathPrefix 去掉path中最后一级sel，得到父节点路径
+func pathPrefix(path string, sel interface{}) string {
+	var last string
+	switch s := sel.(type) {
+	case keySel:
+		last = joinKey("", string(s))
+	case indexSel:
+		last = fmt.Sprintf("[%v]", int(s))
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(path, last), ".")
+}
+
+func (tr *tracker) record(c Change) {
+	tr.log = append(tr.log, c)
+	tr.notify(c)
+}
+
+func (tr *tracker) notify(c Change) {
+	for _, s := range append([]*subscriber(nil), tr.subs...) {
+		if pathHas(c.Path, s.prefix) || pathHas(s.prefix, c.Path) {
+			s.f(c)
+		}
+	}
+}
+
+// pathHas 判断path是否为prefix或其子节点的路径
+func pathHas(path, prefix string) bool {
+	if prefix == "" || path == prefix {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	c := path[len(prefix)]
+	return c == '.' || c == '['
+}
+
+// tracked 返回g所在的开启了跟踪的节点，不存在的节点沿期望的父节点查找
+func (g *GSON) tracked() *GSON {
+	for n := g; n != nil; {
+		if n.tr != nil {
+			return n
+		}
+		if n.v.miss != nil {
+			n = n.v.miss.p
+		} else {
+			n = n.p
+		}
+	}
+	return nil
+}
+
+// relPath 返回g相对于root的路径
+func relPath(root, g *GSON) string {
+	p := g.Path()
+	if root.p == nil {
+		return p
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(p, root.Path()), ".")
+}
+
+// rawOf 返回g当前值的副本
+func rawOf(g *GSON) json.RawMessage {
+	b, err := g.MarshalJSON()
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	return append(json.RawMessage(nil), b...)
+}
+
+// change 执行对g的修改f，g所在文档开启了跟踪时记录变更
+func (g *GSON) change(f func()) {
+	root := g.tracked()
+	if root == nil || root.tr.busy {
+		f()
+		return
+	}
+	root.tr.busy = true
+	defer func() { root.tr.busy = false }()
+
+	if g.v.miss == nil {
+		old := rawOf(g)
+		f()
+		root.tr.busy = false
+		root.tr.record(Change{Op: OpSet, Path: relPath(root, g), Old: old, New: rawOf(g)})
+		return
+	}
+
+	s := g // 最外层被隐式插入的节点
+	for s.v.miss.p.v.miss != nil {
+		s = s.v.miss.p
+	}
+	f()
+	root.tr.busy = false
+	if s.p == nil { // 未插入
+		return
+	}
+	root.tr.record(Change{Op: OpInsert, Path: relPath(root, s), New: rawOf(s)})
+}
+
+// keyIndex 返回c在对象g中的位置，g不是对象时返回-1
+func (g *GSON) keyIndex(c *GSON) int {
+	if g.v.o == nil {
+		return -1
+	}
+	for i, k := range g.v.o.ks {
+		if g.v.o.mp[k] == c {
+			return i
+		}
+	}
+	return -1
+}
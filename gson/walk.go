@@ -0,0 +1,127 @@
+package gson
+
+import (
+	"fmt"
+)
+
+type WalkAction int
+
+const (
+	WalkContinue WalkAction = iota // 继续遍历子节点
+	WalkSkip                       // 不进入当前节点的子节点
+	WalkDelete                     // 删除当前节点，根节点无法删除
+	WalkStop                       // 结束遍历
+)
+
+/*
+Walk 深度优先(先序)遍历g及其所有子节点，包括内嵌json字符串中的节点。
+path为相对g的路径(Get语法)，g本身为""。
+f中可直接调用node.Set替换节点，之后继续遍历替换后的内容。例如删除所有password字段：
+
+	g.Walk(func(path string, node *gson.GSON) gson.WalkAction {
+		if strings.HasSuffix(path, "password") {
+			return gson.WalkDelete
+		}
+		return gson.WalkContinue
+	})
+*/
+func (g *GSON) Walk(f func(path string, node *GSON) WalkAction) {
+	g.walk("", f)
+}
+
+// walk 返回false表示结束遍历
+func (g *GSON) walk(path string, f func(string, *GSON) WalkAction) bool {
+	switch f(path, g) {
+	case WalkSkip:
+		return true
+	case WalkDelete:
+		g.Remove()
+		return true
+	case WalkStop:
+		return false
+	}
+	if g.e != nil {
+		return true
+	}
+	switch g.Type() {
+	case TypObject, TypList, TypString:
+	default:
+		return true
+	}
+
+	g.objInit()
+	if o := g.v.o; o != nil {
+		o.complete()
+		ks := append([]string(nil), o.ks...)
+		for _, k := range ks {
+			c, ok := o.mp[k]
+			if ok && !c.walk(joinKey(path, k), f) {
+				return false
+			}
+		}
+		return true
+	}
+
+	els := g.elems()
+	if els == nil {
+		return true
+	}
+	els = append([]*GSON(nil), els...)
+	i := 0 // 删除前面的元素后，下标随之变化
+	for _, c := range els {
+		if !c.walk(fmt.Sprintf("%v[%v]", path, i), f) {
+			return false
+		}
+		if c.p != nil {
+			i++
+		}
+	}
+	return true
+}
+
+// Map 将列表的每个元素替换为f的返回值，f可直接返回node表示不变
+func (g *GSON) Map(f func(i int, node *GSON) interface{}) error {
+	els, err := g.listElems("map")
+	if err != nil {
+		return err
+	}
+	for i, c := range els {
+		v := f(i, c)
+		if v == c {
+			continue
+		}
+		if err = c.Set(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filter 只保留f返回true的列表元素
+func (g *GSON) Filter(f func(i int, node *GSON) bool) error {
+	els, err := g.listElems("filter")
+	if err != nil {
+		return err
+	}
+	for i, c := range els {
+		if !f(i, c) {
+			c.Remove()
+		}
+	}
+	return nil
+}
+
+// listElems 返回列表元素的副本，g不是列表时返回错误
+func (g *GSON) listElems(op string) ([]*GSON, error) {
+	if g.e != nil {
+		return nil, g.e
+	}
+	if g.v.miss != nil {
+		return nil, g.check(TypList)
+	}
+	els := g.elems()
+	if g.v.l == nil {
+		return nil, TypOpErr{Op: op, Typ: g.Type(), Path: g.Path()}
+	}
+	return append([]*GSON(nil), els...), nil
+}
@@ -0,0 +1,1297 @@
+package gson
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+/*
+FromYAML 解析YAML 1.2的常用子集，key保持原始顺序。支持：
+块映射和块序列、流式[...]和{...}、plain, 单引号, 双引号和多行标量、
+块标量(| >及其-, +修饰)、注释、锚点和别名(包括<<合并)、!!str标签。
+标量按core schema解析：null, ~, true, false, 整数(含0x, 0o)和浮点数。
+不支持复杂key(? ...)、多文档和.inf, .nan等json无法表示的值。
+*/
+func FromYAML(b []byte) *GSON {
+	p := &yamlParser{b: b, anchors: make(map[string]*node)}
+	n, err := p.parse()
+	if err != nil {
+		return &GSON{e: err}
+	}
+	if p.aliases > 0 && expandedSize(n, make(map[*node]int)) > maxAliasExpansion {
+		return &GSON{e: FormatErr{Format: "yaml", Line: 1, Col: 1, Msg: "alias expansion exceeds limit"}}
+	}
+	return n.gson()
+}
+
+// maxAliasExpansion 别名展开后的节点数上限，防止"billion laughs"
+const maxAliasExpansion = 10000000
+
+func expandedSize(n *node, memo map[*node]int) int {
+	if s, ok := memo[n]; ok {
+		return s
+	}
+	s := 1
+	for _, c := range n.els {
+		s += expandedSize(c, memo)
+	}
+	for _, k := range n.ks {
+		s += expandedSize(n.mp[k], memo)
+	}
+	if s > maxAliasExpansion {
+		s = maxAliasExpansion + 1
+	}
+	memo[n] = s
+	return s
+}
+
+type yamlParser struct {
+	b       []byte
+	i       int
+	depth   int
+	anchors map[string]*node
+	aliases int
+}
+
+func (p *yamlParser) errorf(msg string, a ...interface{}) error {
+	return formatErr("yaml", p.b, p.i, msg, a...)
+}
+
+func (p *yamlParser) nest() error {
+	p.depth++
+	if p.depth > maxNesting {
+		return p.errorf("exceeded max depth")
+	}
+	return nil
+}
+
+func (p *yamlParser) lineStart(i int) int {
+	return bytes.LastIndexByte(p.b[:i], '\n') + 1
+}
+
+func (p *yamlParser) col() int {
+	return p.i - p.lineStart(p.i)
+}
+
+// atIndent 当前位置之前只有行首缩进
+func (p *yamlParser) atIndent() bool {
+	for j := p.lineStart(p.i); j < p.i; j++ {
+		if p.b[j] != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *yamlParser) skipSpaces() {
+	for p.i < len(p.b) && (p.b[p.i] == ' ' || p.b[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// restEmpty 当前行剩余部分为空或注释
+func (p *yamlParser) restEmpty() bool {
+	return p.i >= len(p.b) || p.b[p.i] == '\n' || p.b[p.i] == '\r' || p.b[p.i] == '#'
+}
+
+func (p *yamlParser) nextLine() {
+	if j := bytes.IndexByte(p.b[p.i:], '\n'); j >= 0 {
+		p.i += j + 1
+	} else {
+		p.i = len(p.b)
+	}
+}
+
+// skipBlank 跳过当前行剩余部分、空行和注释行，停在下一行内容的第一个字符，
+// 返回其缩进；没有更多内容时返回-1
+func (p *yamlParser) skipBlank() (int, error) {
+	if !p.atIndent() {
+		p.skipSpaces()
+		if !p.restEmpty() {
+			return 0, p.errorf("unexpected %q", p.b[p.i])
+		}
+		p.nextLine()
+	}
+	for p.i < len(p.b) {
+		for p.i < len(p.b) && p.b[p.i] == ' ' {
+			p.i++
+		}
+		if p.i < len(p.b) && p.b[p.i] == '\t' {
+			p.skipSpaces()
+			if !p.restEmpty() {
+				return 0, p.errorf("tabs are not allowed for indentation")
+			}
+		}
+		if p.restEmpty() {
+			p.nextLine()
+			continue
+		}
+		return p.col(), nil
+	}
+	return -1, nil
+}
+
+// atMarker 当前位置为文档标记"---"或"..."
+func (p *yamlParser) atMarker() bool {
+	if p.col() != 0 {
+		return false
+	}
+	rest := p.b[p.i:]
+	if !bytes.HasPrefix(rest, []byte("---")) && !bytes.HasPrefix(rest, []byte("...")) {
+		return false
+	}
+	return len(rest) == 3 || isYAMLSpace(rest[3])
+}
+
+func isYAMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (p *yamlParser) parse() (*node, error) {
+	if bytes.HasPrefix(p.b, []byte("\xef\xbb\xbf")) {
+		p.i = 3
+	}
+	ind, err := p.skipBlank()
+	for err == nil && ind == 0 && p.b[p.i] == '%' { // 指令
+		p.nextLine()
+		ind, err = p.skipBlank()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var n *node
+	if ind == 0 && p.atMarker() && p.b[p.i] == '-' {
+		p.i += 3
+		p.skipSpaces()
+		if !p.restEmpty() {
+			if n, err = p.value(-1, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if n == nil {
+		if ind, err = p.skipBlank(); err != nil {
+			return nil, err
+		}
+		if ind < 0 || p.atMarker() {
+			n = &node{t: TypNull, raw: "null"}
+		} else if n, err = p.block(ind, -1); err != nil {
+			return nil, err
+		}
+	}
+
+	if ind, err = p.skipBlank(); err != nil {
+		return nil, err
+	}
+	if ind >= 0 && p.atMarker() && p.b[p.i] == '.' {
+		p.i += 3
+		if ind, err = p.skipBlank(); err != nil {
+			return nil, err
+		}
+	}
+	if ind >= 0 {
+		if p.atMarker() {
+			return nil, p.errorf("multiple documents are not supported")
+		}
+		return nil, p.errorf("unexpected %q, bad indentation?", p.b[p.i])
+	}
+	return n, nil
+}
+
+// block 解析从当前位置开始的块节点，col为当前列，
+// parent为父节点的缩进，多行标量的后续行须比parent缩进更多
+func (p *yamlParser) block(col, parent int) (*node, error) {
+	if p.isSeqEntry() {
+		return p.seq(col)
+	}
+	if p.isMapEntry() {
+		return p.mapping(col)
+	}
+	return p.value(parent, false)
+}
+
+func (p *yamlParser) isSeqEntry() bool {
+	return p.i < len(p.b) && p.b[p.i] == '-' && (p.i+1 >= len(p.b) || isYAMLSpace(p.b[p.i+1]))
+}
+
+// isMapEntry 当前行是否为"key: value"
+func (p *yamlParser) isMapEntry() bool {
+	save := p.i
+	defer func() { p.i = save }()
+	_, _, err := p.mapKey()
+	return err == nil
+}
+
+// mapKey 解析key及其后的':'
+func (p *yamlParser) mapKey() (key string, quoted bool, err error) {
+	if p.i >= len(p.b) {
+		return "", false, p.errorf("unexpected end of input")
+	}
+	switch c := p.b[p.i]; c {
+	case '"', '\'':
+		start := p.i
+		if c == '"' {
+			key, err = p.doubleQuoted()
+		} else {
+			key, err = p.singleQuoted()
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if bytes.IndexByte(p.b[start:p.i], '\n') >= 0 {
+			return "", false, p.errorf("multi-line keys are not allowed")
+		}
+		quoted = true
+		p.skipSpaces()
+	case '[', '{', '#', '&', '*', '!', '|', '>', '%', '@', '`':
+		return "", false, p.errorf("unexpected %q looking for a mapping key", c)
+	case '?':
+		if p.i+1 >= len(p.b) || isYAMLSpace(p.b[p.i+1]) {
+			return "", false, p.errorf("complex keys are not supported")
+		}
+		fallthrough
+	default:
+		start := p.i
+		for ; p.i < len(p.b); p.i++ {
+			c := p.b[p.i]
+			if c == '\n' || c == '\r' {
+				break
+			}
+			if c == '#' && p.i > start && (p.b[p.i-1] == ' ' || p.b[p.i-1] == '\t') {
+				break
+			}
+			if c == ':' && (p.i+1 >= len(p.b) || isYAMLSpace(p.b[p.i+1])) {
+				break
+			}
+		}
+		key = strings.TrimRight(string(p.b[start:p.i]), " \t")
+	}
+	if p.i >= len(p.b) || p.b[p.i] != ':' || (p.i+1 < len(p.b) && !isYAMLSpace(p.b[p.i+1])) {
+		return "", false, p.errorf("expected ':' after mapping key")
+	}
+	p.i++
+	return key, quoted, nil
+}
+
+func (p *yamlParser) mapping(col int) (*node, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	n := newNode(TypObject)
+	var merges []*node
+	for {
+		start := p.i
+		k, quoted, err := p.mapKey()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		v, err := p.value(col, true)
+		if err != nil {
+			return nil, err
+		}
+		if k == "<<" && !quoted {
+			merges = append(merges, v)
+		} else if _, dup := n.mp[k]; dup {
+			p.i = start
+			return nil, p.errorf("duplicate key %q", k)
+		} else {
+			n.set(k, v)
+		}
+
+		ind, err := p.skipBlank()
+		if err != nil {
+			return nil, err
+		}
+		if ind < col || p.atMarker() {
+			break
+		}
+		if ind > col {
+			return nil, p.errorf("bad indentation of a mapping entry")
+		}
+		if !p.isMapEntry() {
+			if p.isSeqEntry() {
+				return nil, p.errorf("unexpected sequence entry in a mapping")
+			}
+			_, _, err = p.mapKey()
+			return nil, err
+		}
+	}
+
+	// 显式定义的key优先，先出现的合并源优先
+	for _, m := range merges {
+		srcs := []*node{m}
+		if m.t == TypList {
+			srcs = m.els
+		}
+		for _, src := range srcs {
+			if src.t != TypObject {
+				return nil, p.errorf("merge key '<<' requires a mapping or a sequence of mappings")
+			}
+			for _, k := range src.ks {
+				if _, ok := n.mp[k]; !ok {
+					n.set(k, src.mp[k])
+				}
+			}
+		}
+	}
+	return n, nil
+}
+
+func (p *yamlParser) seq(col int) (*node, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	n := newNode(TypList)
+	for {
+		p.i++ // '-'
+		p.skipSpaces()
+		var v *node
+		var err error
+		if p.restEmpty() {
+			ind, err := p.skipBlank()
+			if err != nil {
+				return nil, err
+			}
+			if ind > col && !p.atMarker() {
+				v, err = p.block(ind, col)
+			} else {
+				v = &node{t: TypNull, raw: "null"}
+			}
+		} else {
+			v, err = p.block(p.col(), col)
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.els = append(n.els, v)
+
+		ind, err := p.skipBlank()
+		if err != nil {
+			return nil, err
+		}
+		if ind < col || p.atMarker() {
+			break
+		}
+		if ind > col {
+			return nil, p.errorf("bad indentation of a sequence entry")
+		}
+		if !p.isSeqEntry() {
+			break // 与key同缩进的序列结束
+		}
+	}
+	return n, nil
+}
+
+// value 解析标量、流式集合、别名或下一行开始的块节点，可带锚点和标签。
+// inMap为true时，允许下一行以与key相同的缩进开始一个序列
+func (p *yamlParser) value(parent int, inMap bool) (*node, error) {
+	var anchor, tag string
+	for p.i < len(p.b) && (p.b[p.i] == '&' || p.b[p.i] == '!') {
+		c := p.b[p.i]
+		start := p.i + 1
+		for p.i < len(p.b) && !isYAMLSpace(p.b[p.i]) {
+			p.i++
+		}
+		if c == '&' {
+			anchor = string(p.b[start:p.i])
+			if anchor == "" {
+				return nil, p.errorf("empty anchor name")
+			}
+		} else {
+			tag = string(p.b[start:p.i])
+		}
+		p.skipSpaces()
+	}
+
+	var n *node
+	var err error
+	if p.restEmpty() {
+		ind, err := p.skipBlank()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ind > parent && !p.atMarker():
+			n, err = p.block(ind, parent)
+		case inMap && ind == parent && p.isSeqEntry():
+			n, err = p.seq(ind)
+		default:
+			n = &node{t: TypNull, raw: "null"}
+			if tag == "!str" {
+				n = &node{t: TypString}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		switch p.b[p.i] {
+		case '|', '>':
+			n, err = p.blockScalar(parent)
+		case '*':
+			n, err = p.alias()
+		case '[', '{':
+			n, err = p.flow()
+		case '"', '\'':
+			n, err = p.quoted()
+		default:
+			start := p.i
+			var s string
+			if s, err = p.plain(parent); err == nil {
+				if tag == "!str" {
+					n = &node{t: TypString, s: s}
+				} else if n, err = resolvePlain(s); err != nil {
+					p.i = start
+					err = p.errorf("%v", err)
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tag == "!str" && n.t != TypString && n.t != TypObject && n.t != TypList {
+		n = &node{t: TypString, s: n.raw}
+	}
+	if anchor != "" {
+		p.anchors[anchor] = n
+	}
+	return n, nil
+}
+
+func (p *yamlParser) alias() (*node, error) {
+	start := p.i
+	p.i++ // '*'
+	for p.i < len(p.b) && !isYAMLSpace(p.b[p.i]) && strings.IndexByte(",[]{}", p.b[p.i]) < 0 {
+		p.i++
+	}
+	name := string(p.b[start+1 : p.i])
+	n, ok := p.anchors[name]
+	if !ok {
+		p.i = start
+		return nil, p.errorf("unknown anchor %q", name)
+	}
+	p.aliases++
+	return n, nil
+}
+
+func (p *yamlParser) quoted() (*node, error) {
+	var s string
+	var err error
+	if p.b[p.i] == '"' {
+		s, err = p.doubleQuoted()
+	} else {
+		s, err = p.singleQuoted()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &node{t: TypString, s: s}, nil
+}
+
+// plain 解析块上下文中的plain标量，后续行缩进须大于parent
+func (p *yamlParser) plain(parent int) (string, error) {
+	if c := p.b[p.i]; strings.IndexByte(",]}#@`", c) >= 0 {
+		return "", p.errorf("unexpected %q", c)
+	}
+	buf := []byte(nil)
+	line, err := p.plainLine()
+	if err != nil {
+		return "", err
+	}
+	buf = append(buf, line...)
+	for {
+		p.skipSpaces()
+		if p.i < len(p.b) && p.b[p.i] == '#' {
+			break // 注释结束标量
+		}
+		save := p.i
+		breaks := 0
+		cont := false
+		for p.i < len(p.b) {
+			p.nextLine()
+			for p.i < len(p.b) && p.b[p.i] == ' ' {
+				p.i++
+			}
+			if p.i < len(p.b) && (p.b[p.i] == '\n' || p.b[p.i] == '\r') {
+				breaks++
+				continue
+			}
+			cont = p.i < len(p.b) && p.col() > parent && p.b[p.i] != '#' && !p.atMarker()
+			break
+		}
+		if !cont {
+			p.i = save
+			break
+		}
+		if breaks == 0 {
+			buf = append(buf, ' ')
+		}
+		for ; breaks > 0; breaks-- {
+			buf = append(buf, '\n')
+		}
+		line, err = p.plainLine()
+		if err != nil {
+			return "", err
+		}
+		buf = append(buf, line...)
+	}
+	return string(buf), nil
+}
+
+// plainLine 读取plain标量在当前行的部分
+func (p *yamlParser) plainLine() ([]byte, error) {
+	start := p.i
+	for ; p.i < len(p.b); p.i++ {
+		c := p.b[p.i]
+		if c == '\n' || c == '\r' {
+			break
+		}
+		if c == '#' && p.i > start && (p.b[p.i-1] == ' ' || p.b[p.i-1] == '\t') {
+			break
+		}
+		if c == ':' && (p.i+1 >= len(p.b) || isYAMLSpace(p.b[p.i+1])) {
+			return nil, p.errorf("mapping values are not allowed in this context")
+		}
+	}
+	return bytes.TrimRight(p.b[start:p.i], " \t"), nil
+}
+
+// fold 处理引号标量中的换行：单个换行变为空格，n个空行变为n个换行
+func (p *yamlParser) fold(buf []byte) []byte {
+	buf = bytes.TrimRight(buf, " \t")
+	breaks := 0
+	for p.i < len(p.b) && (p.b[p.i] == '\n' || p.b[p.i] == '\r') {
+		if p.b[p.i] == '\n' {
+			breaks++
+		}
+		p.i++
+		p.skipSpaces()
+	}
+	if breaks <= 1 {
+		return append(buf, ' ')
+	}
+	for ; breaks > 1; breaks-- {
+		buf = append(buf, '\n')
+	}
+	return buf
+}
+
+func (p *yamlParser) singleQuoted() (string, error) {
+	start := p.i
+	p.i++
+	var buf []byte
+	for p.i < len(p.b) {
+		switch c := p.b[p.i]; c {
+		case '\'':
+			if p.i+1 < len(p.b) && p.b[p.i+1] == '\'' {
+				buf = append(buf, '\'')
+				p.i += 2
+				continue
+			}
+			p.i++
+			return string(buf), nil
+		case '\n', '\r':
+			buf = p.fold(buf)
+		default:
+			buf = append(buf, c)
+			p.i++
+		}
+	}
+	p.i = start
+	return "", p.errorf("unterminated quoted string")
+}
+
+func (p *yamlParser) doubleQuoted() (string, error) {
+	start := p.i
+	p.i++
+	var buf []byte
+	for p.i < len(p.b) {
+		c := p.b[p.i]
+		switch c {
+		case '"':
+			p.i++
+			return string(buf), nil
+		case '\n', '\r':
+			buf = p.fold(buf)
+			continue
+		case '\\':
+		default:
+			buf = append(buf, c)
+			p.i++
+			continue
+		}
+
+		p.i++ // '\\'
+		if p.i >= len(p.b) {
+			break
+		}
+		e := p.b[p.i]
+		p.i++
+		switch e {
+		case '0':
+			buf = append(buf, 0)
+		case 'a':
+			buf = append(buf, '\a')
+		case 'b':
+			buf = append(buf, '\b')
+		case 't', '\t':
+			buf = append(buf, '\t')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'v':
+			buf = append(buf, '\v')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'r':
+			buf = append(buf, '\r')
+		case 'e':
+			buf = append(buf, 0x1b)
+		case ' ', '"', '/', '\\':
+			buf = append(buf, e)
+		case 'N':
+			buf = utf8.AppendRune(buf, '\u0085')
+		case '_':
+			buf = utf8.AppendRune(buf, '\u00A0')
+		case 'L':
+			buf = utf8.AppendRune(buf, '\u2028')
+		case 'P':
+			buf = utf8.AppendRune(buf, '\u2029')
+		case 'x', 'u', 'U':
+			n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+			if p.i+n > len(p.b) {
+				p.i -= 2
+				return "", p.errorf("invalid escape sequence")
+			}
+			v, err := strconv.ParseUint(string(p.b[p.i:p.i+n]), 16, 32)
+			if err != nil {
+				p.i -= 2
+				return "", p.errorf("invalid escape sequence")
+			}
+			p.i += n
+			r := rune(v)
+			if e == 'u' && utf16.IsSurrogate(r) && bytes.HasPrefix(p.b[p.i:], []byte(`\u`)) && p.i+6 <= len(p.b) {
+				if lo, err := strconv.ParseUint(string(p.b[p.i+2:p.i+6]), 16, 32); err == nil {
+					r = utf16.DecodeRune(r, rune(lo))
+					p.i += 6
+				}
+			}
+			buf = utf8.AppendRune(buf, r)
+		case '\n', '\r': // 转义的换行：直接连接下一行
+			if e == '\r' && p.i < len(p.b) && p.b[p.i] == '\n' {
+				p.i++
+			}
+			p.skipSpaces()
+		default:
+			p.i -= 2
+			return "", p.errorf("invalid escape sequence")
+		}
+	}
+	p.i = start
+	return "", p.errorf("unterminated quoted string")
+}
+
+// blockScalar 解析| >块标量
+func (p *yamlParser) blockScalar(parent int) (*node, error) {
+	literal := p.b[p.i] == '|'
+	p.i++
+	chomp := byte(0) // 0: clip, '-': strip, '+': keep
+	explicit := 0
+	for p.i < len(p.b) {
+		c := p.b[p.i]
+		if (c == '-' || c == '+') && chomp == 0 {
+			chomp = c
+		} else if '1' <= c && c <= '9' && explicit == 0 {
+			explicit = int(c - '0')
+		} else {
+			break
+		}
+		p.i++
+	}
+	p.skipSpaces()
+	if !p.restEmpty() {
+		return nil, p.errorf("unexpected %q in block scalar header", p.b[p.i])
+	}
+	p.nextLine()
+
+	base := parent
+	if base < 0 {
+		base = 0
+	}
+	indent := base + explicit
+	if explicit == 0 {
+		indent = -1
+		for j := p.i; j < len(p.b); {
+			k := j
+			for k < len(p.b) && p.b[k] == ' ' {
+				k++
+			}
+			if k < len(p.b) && p.b[k] != '\n' && p.b[k] != '\r' {
+				indent = k - j
+				break
+			}
+			if e := bytes.IndexByte(p.b[k:], '\n'); e >= 0 {
+				j = k + e + 1
+			} else {
+				break
+			}
+		}
+		if indent <= parent {
+			indent = -1
+		}
+	}
+
+	var lines []string // 空行为""
+	for p.i < len(p.b) {
+		ls := p.i
+		end := len(p.b)
+		if e := bytes.IndexByte(p.b[ls:], '\n'); e >= 0 {
+			end = ls + e
+		}
+		line := bytes.TrimSuffix(p.b[ls:end], []byte{'\r'})
+		sp := 0
+		for sp < len(line) && line[sp] == ' ' {
+			sp++
+		}
+		if sp == len(line) { // 空行
+			if indent >= 0 && sp > indent {
+				lines = append(lines, string(line[indent:]))
+			} else {
+				lines = append(lines, "")
+			}
+		} else if indent < 0 || sp < indent || (indent == 0 && p.atMarker()) {
+			break
+		} else {
+			lines = append(lines, string(line[indent:]))
+		}
+		p.i = end
+		if p.i < len(p.b) {
+			p.i++
+		}
+	}
+	// 尾部空行
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	content := lines[:len(lines)-trailing]
+
+	var sb strings.Builder
+	if literal {
+		sb.WriteString(strings.Join(content, "\n"))
+	} else {
+		empty := 0
+		prevNormal := false
+		for i, line := range content {
+			if line == "" {
+				empty++
+				continue
+			}
+			more := line[0] == ' ' || line[0] == '\t'
+			switch {
+			case i == empty: // 开头的空行
+				sb.WriteString(strings.Repeat("\n", empty))
+			case prevNormal && !more && empty == 0:
+				sb.WriteByte(' ')
+			case prevNormal && !more:
+				sb.WriteString(strings.Repeat("\n", empty))
+			default:
+				sb.WriteString(strings.Repeat("\n", empty+1))
+			}
+			sb.WriteString(line)
+			empty = 0
+			prevNormal = !more
+		}
+	}
+
+	switch chomp {
+	case 0:
+		if len(content) > 0 {
+			sb.WriteByte('\n')
+		}
+	case '+':
+		if len(content) > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(strings.Repeat("\n", trailing))
+	}
+	return &node{t: TypString, s: sb.String()}, nil
+}
+
+// - - - - - - - - - - flow - - - - - - - - - -
+
+// flowSkip 跳过流式集合中的空白、换行和注释
+func (p *yamlParser) flowSkip() error {
+	for p.i < len(p.b) {
+		switch p.b[p.i] {
+		case ' ', '\t', '\n', '\r':
+			p.i++
+		case '#':
+			p.nextLine()
+		default:
+			return nil
+		}
+	}
+	return p.errorf("unterminated flow collection")
+}
+
+func (p *yamlParser) flow() (*node, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	if p.b[p.i] == '[' {
+		p.i++
+		n := newNode(TypList)
+		for {
+			if err := p.flowSkip(); err != nil {
+				return nil, err
+			}
+			if p.b[p.i] == ']' {
+				break
+			}
+			v, err := p.flowValue()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.flowSkip(); err != nil {
+				return nil, err
+			}
+			if p.b[p.i] == ':' { // [a: 1] 单个键值对
+				k := v.s
+				if v.t != TypString {
+					k = v.raw
+				}
+				p.i++
+				if err = p.flowSkip(); err != nil {
+					return nil, err
+				}
+				if v, err = p.flowValue(); err != nil {
+					return nil, err
+				}
+				m := newNode(TypObject)
+				m.set(k, v)
+				v = m
+				if err = p.flowSkip(); err != nil {
+					return nil, err
+				}
+			}
+			n.els = append(n.els, v)
+			if p.b[p.i] == ',' {
+				p.i++
+				continue
+			}
+			if p.b[p.i] != ']' {
+				return nil, p.errorf("expected ',' or ']' in flow sequence")
+			}
+			break
+		}
+		p.i++ // ']'
+		return n, nil
+	}
+
+	p.i++ // '{'
+	n := newNode(TypObject)
+	for {
+		if err := p.flowSkip(); err != nil {
+			return nil, err
+		}
+		if p.b[p.i] == '}' {
+			break
+		}
+		start := p.i
+		kn, err := p.flowScalar()
+		if err != nil {
+			return nil, err
+		}
+		k := kn.s
+		if kn.t != TypString {
+			k = kn.raw
+		}
+		if err = p.flowSkip(); err != nil {
+			return nil, err
+		}
+		v := &node{t: TypNull, raw: "null"}
+		if p.b[p.i] == ':' {
+			p.i++
+			if err = p.flowSkip(); err != nil {
+				return nil, err
+			}
+			if p.b[p.i] != ',' && p.b[p.i] != '}' {
+				if v, err = p.flowValue(); err != nil {
+					return nil, err
+				}
+				if err = p.flowSkip(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if _, dup := n.mp[k]; dup {
+			p.i = start
+			return nil, p.errorf("duplicate key %q", k)
+		}
+		n.set(k, v)
+		if p.b[p.i] == ',' {
+			p.i++
+			continue
+		}
+		if p.b[p.i] != '}' {
+			return nil, p.errorf("expected ',' or '}' in flow mapping")
+		}
+		break
+	}
+	p.i++ // '}'
+	return n, nil
+}
+
+func (p *yamlParser) flowValue() (*node, error) {
+	var anchor, tag string
+	for p.b[p.i] == '&' || p.b[p.i] == '!' {
+		c := p.b[p.i]
+		start := p.i + 1
+		for p.i < len(p.b) && !isYAMLSpace(p.b[p.i]) && strings.IndexByte(",[]{}", p.b[p.i]) < 0 {
+			p.i++
+		}
+		if c == '&' {
+			anchor = string(p.b[start:p.i])
+		} else {
+			tag = string(p.b[start:p.i])
+		}
+		if err := p.flowSkip(); err != nil {
+			return nil, err
+		}
+	}
+
+	var n *node
+	var err error
+	switch p.b[p.i] {
+	case '[', '{':
+		n, err = p.flow()
+	case '*':
+		n, err = p.alias()
+	default:
+		if tag == "!str" && p.b[p.i] != '"' && p.b[p.i] != '\'' {
+			var s []byte
+			if s, err = p.flowPlain(); err == nil {
+				n = &node{t: TypString, s: string(s)}
+			}
+		} else {
+			n, err = p.flowScalar()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != "" {
+		p.anchors[anchor] = n
+	}
+	return n, nil
+}
+
+func (p *yamlParser) flowScalar() (*node, error) {
+	switch p.b[p.i] {
+	case '"', '\'':
+		return p.quoted()
+	case ',', ']', '}', '[', '{', '#', '|', '>', '@', '`':
+		return nil, p.errorf("unexpected %q in flow collection", p.b[p.i])
+	}
+	start := p.i
+	s, err := p.flowPlain()
+	if err != nil {
+		return nil, err
+	}
+	n, err := resolvePlain(string(s))
+	if err != nil {
+		p.i = start
+		return nil, p.errorf("%v", err)
+	}
+	return n, nil
+}
+
+func (p *yamlParser) flowPlain() ([]byte, error) {
+	start := p.i
+	for ; p.i < len(p.b); p.i++ {
+		c := p.b[p.i]
+		if c == '\n' || c == '\r' || strings.IndexByte(",[]{}", c) >= 0 {
+			break
+		}
+		if c == '#' && p.i > start && (p.b[p.i-1] == ' ' || p.b[p.i-1] == '\t') {
+			break
+		}
+		if c == ':' && (p.i+1 >= len(p.b) || isYAMLSpace(p.b[p.i+1]) || strings.IndexByte(",[]{}", p.b[p.i+1]) >= 0) {
+			break
+		}
+	}
+	s := bytes.TrimRight(p.b[start:p.i], " \t")
+	if len(s) == 0 {
+		return nil, p.errorf("unexpected %q in flow collection", p.b[p.i])
+	}
+	return s, nil
+}
+
+// - - - - - - - - - - scalars - - - - - - - - - -
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOct   = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHex   = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloat = regexp.MustCompile(`^([-+]?)(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlSpec  = regexp.MustCompile(`^([-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+)
+
+// resolvePlain 按YAML 1.2 core schema解析plain标量
+func resolvePlain(s string) (*node, error) {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return &node{t: TypNull, raw: "null"}, nil
+	case "true", "True", "TRUE":
+		return &node{t: TypBool, raw: "true"}, nil
+	case "false", "False", "FALSE":
+		return &node{t: TypBool, raw: "false"}, nil
+	}
+
+	switch {
+	case yamlInt.MatchString(s):
+		n, _ := new(big.Int).SetString(strings.TrimPrefix(s, "+"), 10)
+		return &node{t: TypNumber, raw: n.String()}, nil
+	case yamlOct.MatchString(s):
+		n, _ := new(big.Int).SetString(s[2:], 8)
+		return &node{t: TypNumber, raw: n.String()}, nil
+	case yamlHex.MatchString(s):
+		n, _ := new(big.Int).SetString(s[2:], 16)
+		return &node{t: TypNumber, raw: n.String()}, nil
+	case yamlFloat.MatchString(s):
+		return &node{t: TypNumber, raw: jsonNumber(s)}, nil
+	case yamlSpec.MatchString(s):
+		return nil, errors.New(s + " cannot be represented in json")
+	}
+	return &node{t: TypString, s: s}, nil
+}
+
+// jsonNumber 将[-+]?(\.d+|d+(\.d*)?)(e[-+]?d+)?形式的数字转为json数字
+func jsonNumber(s string) string {
+	var sb strings.Builder
+	if s[0] == '-' {
+		sb.WriteByte('-')
+	}
+	s = strings.TrimLeft(s, "+-")
+	mant, exp := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mant, exp = s[:i], s[i:]
+	}
+	ip, fp, _ := strings.Cut(mant, ".")
+	ip = strings.TrimLeft(ip, "0")
+	if ip == "" {
+		ip = "0"
+	}
+	sb.WriteString(ip)
+	if fp != "" {
+		sb.WriteByte('.')
+		sb.WriteString(fp)
+	}
+	sb.WriteString(exp)
+	return sb.String()
+}
+
+// - - - - - - - - - - emitter - - - - - - - - - -
+
+// ToYAML 以两个空格缩进输出YAML块格式，多行字符串使用|块标量。注释无法保留。
+func (g *GSON) ToYAML() ([]byte, error) {
+	if g.e != nil {
+		return nil, g.e
+	}
+	buf := &bytes.Buffer{}
+	if err := writeYAML(buf, g, 0, false); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeYAML 输出以indent缩进的块节点，inline为true时第一行不输出缩进
+func writeYAML(buf *bytes.Buffer, g *GSON, indent int, inline bool) error {
+	pad := func() {
+		if inline {
+			inline = false
+			return
+		}
+		buf.WriteString(strings.Repeat(" ", indent))
+	}
+
+	switch g.Type() {
+	case TypObject:
+		ks, err := g.keys()
+		if err != nil {
+			return err
+		}
+		if len(ks) == 0 {
+			buf.WriteString("{}\n")
+			return nil
+		}
+		for _, k := range ks {
+			pad()
+			writeYAMLString(buf, k, -1)
+			buf.WriteByte(':')
+			if err := writeYAMLChild(buf, g.ObjIdx(k), indent+2, true); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case TypList:
+		els, err := g.items()
+		if err != nil {
+			return err
+		}
+		if len(els) == 0 {
+			buf.WriteString("[]\n")
+			return nil
+		}
+		for _, c := range els {
+			pad()
+			buf.WriteByte('-')
+			if err := writeYAMLChild(buf, c, indent+2, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return writeYAMLScalar(buf, g, indent)
+}
+
+// writeYAMLChild 输出key(inMap)或'-'之后的值，
+// 对象的值从下一行开始，列表元素从'-'之后开始
+func writeYAMLChild(buf *bytes.Buffer, c *GSON, indent int, inMap bool) error {
+	n := 0
+	switch c.Type() {
+	case TypObject:
+		ks, err := c.keys()
+		if err != nil {
+			return err
+		}
+		n = len(ks)
+	case TypList:
+		els, err := c.items()
+		if err != nil {
+			return err
+		}
+		n = len(els)
+	}
+	if n > 0 {
+		if inMap {
+			buf.WriteByte('\n')
+			return writeYAML(buf, c, indent, false)
+		}
+		buf.WriteByte(' ')
+		return writeYAML(buf, c, indent, true)
+	}
+	buf.WriteByte(' ')
+	return writeYAMLScalar(buf, c, indent)
+}
+
+func writeYAMLScalar(buf *bytes.Buffer, g *GSON, indent int) error {
+	switch g.Type() {
+	case TypObject:
+		buf.WriteString("{}\n")
+	case TypList:
+		buf.WriteString("[]\n")
+	case TypString:
+		writeYAMLString(buf, g.Str(), indent)
+		buf.WriteByte('\n')
+	case TypNumber, TypBool, TypNull:
+		buf.WriteString(strings.TrimSpace(g.Str()))
+		buf.WriteByte('\n')
+	default:
+		return TypOpErr{Op: "yaml", Typ: g.Type(), Path: g.Path()}
+	}
+	return nil
+}
+
+// writeYAMLString 输出字符串：plain, 双引号, 或indent >= 0时多行使用块标量
+func writeYAMLString(buf *bytes.Buffer, s string, indent int) {
+	if yamlPlainSafe(s) {
+		buf.WriteString(s)
+		return
+	}
+	if indent >= 0 && yamlLiteralSafe(s) {
+		body := strings.TrimRight(s, "\n")
+		switch len(s) - len(body) {
+		case 0:
+			buf.WriteString("|-")
+		case 1:
+			buf.WriteString("|")
+		default:
+			buf.WriteString("|+")
+		}
+		lines := strings.Split(s, "\n")
+		if len(s) > len(body) {
+			lines = lines[:len(lines)-1]
+		}
+		for _, line := range lines {
+			buf.WriteByte('\n')
+			if line != "" {
+				buf.WriteString(strings.Repeat(" ", indent))
+				buf.WriteString(line)
+			}
+		}
+		return
+	}
+	buf.WriteString(strconv.Quote(s))
+}
+
+func yamlPlainSafe(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) || strings.HasPrefix(s, "---") || strings.HasPrefix(s, "...") {
+		return false
+	}
+	switch c := s[0]; c {
+	case ',', '[', ']', '{', '}', '#', '&', '*', '!', '|', '>', '\'', '"', '%', '@', '`':
+		return false
+	case '-', '?', ':':
+		if len(s) == 1 || isYAMLSpace(s[1]) {
+			return false
+		}
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.Contains(s, ":\t") || strings.Contains(s, "\t#") {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	n, err := resolvePlain(s)
+	return err == nil && n.t == TypString
+}
+
+func yamlLiteralSafe(s string) bool {
+	if !strings.Contains(s, "\n") {
+		return false
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" && strings.TrimLeft(line, " ") == "" {
+			return false
+		}
+		for _, r := range line {
+			if r != '\t' && !unicode.IsPrint(r) {
+				return false
+			}
+		}
+	}
+	first := strings.TrimLeft(s, "\n")
+	return first != "" && first[0] != ' ' && first[0] != '\t'
+}
@@ -0,0 +1,616 @@
+// Package json5 将JSON5流转为JSON，供jcon和gson共用。
+// 在注释之外，还支持末尾多余的逗号、单引号字符串、
+// 不带引号的key、十六进制数、.5和5.形式的小数、正号及JSON5的转义字符。
+// 输出保留原有的换行，转换后的行号与原文一致。
+package json5
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type SyntaxError struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (se *SyntaxError) Error() string {
+	return fmt.Sprintf("json5: line %v col %v: %v", se.Line, se.Col, se.Msg)
+}
+
+// maxDepth 嵌套层数限制，同encoding/json
+const maxDepth = 10000
+
+type state int
+
+const (
+	stValue      state = iota // 期待一个值
+	stKey                     // 期待key或'}'
+	stColon                   // 期待':'
+	stAfterValue              // 期待','或结束符
+	stEnd                     // 顶层值已结束
+	stDone
+)
+
+type json5Reader struct {
+	br  *bufio.Reader
+	out bytes.Buffer
+	err error
+
+	line, col         int
+	prevLine, prevCol int // UnreadRune时恢复
+	tokLine, tokCol   int // 当前token的起始位置
+
+	state      state
+	stack      []byte // '{'或'['
+	allowClose bool   // stValue时可以直接遇到']'：空数组或末尾逗号
+	comma      int    // 未确定的逗号在out中的位置，后面是'}'或']'时删除；没有时为-1
+}
+
+// NewReader 将JSON5流转为JSON，语法错误时Read返回*SyntaxError
+func NewReader(r io.Reader) io.Reader {
+	return &json5Reader{br: bufio.NewReader(r), line: 1, col: 1, comma: -1}
+}
+
+// Convert 将JSON5转为JSON
+func Convert(p []byte) ([]byte, error) {
+	return ioutil.ReadAll(NewReader(bytes.NewReader(p)))
+}
+
+func (r *json5Reader) Read(b []byte) (int, error) {
+	for r.ready() < len(b) && r.err == nil && r.state != stDone {
+		r.err = r.step()
+	}
+	if n := r.ready(); n > 0 {
+		if n < len(b) {
+			b = b[:n]
+		}
+		n, _ = r.out.Read(b)
+		if r.comma >= 0 {
+			r.comma -= n
+		}
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
+
+// ready 可以输出的字节数，未确定的逗号及之后的内容暂不输出
+func (r *json5Reader) ready() int {
+	if r.comma >= 0 && r.err == nil {
+		return r.comma
+	}
+	return r.out.Len()
+}
+
+func (r *json5Reader) fail(format string, a ...interface{}) error {
+	return &SyntaxError{Line: r.tokLine, Col: r.tokCol, Msg: fmt.Sprintf(format, a...)}
+}
+
+// failLast 在上一个读取的字符处报错
+func (r *json5Reader) failLast(format string, a ...interface{}) error {
+	r.tokLine, r.tokCol = r.prevLine, r.prevCol
+	return r.fail(format, a...)
+}
+
+// next 读取一个字符，结束时返回io.EOF
+func (r *json5Reader) next() (rune, error) {
+	c, _, err := r.br.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	r.prevLine, r.prevCol = r.line, r.col
+	if c == '\n' {
+		r.line++
+		r.col = 1
+	} else {
+		r.col += utf8.RuneLen(c)
+	}
+	return c, nil
+}
+
+func (r *json5Reader) unread() {
+	r.br.UnreadRune()
+	r.line, r.col = r.prevLine, r.prevCol
+}
+
+// peek 读取一个字符并退回
+func (r *json5Reader) peek() rune {
+	c, err := r.next()
+	if err != nil {
+		return -1
+	}
+	r.unread()
+	return c
+}
+
+// eofErr 输入中途结束：EOF转为语法错误，其它读取错误原样返回
+func (r *json5Reader) eofErr(err error) error {
+	if err == io.EOF {
+		r.tokLine, r.tokCol = r.line, r.col
+		return r.fail("unexpected end of input")
+	}
+	return err
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokPunct
+	tokString
+	tokNumber
+	tokIdent
+)
+
+// step 处理一个token
+func (r *json5Reader) step() error {
+	kind, c, text, err := r.token()
+	if err != nil {
+		return err
+	}
+	if kind == tokEOF {
+		if r.state == stEnd {
+			r.state = stDone
+			return nil
+		}
+		return r.fail("unexpected end of input")
+	}
+
+	switch r.state {
+	case stValue:
+		if kind == tokPunct && c == ']' && r.allowClose {
+			return r.close(c)
+		}
+		return r.value(kind, c, text)
+
+	case stKey:
+		switch {
+		case kind == tokPunct && c == '}':
+			return r.close(c)
+		case kind == tokString:
+			r.comma = -1
+			r.out.WriteString(text)
+		case kind == tokIdent:
+			r.comma = -1
+			writeString(&r.out, text)
+		default:
+			return r.fail("invalid character %q looking for beginning of object key", c)
+		}
+		r.state = stColon
+		return nil
+
+	case stColon:
+		if kind != tokPunct || c != ':' {
+			return r.fail("invalid character %q after object key", c)
+		}
+		r.out.WriteByte(':')
+		r.state, r.allowClose = stValue, false
+		return nil
+
+	case stAfterValue:
+		top := r.stack[len(r.stack)-1]
+		switch {
+		case kind == tokPunct && c == ',':
+			r.comma = r.out.Len()
+			r.out.WriteByte(',')
+			if top == '{' {
+				r.state = stKey
+			} else {
+				r.state, r.allowClose = stValue, true
+			}
+			return nil
+		case kind == tokPunct && (top == '{' && c == '}' || top == '[' && c == ']'):
+			return r.close(c)
+		}
+		if top == '{' {
+			return r.fail("invalid character %q after object key:value pair", c)
+		}
+		return r.fail("invalid character %q after array element", c)
+	}
+	return r.fail("invalid character %q after top-level value", c)
+}
+
+func (r *json5Reader) value(kind tokKind, c rune, text string) error {
+	r.comma = -1
+	switch kind {
+	case tokPunct:
+		if c != '{' && c != '[' {
+			return r.fail("invalid character %q looking for beginning of value", c)
+		}
+		if len(r.stack) >= maxDepth {
+			return r.fail("exceeded max depth")
+		}
+		r.out.WriteRune(c)
+		r.stack = append(r.stack, byte(c))
+		if c == '{' {
+			r.state = stKey
+		} else {
+			r.state, r.allowClose = stValue, true
+		}
+		return nil
+	case tokIdent:
+		switch text {
+		case "true", "false", "null":
+		case "Infinity", "NaN":
+			return r.fail("%v cannot be represented in JSON", text)
+		default:
+			return r.fail("invalid literal %q", text)
+		}
+	}
+	r.out.WriteString(text)
+	r.afterValue()
+	return nil
+}
+
+func (r *json5Reader) close(c rune) error {
+	if r.comma >= 0 { // 删除末尾的逗号
+		b := r.out.Bytes()
+		copy(b[r.comma:], b[r.comma+1:])
+		r.out.Truncate(len(b) - 1)
+		r.comma = -1
+	}
+	r.out.WriteRune(c)
+	r.stack = r.stack[:len(r.stack)-1]
+	r.afterValue()
+	return nil
+}
+
+func (r *json5Reader) afterValue() {
+	if len(r.stack) == 0 {
+		r.state = stEnd
+	} else {
+		r.state = stAfterValue
+	}
+}
+
+// token 跳过空白和注释，读取下一个token。
+// tokPunct返回字符c，tokString和tokNumber返回转换后的JSON文本，tokIdent返回标识符
+func (r *json5Reader) token() (kind tokKind, c rune, text string, err error) {
+	if err = r.skip(); err != nil {
+		return
+	}
+	r.tokLine, r.tokCol = r.line, r.col
+	c, err = r.next()
+	if err == io.EOF {
+		return tokEOF, 0, "", nil
+	}
+	if err != nil {
+		return
+	}
+	switch {
+	case strings.ContainsRune("{}[]:,", c):
+		return tokPunct, c, "", nil
+	case c == '"' || c == '\'':
+		text, err = r.str(c)
+		return tokString, c, text, err
+	case c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.':
+		r.unread()
+		text, err = r.number()
+		if err == nil && text == "" { // -Infinity等
+			return tokIdent, c, "", r.fail("%vInfinity and NaN cannot be represented in JSON", string(c))
+		}
+		return tokNumber, c, text, err
+	case isIdentStart(c) || c == '\\':
+		r.unread()
+		text, err = r.ident()
+		return tokIdent, c, text, err
+	}
+	return tokPunct, c, "", r.fail("invalid character %q", c)
+}
+
+// skip 跳过空白和注释，换行原样输出
+func (r *json5Reader) skip() error {
+	for {
+		c, err := r.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			r.out.WriteRune(c)
+		case c == '\v' || c == '\f' || c == '\u00a0' || c == '\ufeff' ||
+			c == '\u2028' || c == '\u2029' || unicode.Is(unicode.Zs, c):
+			r.out.WriteByte(' ')
+		case c == '/':
+			r.tokLine, r.tokCol = r.prevLine, r.prevCol
+			if err = r.comment(); err != nil {
+				return err
+			}
+		default:
+			r.unread()
+			return nil
+		}
+	}
+}
+
+func (r *json5Reader) comment() error {
+	c, err := r.next()
+	if err != nil {
+		if err == io.EOF {
+			return r.fail("invalid character '/'")
+		}
+		return err
+	}
+	switch c {
+	case '/':
+		for {
+			c, err = r.next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if c == '\n' || c == '\r' {
+				r.unread()
+				return nil
+			}
+		}
+	case '*':
+		star := false
+		for {
+			c, err = r.next()
+			if err != nil {
+				if err == io.EOF {
+					return r.fail("unterminated comment")
+				}
+				return err
+			}
+			if star && c == '/' {
+				return nil
+			}
+			star = c == '*'
+			if c == '\n' {
+				r.out.WriteByte('\n')
+			}
+		}
+	}
+	r.unread()
+	return r.fail("invalid character '/'")
+}
+
+// str 读取引号q括起的字符串，返回双引号的JSON字符串。
+// 续行被去掉的换行补在字符串之后，保持行号不变
+func (r *json5Reader) str(q rune) (string, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('"')
+	line := r.line
+	for {
+		c, err := r.next()
+		if err != nil {
+			return "", r.eofErr(err)
+		}
+		switch c {
+		case q:
+			buf.WriteByte('"')
+			for ; line < r.line; line++ {
+				buf.WriteByte('\n')
+			}
+			return buf.String(), nil
+		case '\n', '\r':
+			return "", r.fail("unterminated string")
+		case '\\':
+			if err = r.escape(buf); err != nil {
+				return "", err
+			}
+		default:
+			writeRune(buf, c)
+		}
+	}
+}
+
+// escape 转换'\'之后的转义字符
+func (r *json5Reader) escape(buf *bytes.Buffer) error {
+	c, err := r.next()
+	if err != nil {
+		return r.eofErr(err)
+	}
+	switch c {
+	case '"', '\\', 'b', 'f', 'n', 'r', 't':
+		buf.WriteByte('\\')
+		buf.WriteRune(c)
+	case 'v':
+		buf.WriteString(`\u000b`)
+	case '0':
+		if p := r.peek(); p >= '0' && p <= '9' {
+			return r.failLast("invalid escape sequence")
+		}
+		buf.WriteString(`\u0000`)
+	case 'x':
+		h, err := r.hex(2)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(`\u00` + h)
+	case 'u':
+		h, err := r.hex(4)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(`\u` + h)
+	case '\r': // 续行
+		if r.peek() == '\n' {
+			r.next()
+		}
+	case '\n', '\u2028', '\u2029':
+	default:
+		if c >= '1' && c <= '9' {
+			return r.failLast("invalid escape sequence")
+		}
+		writeRune(buf, c)
+	}
+	return nil
+}
+
+func (r *json5Reader) hex(n int) (string, error) {
+	var h []rune
+	for i := 0; i < n; i++ {
+		c, err := r.next()
+		if err != nil {
+			return "", r.eofErr(err)
+		}
+		if !isHex(c) {
+			return "", r.failLast("invalid escape sequence")
+		}
+		h = append(h, c)
+	}
+	return string(h), nil
+}
+
+func isHex(c rune) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+var (
+	hexNumber = regexp.MustCompile(`^0[xX][0-9a-fA-F]+$`)
+	decNumber = regexp.MustCompile(`^(0|[1-9][0-9]*)?(\.[0-9]*)?([eE][+-]?[0-9]+)?$`)
+)
+
+// number 读取数字并转为JSON格式，Infinity和NaN返回""
+func (r *json5Reader) number() (string, error) {
+	var sign, raw string
+	c, _ := r.next()
+	if c == '-' || c == '+' {
+		raw = string(c)
+		if c == '-' {
+			sign = "-"
+		}
+		if isIdentStart(r.peek()) {
+			name, err := r.ident()
+			if err != nil {
+				return "", err
+			}
+			if name == "Infinity" || name == "NaN" {
+				return "", nil
+			}
+			return "", r.fail("invalid number")
+		}
+	} else {
+		r.unread()
+	}
+
+	var lit []rune
+	for {
+		c, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		prev := rune(0)
+		if len(lit) > 0 {
+			prev = lit[len(lit)-1]
+		}
+		isHexLit := len(lit) > 1 && (lit[1] == 'x' || lit[1] == 'X')
+		if c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '.' ||
+			(c == '+' || c == '-') && (prev == 'e' || prev == 'E') && !isHexLit {
+			lit = append(lit, c)
+			continue
+		}
+		r.unread()
+		break
+	}
+
+	s := string(lit)
+	if hexNumber.MatchString(s) {
+		n, _ := new(big.Int).SetString(s[2:], 16)
+		if n.Sign() == 0 {
+			sign = ""
+		}
+		return sign + n.String(), nil
+	}
+	m := decNumber.FindStringSubmatch(s)
+	if m == nil || m[1] == "" && len(m[2]) <= 1 {
+		return "", r.fail("invalid number %q", raw+s)
+	}
+	if m[1] == "" {
+		m[1] = "0"
+	}
+	if m[2] == "." {
+		m[2] = ""
+	}
+	return sign + m[1] + m[2] + m[3], nil
+}
+
+func isIdentStart(c rune) bool {
+	return c == '$' || c == '_' || unicode.IsLetter(c)
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || unicode.IsDigit(c) || c == '\u200c' || c == '\u200d' ||
+		unicode.In(c, unicode.Mn, unicode.Mc, unicode.Pc)
+}
+
+// ident 读取标识符，支持\uXXXX转义
+func (r *json5Reader) ident() (string, error) {
+	var name []rune
+	for {
+		c, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if c == '\\' {
+			if c, err = r.next(); err != nil || c != 'u' {
+				return "", r.fail("invalid identifier escape")
+			}
+			h, err := r.hex(4)
+			if err != nil {
+				return "", err
+			}
+			u, _ := strconv.ParseUint(h, 16, 32)
+			c = rune(u)
+			if !isIdentPart(c) || len(name) == 0 && !isIdentStart(c) {
+				return "", r.fail("invalid identifier escape")
+			}
+		} else if !isIdentPart(c) || len(name) == 0 && !isIdentStart(c) {
+			r.unread()
+			break
+		}
+		name = append(name, c)
+	}
+	return string(name), nil
+}
+
+// writeString 输出JSON字符串
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, c := range s {
+		writeRune(buf, c)
+	}
+	buf.WriteByte('"')
+}
+
+// writeRune 输出字符串中的字符，转义'"', '\\'和控制字符
+func writeRune(buf *bytes.Buffer, c rune) {
+	switch {
+	case c == '"' || c == '\\':
+		buf.WriteByte('\\')
+		buf.WriteRune(c)
+	case c == '\t':
+		buf.WriteString(`\t`)
+	case c < 0x20:
+		fmt.Fprintf(buf, `\u%04x`, c)
+	default:
+		buf.WriteRune(c)
+	}
+}
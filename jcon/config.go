@@ -0,0 +1,391 @@
+package jcon
+
+// 加载带注释的json配置
+// 多个文件按顺序深度合并，字符串中的${ENV:default}替换为环境变量，
+// 再用PREFIX_SECTION_KEY形式的环境变量覆盖对应字段，最后解码到结构体。
+// 字段tag `jcon:"required"`表示必填。
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eachain/common/gson"
+)
+
+type RequiredErr struct {
+	Fields []string
+}
+
+func (re RequiredErr) Error() string {
+	return fmt.Sprintf("jcon: required fields missing: %v", strings.Join(re.Fields, ", "))
+}
+
+type EnvErr struct {
+	Name string
+	Msg  string
+}
+
+func (ee EnvErr) Error() string {
+	return fmt.Sprintf("jcon: env %v: %v", ee.Name, ee.Msg)
+}
+
+// Loader 配置加载选项
+type Loader struct {
+	// EnvPrefix 环境变量覆盖的前缀，为空时不覆盖。
+	// 如"APP"时，APP_DB_HOST覆盖db.host，key中的非字母数字字符写作'_'
+	EnvPrefix string
+	// Interval Watch检查文件变化的间隔，默认1秒
+	Interval time.Duration
+	// LookupEnv 默认os.LookupEnv
+	LookupEnv func(key string) (string, bool)
+}
+
+var DefaultLoader = &Loader{EnvPrefix: "APP"}
+
+// Load 使用DefaultLoader加载files到v，v中已有的值作为默认值
+func Load(v interface{}, files ...string) error {
+	return DefaultLoader.Load(v, files...)
+}
+
+// Watch 使用DefaultLoader加载并监视files
+func Watch(v interface{}, onChange func(v interface{}, err error), files ...string) (stop func(), err error) {
+	return DefaultLoader.Watch(v, onChange, files...)
+}
+
+// Load 加载files到v，v须为结构体指针，v中已有的值作为默认值
+func (l *Loader) Load(v interface{}, files ...string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("jcon: Load(non-struct-pointer %T)", v)
+	}
+
+	g := gson.FromString("{}")
+	for _, name := range files {
+		p, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		src := NewSource(name, p)
+		var raw json.RawMessage
+		if err = json.Unmarshal(src.JSON, &raw); err != nil {
+			return src.Error(err)
+		}
+		if err = gson.Merge(g, gson.FromBytes(raw), gson.MergeStrategy{}); err != nil {
+			return fmt.Errorf("jcon: %v: %v", name, err)
+		}
+	}
+	g.SetEmbeddedJSON(false)
+
+	if err := l.expand(g); err != nil {
+		return err
+	}
+	if err := l.override(g, rv.Elem().Type(), nil, map[reflect.Type]bool{}); err != nil {
+		return err
+	}
+	var missing []string
+	required(g, rv.Elem().Type(), nil, map[reflect.Type]bool{}, &missing)
+	if len(missing) > 0 {
+		return RequiredErr{Fields: missing}
+	}
+	return g.Decode(v)
+}
+
+// Watch 先Load到v，之后每隔Interval检查files的修改时间和大小，
+// 有变化时重新加载到与v同类型的新值，调用onChange(新值, nil)；加载失败时调用onChange(nil, err)。
+// 重新加载以第一次Load之前v中的值为默认值，不会修改v。
+func (l *Loader) Watch(v interface{}, onChange func(v interface{}, err error), files ...string) (stop func(), err error) {
+	defaults, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err = l.Load(v, files...); err != nil {
+		return nil, err
+	}
+	typ := reflect.TypeOf(v).Elem()
+	interval := l.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	done := make(chan struct{})
+	last := statFiles(files)
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+			st := statFiles(files)
+			if reflect.DeepEqual(st, last) {
+				continue
+			}
+			last = st
+			nv := reflect.New(typ)
+			json.Unmarshal(defaults, nv.Interface())
+			if err := l.Load(nv.Interface(), files...); err != nil {
+				onChange(nil, err)
+			} else {
+				onChange(nv.Interface(), nil)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }, nil
+}
+
+type fileStat struct {
+	mod  time.Time
+	size int64
+}
+
+func statFiles(files []string) []fileStat {
+	st := make([]fileStat, len(files))
+	for i, name := range files {
+		if fi, err := os.Stat(name); err == nil {
+			st[i] = fileStat{mod: fi.ModTime(), size: fi.Size()}
+		}
+	}
+	return st
+}
+
+func (l *Loader) lookupEnv(key string) (string, bool) {
+	if l.LookupEnv != nil {
+		return l.LookupEnv(key)
+	}
+	return os.LookupEnv(key)
+}
+
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:[^}]*)?\}`)
+
+// expand 替换字符串中的${ENV}和${ENV:default}，未设置且没有默认值时返回EnvErr
+func (l *Loader) expand(g *gson.GSON) error {
+	var err error
+	g.Walk(func(path string, node *gson.GSON) gson.WalkAction {
+		if node.Type() != gson.TypString {
+			return gson.WalkContinue
+		}
+		s := node.Str()
+		if !strings.Contains(s, "${") {
+			return gson.WalkContinue
+		}
+		r := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+			m := envRef.FindStringSubmatch(ref)
+			if v, ok := l.lookupEnv(m[1]); ok {
+				return v
+			}
+			if m[2] == "" && err == nil {
+				err = EnvErr{Name: m[1], Msg: "not set, referenced by " + path}
+			}
+			return strings.TrimPrefix(m[2], ":")
+		})
+		if err != nil {
+			return gson.WalkStop
+		}
+		node.Set(r)
+		return gson.WalkContinue
+	})
+	return err
+}
+
+type configField struct {
+	name     string
+	typ      reflect.Type
+	required bool
+}
+
+// configFields 同encoding/json的字段名规则，内嵌struct的字段被提升
+func configFields(t reflect.Type) []configField {
+	var fs []configField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			fs = append(fs, configFields(ft)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fs = append(fs, configField{
+			name:     name,
+			typ:      sf.Type,
+			required: sf.Tag.Get("jcon") == "required",
+		})
+	}
+	return fs
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// section 是否为需要逐字段处理的结构体
+func section(t reflect.Type) (reflect.Type, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t, t.Kind() == reflect.Struct && !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// override 用环境变量覆盖字段，并将字符串转换为数字、布尔等字段类型
+func (l *Loader) override(g *gson.GSON, t reflect.Type, path []string, visiting map[reflect.Type]bool) error {
+	if visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for _, f := range configFields(t) {
+		p := append(path[:len(path):len(path)], f.name)
+		if st, ok := section(f.typ); ok {
+			if err := l.override(g, st, p, visiting); err != nil {
+				return err
+			}
+			continue
+		}
+
+		node := g.Get(joinPath(p))
+		if l.EnvPrefix != "" {
+			name := envName(l.EnvPrefix, p)
+			if s, ok := l.lookupEnv(name); ok {
+				v, err := convert(s, f.typ, true)
+				if err != nil {
+					return EnvErr{Name: name, Msg: err.Error()}
+				}
+				if err = node.Set(v); err != nil {
+					return EnvErr{Name: name, Msg: err.Error()}
+				}
+				continue
+			}
+		}
+		if node.Type() == gson.TypString {
+			v, err := convert(node.Str(), f.typ, false)
+			if err != nil {
+				return fmt.Errorf("jcon: %v: %v", joinPath(p), err)
+			}
+			if err = node.Set(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// convert 将字符串转换为t类型对应的json值。
+// env为true时，slice、map等类型的值按json解析，否则保留字符串。
+func convert(s string, t reflect.Type, env bool) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			if n, e := strconv.ParseInt(s, 10, 64); e == nil {
+				return n, nil
+			}
+			return nil, err
+		}
+		return int64(d), nil
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return s, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		var n json.Number
+		if err := json.Unmarshal([]byte(s), &n); err != nil {
+			return nil, fmt.Errorf("invalid number %q", s)
+		}
+		return n, nil
+	}
+	if env && json.Valid([]byte(s)) {
+		return json.RawMessage(s), nil
+	}
+	return s, nil
+}
+
+// required 收集缺失的必填字段，指针结构体不存在时不检查其内部字段
+func required(g *gson.GSON, t reflect.Type, path []string, visiting map[reflect.Type]bool, missing *[]string) {
+	if visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for _, f := range configFields(t) {
+		p := append(path[:len(path):len(path)], f.name)
+		node := g.Get(joinPath(p))
+		exists := node.Err() == nil && node.Type() != gson.TypUnknown && node.Type() != gson.TypNull
+		if f.required && !exists {
+			*missing = append(*missing, joinPath(p))
+			continue
+		}
+		if st, ok := section(f.typ); ok && (exists || f.typ.Kind() != reflect.Ptr) {
+			required(g, st, p, visiting, missing)
+		}
+	}
+}
+
+// joinPath 拼接为gson.Get的路径
+func joinPath(path []string) string {
+	var b strings.Builder
+	for i, k := range path {
+		if k == "" || strings.ContainsAny(k, ".[]") {
+			q, _ := json.Marshal(k)
+			fmt.Fprintf(&b, "[%s]", q)
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(k)
+	}
+	return b.String()
+}
+
+// envName 如APP_DB_HOST
+func envName(prefix string, path []string) string {
+	name := []byte(prefix)
+	for _, k := range path {
+		name = append(name, '_')
+		for _, c := range strings.ToUpper(k) {
+			if 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+				name = append(name, byte(c))
+			} else {
+				name = append(name, '_')
+			}
+		}
+	}
+	return string(name)
+}
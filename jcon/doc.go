@@ -0,0 +1,732 @@
+package jcon
+
+// 保留注释和格式的json文档
+// 解析后可按gson.Get的路径语法修改，输出时注释、空白和未修改部分保持原样。
+// key之前的注释属于该key，值后面同一行的注释属于该值，删除时一并删除。
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eachain/common/gson"
+)
+
+type PathErr struct {
+	Path string
+	Msg  string
+}
+
+func (pe PathErr) Error() string {
+	return fmt.Sprintf("jcon: path '%v': %v", pe.Path, pe.Msg)
+}
+
+type Doc struct {
+	root *value
+	unit string // 缩进单位，单行文档为""
+}
+
+type value struct {
+	before []byte // 值之前的空白和注释
+	after  []byte // 值之后、','之前
+	trail  []byte // ','之后同一行的注释
+
+	kind    byte // '{', '['，标量为0
+	lit     []byte
+	members []*member
+	elems   []*value
+	end     []byte // 最后一个元素之后、结束符之前
+}
+
+type member struct {
+	before []byte // key之前的空白和注释
+	key    []byte // 带引号的key
+	name   string
+	colon  []byte // key与':'之间
+	v      *value // v.before为':'之后
+}
+
+// ParseDoc 解析带注释的json，语法错误时返回*SyntaxError
+func ParseDoc(p []byte) (*Doc, error) {
+	dp := &docParser{p: p}
+	before, err := dp.trivia()
+	if err != nil {
+		return nil, err
+	}
+	v, err := dp.value()
+	if err != nil {
+		return nil, err
+	}
+	v.before = before
+	if v.after, err = dp.trivia(); err != nil {
+		return nil, err
+	}
+	if dp.i < len(p) {
+		return nil, dp.fail("unexpected %q after top-level value", dp.rune())
+	}
+	return &Doc{root: v, unit: indentUnit(v)}, nil
+}
+
+// Bytes 输出文档，包括注释
+func (d *Doc) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	d.root.write(buf, true)
+	return buf.Bytes()
+}
+
+// JSON 输出去除注释后的json
+func (d *Doc) JSON() []byte {
+	return TrimComment(d.Bytes())
+}
+
+// Get 同gson的Get，返回的是副本，修改请使用Set
+func (d *Doc) Get(path string) *gson.GSON {
+	return gson.FromBytes(d.JSON()).Get(path)
+}
+
+// Set 将path处的值设为v，v按json.Marshal编码，json.RawMessage原样使用。
+// 原有值前后的注释保留；不存在的key追加到对象末尾，中间缺失的对象或列表自动创建；
+// 下标越界时追加到列表末尾，负数越界时插入到开头，同gson。
+func (d *Doc) Set(path string, v interface{}) error {
+	raw, err := marshalValue(v)
+	if err != nil {
+		return err
+	}
+	segs, err := gson.SplitPath(path)
+	if err != nil {
+		return err
+	}
+	cur, ind := d.root, ""
+	for k, seg := range segs {
+		child, childInd := cur.child(seg, ind)
+		if child == nil {
+			return d.insert(cur, ind, seg, nest(segs[k+1:], raw), path)
+		}
+		cur, ind = child, childInd
+	}
+	multi := bytes.IndexByte(cur.body(), '\n') >= 0
+	nv, err := parseValue(d.format(raw, ind, multi))
+	if err != nil {
+		return err
+	}
+	cur.kind, cur.lit, cur.members, cur.elems, cur.end = nv.kind, nv.lit, nv.members, nv.elems, nv.end
+	return nil
+}
+
+// Delete 删除path处的值及其注释，不存在时忽略
+func (d *Doc) Delete(path string) error {
+	segs, err := gson.SplitPath(path)
+	if err != nil {
+		return err
+	}
+	cur, ind := d.root, ""
+	for _, seg := range segs[:len(segs)-1] {
+		if cur, ind = cur.child(seg, ind); cur == nil {
+			return nil
+		}
+	}
+	switch seg := segs[len(segs)-1].(type) {
+	case string:
+		if i := cur.memberIndex(seg); i >= 0 {
+			cur.members = append(cur.members[:i], cur.members[i+1:]...)
+		}
+	case int:
+		if i, ok := cur.elemIndex(seg); ok {
+			cur.elems = append(cur.elems[:i], cur.elems[i+1:]...)
+		}
+	}
+	return nil
+}
+
+// Comment 返回path处key(列表元素为值)之前的注释，去掉注释符号，多个注释按行合并
+func (d *Doc) Comment(path string) string {
+	before, err := d.leading(path)
+	if err != nil || before == nil {
+		return ""
+	}
+	var lines []string
+	for _, c := range comments(*before) {
+		if strings.HasPrefix(c, "//") {
+			lines = append(lines, strings.TrimPrefix(c[2:], " "))
+			continue
+		}
+		for _, l := range strings.Split(strings.TrimSpace(c[2:len(c)-2]), "\n") {
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), "*")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SetComment 替换path处之前的注释，text每行写作一个"//"注释，text为空时删除注释。
+// 单行文档中写作"/* */"注释。
+func (d *Doc) SetComment(path, text string) error {
+	before, err := d.leading(path)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return PathErr{Path: path, Msg: "not found"}
+	}
+	*before = withComment(*before, text)
+	return nil
+}
+
+// leading 返回path处节点之前的注释所在位置，不存在时返回nil
+func (d *Doc) leading(path string) (*[]byte, error) {
+	segs, err := gson.SplitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	cur := d.root
+	for _, seg := range segs[:len(segs)-1] {
+		if cur, _ = cur.child(seg, ""); cur == nil {
+			return nil, nil
+		}
+	}
+	switch seg := segs[len(segs)-1].(type) {
+	case string:
+		if i := cur.memberIndex(seg); i >= 0 {
+			return &cur.members[i].before, nil
+		}
+	case int:
+		if i, ok := cur.elemIndex(seg); ok {
+			return &cur.elems[i].before, nil
+		}
+	}
+	return nil, nil
+}
+
+// insert 在cur中添加不存在的seg，ind为cur所在行的缩进
+func (d *Doc) insert(cur *value, ind string, seg interface{}, raw []byte, path string) error {
+	multi := cur.multiline() || cur.empty() && d.unit != ""
+	childInd := ind + d.unit
+	var last *value
+	var lastBefore []byte
+	switch cur.kind {
+	case '{':
+		if _, ok := seg.(string); !ok {
+			return PathErr{Path: path, Msg: "index on object"}
+		}
+		if n := len(cur.members); n > 0 {
+			last, lastBefore = cur.members[n-1].v, cur.members[n-1].before
+		}
+	case '[':
+		if _, ok := seg.(int); !ok {
+			return PathErr{Path: path, Msg: "key on list"}
+		}
+		if n := len(cur.elems); n > 0 {
+			last = cur.elems[n-1]
+			lastBefore = last.before
+		}
+	default:
+		return PathErr{Path: path, Msg: "not an object or list"}
+	}
+	if i := bytes.LastIndexByte(lastBefore, '\n'); i >= 0 {
+		childInd = string(lastBefore[i+1:])
+	}
+
+	nv, err := parseValue(d.format(raw, childInd, multi))
+	if err != nil {
+		return err
+	}
+	before := []byte(nil)
+	if multi {
+		before = []byte("\n" + childInd)
+		if cur.empty() && isSpace(cur.end) {
+			cur.end = []byte("\n" + ind)
+		}
+	} else if last != nil {
+		before = spaces(lastBefore)
+	}
+
+	if cur.kind == '[' {
+		nv.before = before
+		if seg.(int) < 0 && len(cur.elems) > 0 {
+			first := cur.elems[0]
+			if !multi {
+				nv.before, first.before = first.before, []byte(" ")
+			}
+			cur.elems = append([]*value{nv}, cur.elems...)
+		} else {
+			cur.elems = append(cur.elems, nv)
+		}
+		return nil
+	}
+
+	name := seg.(string)
+	key, _ := json.Marshal(name)
+	m := &member{before: before, key: key, name: name, v: nv}
+	if last != nil {
+		lm := cur.members[len(cur.members)-1]
+		m.colon, nv.before = spaces(lm.colon), spaces(lm.v.before)
+	} else if multi {
+		nv.before = []byte(" ")
+	}
+	cur.members = append(cur.members, m)
+	return nil
+}
+
+// format 格式化新值，多行时按ind缩进
+func (d *Doc) format(raw []byte, ind string, multi bool) []byte {
+	buf := &bytes.Buffer{}
+	if multi && d.unit != "" && (raw[0] == '{' || raw[0] == '[') {
+		if json.Indent(buf, raw, ind, d.unit) == nil {
+			return buf.Bytes()
+		}
+		buf.Reset()
+	}
+	if json.Compact(buf, raw) != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+func marshalValue(v interface{}) ([]byte, error) {
+	if raw, ok := v.(json.RawMessage); ok {
+		if !json.Valid(raw) {
+			return nil, fmt.Errorf("jcon: invalid json: %s", raw)
+		}
+		return raw, nil
+	}
+	return json.Marshal(v)
+}
+
+// nest 为缺失的路径生成对象或列表
+func nest(segs []interface{}, raw []byte) []byte {
+	for i := len(segs) - 1; i >= 0; i-- {
+		switch seg := segs[i].(type) {
+		case string:
+			key, _ := json.Marshal(seg)
+			raw = []byte("{" + string(key) + ":" + string(raw) + "}")
+		case int:
+			raw = []byte("[" + string(raw) + "]")
+		}
+	}
+	return raw
+}
+
+// child 返回seg对应的子节点及其所在行的缩进，不存在时返回nil
+func (v *value) child(seg interface{}, ind string) (*value, string) {
+	var c *value
+	var before []byte
+	switch seg := seg.(type) {
+	case string:
+		if i := v.memberIndex(seg); i >= 0 {
+			c, before = v.members[i].v, v.members[i].before
+		}
+	case int:
+		if i, ok := v.elemIndex(seg); ok {
+			c, before = v.elems[i], v.elems[i].before
+		}
+	}
+	if i := bytes.LastIndexByte(before, '\n'); i >= 0 {
+		ind = string(before[i+1:])
+	}
+	return c, ind
+}
+
+func (v *value) memberIndex(name string) int {
+	if v.kind != '{' {
+		return -1
+	}
+	for i, m := range v.members {
+		if m.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// elemIndex 同gson，负数表示倒数第几个
+func (v *value) elemIndex(i int) (int, bool) {
+	if v.kind != '[' {
+		return 0, false
+	}
+	if i < 0 {
+		i += len(v.elems)
+	}
+	return i, i >= 0 && i < len(v.elems)
+}
+
+func (v *value) empty() bool {
+	return len(v.members) == 0 && len(v.elems) == 0
+}
+
+// multiline 元素是否分行书写
+func (v *value) multiline() bool {
+	for _, m := range v.members {
+		if bytes.IndexByte(m.before, '\n') >= 0 {
+			return true
+		}
+	}
+	for _, e := range v.elems {
+		if bytes.IndexByte(e.before, '\n') >= 0 {
+			return true
+		}
+	}
+	return bytes.IndexByte(v.end, '\n') >= 0
+}
+
+func (v *value) body() []byte {
+	buf := &bytes.Buffer{}
+	v.writeBody(buf)
+	return buf.Bytes()
+}
+
+func (v *value) write(buf *bytes.Buffer, last bool) {
+	buf.Write(v.before)
+	v.writeBody(buf)
+	buf.Write(v.after)
+	if !last {
+		buf.WriteByte(',')
+	}
+	buf.Write(v.trail)
+}
+
+func (v *value) writeBody(buf *bytes.Buffer) {
+	switch v.kind {
+	case '{':
+		buf.WriteByte('{')
+		for i, m := range v.members {
+			buf.Write(m.before)
+			buf.Write(m.key)
+			buf.Write(m.colon)
+			buf.WriteByte(':')
+			m.v.write(buf, i == len(v.members)-1)
+		}
+		buf.Write(v.end)
+		buf.WriteByte('}')
+	case '[':
+		buf.WriteByte('[')
+		for i, e := range v.elems {
+			e.write(buf, i == len(v.elems)-1)
+		}
+		buf.Write(v.end)
+		buf.WriteByte(']')
+	default:
+		buf.Write(v.lit)
+	}
+}
+
+// indentUnit 以第一层元素的缩进作为缩进单位
+func indentUnit(v *value) string {
+	var before []byte
+	if len(v.members) > 0 {
+		before = v.members[0].before
+	} else if len(v.elems) > 0 {
+		before = v.elems[0].before
+	}
+	i := bytes.LastIndexByte(before, '\n')
+	if i < 0 {
+		return ""
+	}
+	if unit := string(before[i+1:]); unit != "" {
+		return unit
+	}
+	return "  "
+}
+
+func isSpace(b []byte) bool {
+	return len(bytes.TrimLeft(b, " \t\r\n")) == 0
+}
+
+// spaces 去掉b中的注释，只保留最后一行的空白
+func spaces(b []byte) []byte {
+	if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+		b = b[i:]
+	}
+	var out []byte
+	for _, c := range TrimComment(b) {
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// comments 返回trivia中的注释
+func comments(b []byte) []string {
+	var cs []string
+	for i := 0; i < len(b); i++ {
+		if b[i] != '/' || i+1 >= len(b) {
+			continue
+		}
+		n := commentLen(b[i:])
+		if n > 0 {
+			cs = append(cs, string(b[i:i+n]))
+			i += n - 1
+		}
+	}
+	return cs
+}
+
+// commentLen b以注释开头时返回注释长度，未结束的多行注释返回-1
+func commentLen(b []byte) int {
+	if len(b) < 2 || b[0] != '/' {
+		return 0
+	}
+	switch b[1] {
+	case '/':
+		if i := bytes.IndexAny(b, "\r\n"); i >= 0 {
+			return i
+		}
+		return len(b)
+	case '*':
+		if i := bytes.Index(b[2:], []byte("*/")); i >= 0 {
+			return i + 4
+		}
+		return -1
+	}
+	return 0
+}
+
+// withComment 将trivia中的注释替换为text
+func withComment(b []byte, text string) []byte {
+	nl := bytes.LastIndexByte(b, '\n')
+	if nl < 0 {
+		b = spaces(b)
+		if text == "" {
+			return b
+		}
+		return append(b, "/* "+strings.Replace(text, "*/", "* /", -1)+" */ "...)
+	}
+	ind := string(b[nl+1:])
+	lead := b[:nl+1]
+	if cs := comments(b); len(cs) > 0 {
+		lead = b[:bytes.Index(b, []byte(cs[0]))]
+		lead = lead[:bytes.LastIndexByte(lead, '\n')+1]
+	}
+	out := append([]byte(nil), lead...)
+	if len(lead) == 0 { // 注释与上一个值在同一行
+		out = append(out, '\n')
+	}
+	if text != "" {
+		for _, l := range strings.Split(text, "\n") {
+			out = append(out, strings.TrimRight(ind+"// "+l, " ")...)
+			out = append(out, '\n')
+		}
+	}
+	return append(out, ind...)
+}
+
+func parseValue(p []byte) (*value, error) {
+	d, err := ParseDoc(p)
+	if err != nil {
+		return nil, err
+	}
+	d.root.before, d.root.after = nil, nil
+	return d.root, nil
+}
+
+type docParser struct {
+	p []byte
+	i int
+}
+
+func (dp *docParser) fail(msg string, a ...interface{}) error {
+	line, col := 1, 1
+	for _, c := range string(dp.p[:dp.i]) {
+		if c == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return &SyntaxError{Line: line, Col: col, Msg: fmt.Sprintf(msg, a...)}
+}
+
+func (dp *docParser) rune() rune {
+	c, _ := utf8.DecodeRune(dp.p[dp.i:])
+	return c
+}
+
+func (dp *docParser) peek() byte {
+	if dp.i < len(dp.p) {
+		return dp.p[dp.i]
+	}
+	return 0
+}
+
+// trivia 读取空白和注释
+func (dp *docParser) trivia() ([]byte, error) {
+	start := dp.i
+	for dp.i < len(dp.p) {
+		switch dp.p[dp.i] {
+		case ' ', '\t', '\r', '\n':
+			dp.i++
+			continue
+		case '/':
+			n := commentLen(dp.p[dp.i:])
+			if n < 0 {
+				return nil, dp.fail("unterminated comment")
+			}
+			if n > 0 {
+				dp.i += n
+				continue
+			}
+		}
+		break
+	}
+	return dp.p[start:dp.i:dp.i], nil
+}
+
+// splitTrail 将t中第一个换行之前的注释分出，作为前一个值的行尾注释
+func splitTrail(t []byte) (trail, rest []byte) {
+	for i := 0; i < len(t); i++ {
+		switch t[i] {
+		case '\n':
+			if len(comments(t[:i])) > 0 {
+				return t[:i:i], t[i:]
+			}
+			return nil, t
+		case '/':
+			if n := commentLen(t[i:]); n > 0 {
+				i += n - 1
+			}
+		}
+	}
+	return nil, t
+}
+
+// value 解析一个值，不含前后的空白和注释
+func (dp *docParser) value() (*value, error) {
+	v := &value{}
+	switch c := dp.peek(); c {
+	case '{', '[':
+		dp.i++
+		v.kind = c
+		t, err := dp.trivia()
+		if err != nil {
+			return nil, err
+		}
+		closer := byte('}')
+		if c == '[' {
+			closer = ']'
+		}
+		if dp.peek() == closer {
+			dp.i++
+			v.end = t
+			return v, nil
+		}
+		for {
+			var e *value
+			if c == '{' {
+				m, err := dp.member(t)
+				if err != nil {
+					return nil, err
+				}
+				v.members = append(v.members, m)
+				e = m.v
+			} else {
+				if e, err = dp.value(); err != nil {
+					return nil, err
+				}
+				e.before = t
+				v.elems = append(v.elems, e)
+			}
+			if t, err = dp.trivia(); err != nil {
+				return nil, err
+			}
+			switch dp.peek() {
+			case ',':
+				dp.i++
+				e.after = t
+				if t, err = dp.trivia(); err != nil {
+					return nil, err
+				}
+				if dp.peek() == closer {
+					return nil, dp.fail("unexpected %q after ','", closer)
+				}
+				e.trail, t = splitTrail(t)
+			case closer:
+				dp.i++
+				e.trail, v.end = splitTrail(t)
+				return v, nil
+			default:
+				if dp.i >= len(dp.p) {
+					return nil, dp.fail("unexpected EOF")
+				}
+				return nil, dp.fail("expect ',' or %q, got %q", closer, dp.rune())
+			}
+		}
+	case '"':
+		lit, err := dp.str()
+		if err != nil {
+			return nil, err
+		}
+		v.lit = lit
+		return v, nil
+	case 0:
+		if dp.i >= len(dp.p) {
+			return nil, dp.fail("unexpected EOF")
+		}
+	}
+	start := dp.i
+	for dp.i < len(dp.p) && !strings.ContainsRune(" \t\r\n,:]}/", rune(dp.p[dp.i])) {
+		dp.i++
+	}
+	v.lit = dp.p[start:dp.i:dp.i]
+	if len(v.lit) == 0 || !json.Valid(v.lit) || strings.ContainsRune("{[\"", rune(v.lit[0])) {
+		dp.i = start
+		return nil, dp.fail("invalid value %v", strconv.Quote(string(v.lit)))
+	}
+	return v, nil
+}
+
+func (dp *docParser) member(before []byte) (*member, error) {
+	if dp.peek() != '"' {
+		if dp.i >= len(dp.p) {
+			return nil, dp.fail("unexpected EOF")
+		}
+		return nil, dp.fail("expect string key, got %q", dp.rune())
+	}
+	m := &member{before: before}
+	var err error
+	if m.key, err = dp.str(); err != nil {
+		return nil, err
+	}
+	json.Unmarshal(m.key, &m.name)
+	if m.colon, err = dp.trivia(); err != nil {
+		return nil, err
+	}
+	if dp.peek() != ':' {
+		return nil, dp.fail("expect ':'")
+	}
+	dp.i++
+	vb, err := dp.trivia()
+	if err != nil {
+		return nil, err
+	}
+	if m.v, err = dp.value(); err != nil {
+		return nil, err
+	}
+	m.v.before = vb
+	return m, nil
+}
+
+// str 读取带引号的字符串
+func (dp *docParser) str() ([]byte, error) {
+	start := dp.i
+	for dp.i++; dp.i < len(dp.p); dp.i++ {
+		switch dp.p[dp.i] {
+		case '\\':
+			dp.i++
+		case '"':
+			dp.i++
+			lit := dp.p[start:dp.i:dp.i]
+			if !json.Valid(lit) {
+				dp.i = start
+				return nil, dp.fail("invalid string")
+			}
+			return lit, nil
+		case '\n':
+			dp.i = start
+			return nil, dp.fail("unterminated string")
+		}
+	}
+	dp.i = start
+	return nil, dp.fail("unterminated string")
+}
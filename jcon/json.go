@@ -10,13 +10,28 @@ import (
 	"io/ioutil"
 )
 
+type commentState int
+
+const (
+	csNormal       commentState = iota
+	csString                    // 字符串内
+	csEscape                    // 字符串内'\'之后
+	csSlash                     // 字符串外的'/'，尚不确定是否为注释
+	csLineComment               // "//"注释，到换行结束，换行保留
+	csBlockComment              // "/*"注释
+	csBlockStar                 // "/*"注释内的'*'之后
+)
+
 type reader struct {
-	r io.Reader
+	r     io.Reader
+	buf   []byte
+	out   []byte // 已处理、未返回的数据
+	state commentState
+	err   error
 
-	prefix          byte
-	inString        bool
-	inSingleComment bool
-	inMultiComment  bool
+	blank bool  // 注释替换为空格而非删除
+	n     int   // 已处理的字节数
+	pos   []int // 非nil时记录out中每个字节在原文中的位置
 }
 
 func NewReader(r io.Reader) io.Reader {
@@ -24,58 +39,125 @@ func NewReader(r io.Reader) io.Reader {
 }
 
 func (r *reader) Read(b []byte) (int, error) {
-	n, err := r.r.Read(b)
-	l := 0
-	for i := 0; i < n; i++ {
-		if r.inSingleComment {
-			if b[i] == '\n' || b[i] == '\r' {
-				r.inSingleComment = false
-				r.prefix = 0
-			} else {
-				continue
-			}
+	for len(r.out) == 0 && r.err == nil {
+		if r.buf == nil {
+			r.buf = make([]byte, 4096)
 		}
-
-		if r.inMultiComment {
-			if r.prefix == '*' && b[i] == '/' {
-				r.inMultiComment = false
-				r.prefix = 0
-			} else {
-				r.prefix = b[i]
-			}
-			continue
+		n, err := r.r.Read(r.buf)
+		for _, c := range r.buf[:n] {
+			r.process(c)
+			r.n++
 		}
+		if err != nil {
+			r.flush()
+			r.err = err
+		}
+	}
+	n := copy(b, r.out)
+	r.out = r.out[n:]
+	if len(r.out) > 0 {
+		return n, nil
+	}
+	r.out = r.out[:0:0]
+	return n, r.err
+}
 
-		if b[i] == '"' {
-			r.inString = !r.inString
+func (r *reader) process(c byte) {
+	switch r.state {
+	case csNormal:
+		switch c {
+		case '"':
+			r.state = csString
+		case '/':
+			r.state = csSlash
+			return
 		}
-		if !r.inString {
-			if r.prefix == '/' {
-				if b[i] == '/' {
-					r.inSingleComment = true
-					r.prefix = 0
-					l--
-					continue
-				}
-				if b[i] == '*' {
-					r.inMultiComment = true
-					r.prefix = 0
-					l--
-					continue
-				}
-			}
+	case csString:
+		switch c {
+		case '\\':
+			r.state = csEscape
+		case '"':
+			r.state = csNormal
 		}
-
-		r.prefix = b[i]
-		if l < i {
-			b[l] = b[i]
+	case csEscape:
+		r.state = csString
+	case csSlash:
+		switch c {
+		case '/':
+			r.state = csLineComment
+			r.drop('/', r.n-1)
+			r.drop(c, r.n)
+			return
+		case '*':
+			r.state = csBlockComment
+			r.drop('/', r.n-1)
+			r.drop(c, r.n)
+			return
+		}
+		r.emit('/', r.n-1)
+		r.state = csNormal
+		r.process(c)
+		return
+	case csLineComment:
+		if c != '\n' && c != '\r' {
+			r.drop(c, r.n)
+			return
 		}
-		l++
+		r.state = csNormal
+	case csBlockComment:
+		if c == '*' {
+			r.state = csBlockStar
+		}
+		r.drop(c, r.n)
+		return
+	case csBlockStar:
+		switch c {
+		case '/':
+			r.state = csNormal
+		case '*':
+		default:
+			r.state = csBlockComment
+		}
+		r.drop(c, r.n)
+		return
 	}
-	return l, err
+	r.emit(c, r.n)
+}
+
+// flush 输入结束，末尾的'/'不是注释
+func (r *reader) flush() {
+	if r.state == csSlash {
+		r.emit('/', r.n-1)
+		r.state = csNormal
+	}
+}
+
+// emit 输出原文中位于at的字节c
+func (r *reader) emit(c byte, at int) {
+	r.out = append(r.out, c)
+	if r.pos != nil {
+		r.pos = append(r.pos, at)
+	}
+}
+
+// drop 丢弃注释中的字节，blank时换行原样保留，其它替换为空格
+func (r *reader) drop(c byte, at int) {
+	if !r.blank {
+		return
+	}
+	if c != '\n' && c != '\r' {
+		c = ' '
+	}
+	r.emit(c, at)
 }
 
 func TrimComment(p []byte) []byte {
 	b, _ := ioutil.ReadAll(NewReader(bytes.NewReader(p)))
 	return b
 }
+
+// BlankComment 将注释替换为空格，换行保留，输出与原文的字节偏移和行号一致
+func BlankComment(p []byte) []byte {
+	b, _ := ioutil.ReadAll(&reader{r: bytes.NewReader(p), blank: true})
+	return b
+}
@@ -0,0 +1,47 @@
+package jcon
+
+// JSON5转为JSON，实现见internal/json5，gson.FromJSON5与此共用。
+// 在注释之外，还支持末尾多余的逗号、单引号字符串、
+// 不带引号的key、十六进制数、.5和5.形式的小数、正号及JSON5的转义字符。
+// 输出保留原有的换行，转换后的行号与原文一致。
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/eachain/common/internal/json5"
+)
+
+type SyntaxError struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (se *SyntaxError) Error() string {
+	return fmt.Sprintf("jcon: line %v col %v: %v", se.Line, se.Col, se.Msg)
+}
+
+type json5Reader struct {
+	r io.Reader
+}
+
+// NewJSON5Reader 将JSON5流转为JSON，语法错误时Read返回*SyntaxError
+func NewJSON5Reader(r io.Reader) io.Reader {
+	return json5Reader{r: json5.NewReader(r)}
+}
+
+// ConvertJSON5 将JSON5转为JSON
+func ConvertJSON5(p []byte) ([]byte, error) {
+	return ioutil.ReadAll(NewJSON5Reader(bytes.NewReader(p)))
+}
+
+func (r json5Reader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if se, ok := err.(*json5.SyntaxError); ok {
+		err = &SyntaxError{Line: se.Line, Col: se.Col, Msg: se.Msg}
+	}
+	return n, err
+}
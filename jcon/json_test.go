@@ -0,0 +1,382 @@
+package jcon
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// trimCommentRef 一次处理完整输入的参考实现
+func trimCommentRef(p []byte) []byte {
+	var out []byte
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for j < len(p) && p[j] != '"' {
+				if p[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(p) {
+				j = len(p) - 1
+			}
+			out = append(out, p[i:j+1]...)
+			i = j
+		case c == '/' && i+1 < len(p) && p[i+1] == '/':
+			j := i + 2
+			for j < len(p) && p[j] != '\n' && p[j] != '\r' {
+				j++
+			}
+			i = j - 1
+		case c == '/' && i+1 < len(p) && p[i+1] == '*':
+			end := bytes.Index(p[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(p)
+			} else {
+				i += 2 + end + 1
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// chunkReader 按sizes循环给出的长度分块返回数据
+type chunkReader struct {
+	p     []byte
+	sizes []byte
+	i     int
+}
+
+func (c *chunkReader) Read(b []byte) (int, error) {
+	if len(c.p) == 0 {
+		return 0, io.EOF
+	}
+	n := 1
+	if len(c.sizes) > 0 {
+		n = int(c.sizes[c.i%len(c.sizes)])%7 + 1
+		c.i++
+	}
+	if n > len(b) {
+		n = len(b)
+	}
+	if n > len(c.p) {
+		n = len(c.p)
+	}
+	copy(b, c.p[:n])
+	c.p = c.p[n:]
+	return n, nil
+}
+
+// readChunks 以sizes决定的缓冲区大小读完r
+func readChunks(r io.Reader, sizes []byte) ([]byte, error) {
+	var out []byte
+	for i := 0; ; i++ {
+		n := 1
+		if len(sizes) > 0 {
+			n = int(sizes[(i+3)%len(sizes)])%5 + 1
+		}
+		b := make([]byte, n)
+		m, err := r.Read(b)
+		out = append(out, b[:m]...)
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+	}
+}
+
+func TestTrimComment(t *testing.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{`{"a":1}`, `{"a":1}`},
+		{"{\"a\":1 // c\n}", "{\"a\":1 \n}"},
+		{`{"a":/* c */1}`, `{"a":1}`},
+		{`{"a\"//b":1}`, `{"a\"//b":1}`},
+		{`{"a\\"/*x*/:1}`, `{"a\\":1}`},
+		{`{"a":"/*b*/"}`, `{"a":"/*b*/"}`},
+		{`[1/2]`, `[1/2]`},
+		{`[1]/`, `[1]/`},
+		{`[1/**/]`, `[1]`},
+		{`[1/***/]`, `[1]`},
+		{`[1/*/]`, `[1`},
+	}
+	for _, c := range cases {
+		if got := string(TrimComment([]byte(c.in))); got != c.out {
+			t.Errorf("TrimComment(%q): %q, want %q", c.in, got, c.out)
+		}
+		for _, size := range []byte{0, 1, 2, 3} {
+			got, err := readChunks(NewReader(&chunkReader{p: []byte(c.in), sizes: []byte{size}}), []byte{size})
+			if err != nil || string(got) != c.out {
+				t.Errorf("chunk %v %q: %q, %v, want %q", size, c.in, got, err, c.out)
+			}
+		}
+	}
+}
+
+func FuzzTrimComment(f *testing.F) {
+	f.Add([]byte("{\"a\":1 // c\n}"), []byte{0})
+	f.Add([]byte(`{"a\"//b":/* c */1}`), []byte{1, 2, 3})
+	f.Add([]byte(`["\\", "/", 1/2, "x"/**/]/`), []byte{5, 0, 6})
+	f.Fuzz(func(t *testing.T, p, sizes []byte) {
+		want := trimCommentRef(p)
+		if got := TrimComment(p); !bytes.Equal(got, want) {
+			t.Fatalf("TrimComment(%q): %q, want %q", p, got, want)
+		}
+		got, err := readChunks(NewReader(&chunkReader{p: p, sizes: sizes}), sizes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("chunked %q by %v: %q, want %q", p, sizes, got, want)
+		}
+	})
+}
+
+func TestDoc(t *testing.T) {
+	src := `// 服务配置
+{
+  // 监听地址
+  "addr": ":8080", // 默认端口
+  "db": {
+    "host": "localhost",
+    /* 连接池 */
+    "pool": 10
+  },
+  "tags": ["a", "b"] // 行尾
+}
+`
+	d, err := ParseDoc([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(d.Bytes()); got != src {
+		t.Fatalf("round trip: %q", got)
+	}
+	if got := d.Get("db.pool").Int(); got != 10 {
+		t.Fatalf("db.pool: %v", got)
+	}
+	if got := d.Comment("addr"); got != "监听地址" {
+		t.Fatalf("comment: %q", got)
+	}
+
+	d.Set("addr", ":9090")
+	d.Set("db.user", "root")
+	d.Set("tags[-1]", "c")
+	d.Set("tags[5]", "d")
+	d.Set("log.level", "debug")
+	d.Delete("db.pool")
+	d.SetComment("db.host", "数据库地址")
+	want := `// 服务配置
+{
+  // 监听地址
+  "addr": ":9090", // 默认端口
+  "db": {
+    // 数据库地址
+    "host": "localhost",
+    "user": "root"
+  },
+  "tags": ["a", "c", "d"], // 行尾
+  "log": {
+    "level": "debug"
+  }
+}
+`
+	if got := string(d.Bytes()); got != want {
+		t.Fatalf("edit:\n%s\nwant:\n%s", got, want)
+	}
+	if err := d.Set("addr.x", 1); err == nil {
+		t.Fatal("set key on string should fail")
+	}
+
+	for _, bad := range []string{`{"a":1,}`, `{"a" 1}`, `[1 2]`, `{"a":1} x`, `/* x`} {
+		if _, err := ParseDoc([]byte(bad)); err == nil {
+			t.Errorf("ParseDoc(%q) should fail", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.json")
+	local := filepath.Join(dir, "local.json")
+	os.WriteFile(base, []byte(`{
+	// 监听地址
+	"addr": "${HOST:0.0.0.0}:${PORT:8080}",
+	"db": {"host": "localhost", "pool": 10},
+	"timeout": "3s"
+}`), 0644)
+	os.WriteFile(local, []byte(`{"db": {"pool": 20} /* 覆盖 */}`), 0644)
+
+	type DB struct {
+		Host string `json:"host" jcon:"required"`
+		Pool int    `json:"pool"`
+		User string `json:"user"`
+	}
+	type Config struct {
+		Addr    string        `json:"addr"`
+		DB      DB            `json:"db"`
+		Timeout time.Duration `json:"timeout"`
+		Debug   bool          `json:"debug"`
+		Tags    []string      `json:"tags"`
+		Cache   *struct {
+			Size int `json:"size" jcon:"required"`
+		} `json:"cache"`
+	}
+	env := map[string]string{
+		"PORT":          "9090",
+		"APP_DB_USER":   "root",
+		"APP_DEBUG":     "true",
+		"APP_TAGS":      `["a","b"]`,
+		"APP_DB_POOL":   "30",
+		"OTHER_DB_USER": "x",
+	}
+	l := &Loader{EnvPrefix: "APP", LookupEnv: func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}}
+
+	cfg := Config{Debug: false, Tags: []string{"default"}}
+	if err := l.Load(&cfg, base, local); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != "0.0.0.0:9090" || cfg.DB.Host != "localhost" || cfg.DB.Pool != 30 ||
+		cfg.DB.User != "root" || cfg.Timeout != 3*time.Second || !cfg.Debug ||
+		len(cfg.Tags) != 2 || cfg.Cache != nil {
+		t.Fatalf("config: %+v", cfg)
+	}
+
+	os.WriteFile(local, []byte(`{"db": {"host": null}, "cache": {}}`), 0644)
+	err := l.Load(&Config{}, base, local)
+	if re, ok := err.(RequiredErr); !ok || strings.Join(re.Fields, ",") != "db.host,cache.size" {
+		t.Fatalf("required: %v", err)
+	}
+	os.WriteFile(local, []byte(`{"x": "${NOT_SET}"}`), 0644)
+	if _, ok := l.Load(&Config{}, local).(EnvErr); !ok {
+		t.Fatal("unset env should fail")
+	}
+
+	os.WriteFile(local, []byte(`{}`), 0644)
+	l.Interval = 10 * time.Millisecond
+	ch := make(chan interface{}, 1)
+	stop, err := l.Watch(&Config{}, func(v interface{}, err error) {
+		if err != nil {
+			ch <- err
+			return
+		}
+		ch <- v
+	}, base, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	os.WriteFile(local, []byte(`{"db": {"host": "db1"}}`), 0644)
+	os.Chtimes(local, time.Now(), time.Now().Add(time.Second))
+	select {
+	case v := <-ch:
+		if c, ok := v.(*Config); !ok || c.DB.Host != "db1" {
+			t.Fatalf("reload: %v", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reload")
+	}
+}
+
+func TestSource(t *testing.T) {
+	src := "{\n\t// 注释\n\t\"a\": /* x */ 1,\n\t\"b\": 2 3\n}"
+	s := NewSource("app.json", []byte(src))
+	if string(s.JSON) != string(TrimComment([]byte(src))) {
+		t.Fatalf("json: %q", s.JSON)
+	}
+	if b := BlankComment([]byte(src)); len(b) != len(src) || string(b[:4]) != "{\n\t " {
+		t.Fatalf("blank: %q", b)
+	}
+
+	var v interface{}
+	err := s.Error(json.Unmarshal(s.JSON, &v))
+	se, ok := err.(*SourceErr)
+	if !ok {
+		t.Fatalf("error: %v", err)
+	}
+	if se.File != "app.json" || se.Line != 4 || se.Col != 9 || se.Snippet != "\t\"b\": 2 3\n\t       ^" {
+		t.Fatalf("error: %+v", se)
+	}
+
+	var x struct{ A string }
+	err = NewSource("", []byte("{\n  /* x */ \"a\": 1}")).Error(json.Unmarshal(TrimComment([]byte("{\n  /* x */ \"a\": 1}")), &x))
+	if se, ok := err.(*SourceErr); !ok || se.Line != 2 || se.Col != 16 {
+		t.Fatalf("type error: %v", err)
+	}
+}
+
+func TestJSON5(t *testing.T) {
+	cases := []struct {
+		in, out   string
+		line, col int // 出错位置，0表示没有错误
+	}{
+		// 注释
+		{"{a: 1 // c\n}", "{\"a\": 1 \n}", 0, 0},
+		{"[1, /* x\ny */ 2]", "[1, \n 2]", 0, 0},
+		{"[1 /* x", "", 1, 4},
+		{"[1 / 2]", "", 1, 4},
+		// 不带引号的key
+		{"{$a_1: 1, é: 2}", `{"$a_1": 1, "é": 2}`, 0, 0},
+		{`{ab: 1}`, `{"ab": 1}`, 0, 0},
+		{"{1a: 1}", "", 1, 2},
+		// 单引号
+		{`['a"b', 'it\'s']`, `["a\"b", "it's"]`, 0, 0},
+		{`'\x41\v\0'`, `"\u0041\u000b\u0000"`, 0, 0},
+		{"'a\\\nb'", "\"ab\"\n", 0, 0},
+		{"'a\nb'", "", 1, 1},
+		// 数字
+		{"[0x1F, -0XfF, +1, .5, 5., 1e3]", "[31, -255, 1, 0.5, 5, 1e3]", 0, 0},
+		{"[Infinity]", "", 1, 2},
+		{"[-Infinity]", "", 1, 2},
+		{"[NaN]", "", 1, 2},
+		{"[01]", "", 1, 2},
+		// 末尾逗号
+		{"{a: [1, 2,], b: {c: 1,},}", `{"a": [1, 2], "b": {"c": 1}}`, 0, 0},
+		{"[1,,]", "", 1, 4},
+		{"[,]", "", 1, 2},
+		// 出错位置
+		{"{\n  a: 1,\n  b: x,\n}", "", 3, 6},
+		{"{\n  a: 1\n  b: 2\n}", "", 3, 3},
+		{"{a: 1", "", 1, 6},
+		{"1 2", "", 1, 3},
+	}
+	for _, c := range cases {
+		got, err := ConvertJSON5([]byte(c.in))
+		// 每次只读一个字节，结果应相同
+		chunked, cerr := readChunks(NewJSON5Reader(&chunkReader{p: []byte(c.in)}), nil)
+		if c.line == 0 {
+			if err != nil || string(got) != c.out {
+				t.Errorf("ConvertJSON5(%q): %q, %v, want %q", c.in, got, err, c.out)
+			}
+			if cerr != nil || string(chunked) != c.out {
+				t.Errorf("chunked %q: %q, %v, want %q", c.in, chunked, cerr, c.out)
+			}
+			if !json.Valid(got) {
+				t.Errorf("ConvertJSON5(%q): invalid json %q", c.in, got)
+			}
+			continue
+		}
+		for _, e := range []error{err, cerr} {
+			se, ok := e.(*SyntaxError)
+			if !ok || se.Line != c.line || se.Col != c.col {
+				t.Errorf("ConvertJSON5(%q): %v, want error at %v:%v", c.in, e, c.line, c.col)
+			}
+		}
+	}
+}
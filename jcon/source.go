@@ -0,0 +1,132 @@
+package jcon
+
+// 去除注释后，将json解码错误的偏移对应到原文的行列
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type SourceErr struct {
+	File    string
+	Line    int
+	Col     int
+	Snippet string // 出错的行，及指向出错列的'^'
+	Err     error
+}
+
+func (se *SourceErr) Error() string {
+	pos := fmt.Sprintf("%v:%v", se.Line, se.Col)
+	if se.File != "" {
+		pos = se.File + ":" + pos
+	}
+	return fmt.Sprintf("%v: %v\n%v", pos, se.Err, se.Snippet)
+}
+
+func (se *SourceErr) Unwrap() error {
+	return se.Err
+}
+
+// Source 原文及去除注释后的json，记录两者的偏移对应关系
+type Source struct {
+	Name string // 文件名，用于错误信息
+	Src  []byte
+	JSON []byte
+	runs []run
+}
+
+// run json中从out开始的连续字节对应原文中从src开始的连续字节
+type run struct {
+	out, src int
+}
+
+// NewSource 去除src中的注释
+func NewSource(name string, src []byte) *Source {
+	r := &reader{pos: make([]int, 0, len(src))}
+	for _, c := range src {
+		r.process(c)
+		r.n++
+	}
+	r.flush()
+
+	s := &Source{Name: name, Src: src, JSON: r.out}
+	for i, at := range r.pos {
+		if i == 0 || at != r.pos[i-1]+1 {
+			s.runs = append(s.runs, run{out: i, src: at})
+		}
+	}
+	return s
+}
+
+// Offset 返回JSON中偏移off在原文中的偏移
+func (s *Source) Offset(off int) int {
+	if off >= len(s.JSON) {
+		return len(s.Src)
+	}
+	i := sort.Search(len(s.runs), func(i int) bool { return s.runs[i].out > off }) - 1
+	if i < 0 {
+		return off
+	}
+	return s.runs[i].src + off - s.runs[i].out
+}
+
+// Position 返回原文偏移off所在的行列，从1开始，列按字符计
+func (s *Source) Position(off int) (line, col int) {
+	if off > len(s.Src) {
+		off = len(s.Src)
+	}
+	start := 0
+	if i := strings.LastIndexByte(string(s.Src[:off]), '\n'); i >= 0 {
+		start = i + 1
+	}
+	line = 1 + strings.Count(string(s.Src[:start]), "\n")
+	return line, 1 + utf8.RuneCount(s.Src[start:off])
+}
+
+// Error 将解码s.JSON时的*json.SyntaxError或*json.UnmarshalTypeError
+// 转换为指向原文位置的*SourceErr，其它错误原样返回
+func (s *Source) Error(err error) error {
+	var off int64 // Offset为读取出错字节之后的偏移
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &se):
+		off = se.Offset
+	case errors.As(err, &te):
+		off = te.Offset
+	default:
+		return err
+	}
+
+	at := 0
+	if off > 0 {
+		at = s.Offset(int(off - 1))
+	}
+	line, col := s.Position(at)
+	return &SourceErr{File: s.Name, Line: line, Col: col, Snippet: s.snippet(at), Err: err}
+}
+
+// snippet 返回off所在的行，下一行用'^'指出位置，制表符原样保留以便对齐
+func (s *Source) snippet(off int) string {
+	start := strings.LastIndexByte(string(s.Src[:off]), '\n') + 1
+	end := len(s.Src)
+	if i := strings.IndexByte(string(s.Src[off:]), '\n'); i >= 0 {
+		end = off + i
+	}
+	text := strings.TrimRight(string(s.Src[start:end]), "\r")
+
+	var caret strings.Builder
+	for _, c := range string(s.Src[start:off]) {
+		if c == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	caret.WriteByte('^')
+	return text + "\n" + caret.String()
+}
@@ -0,0 +1,465 @@
+package logger
+
+// 结构化日志
+// With为Logger添加字段，NewEncoder按JSON或logfmt每条输出一行，
+// printf式的调用格式化后作为msg字段。
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 各编码器共用的key
+const (
+	KeyTime   = "time"
+	KeyLevel  = "level"
+	KeyCaller = "caller"
+	KeyMsg    = "msg"
+)
+
+const timeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// encLevelNames 编码器输出的级别名，与文本Logger的"[ERR]"不同，使用通用的ERROR
+var encLevelNames = [...]string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+func levelName(l Level) string {
+	if l >= LevelDebug && l <= LevelFatal {
+		return encLevelNames[l]
+	}
+	return l.String()
+}
+
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Record 一条日志
+type Record struct {
+	Time   time.Time
+	Level  Level
+	Caller string // 如"gson/json.go:12"
+	Msg    string
+	Fields []Field
+}
+
+type Encoder interface {
+	// Encode 将r编码为一行追加到buf，包括末尾的换行
+	Encode(buf *bytes.Buffer, r *Record)
+}
+
+var (
+	// JSONEncoder 输出如{"time":"...","level":"INFO","caller":"a/b.go:1","msg":"hello","user_id":1}
+	JSONEncoder Encoder = jsonEncoder{}
+	// LogfmtEncoder 输出如time=... level=INFO caller=a/b.go:1 msg=hello user_id=1
+	LogfmtEncoder Encoder = logfmtEncoder{}
+)
+
+/*
+With 为logger添加字段，kv为key, value交替，例如：
+
+	log := logger.With(logger.Get(), "user_id", uid, "req_id", rid)
+	log = logger.WithPrefix(log, "HandleLogin: ")
+	log.Infof("login from %v", ip)
+	// JSONEncoder Output:
+	// {"time":"...","level":"INFO","caller":"api/login.go:20","msg":"HandleLogin: login from 1.2.3.4","user_id":1,"req_id":"x"}
+
+key不是string时用fmt.Sprint转换，落单的value使用key "!BADKEY"。
+New创建的文本Logger将字段按logfmt追加到行尾。
+*/
+func With(logger Logger, kv ...interface{}) Logger {
+	if len(kv) == 0 {
+		return logger
+	}
+	fs := make([]Field, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 == len(kv) {
+			fs = append(fs, Field{Key: "!BADKEY", Value: kv[i]})
+			break
+		}
+		k, ok := kv[i].(string)
+		if !ok {
+			k = fmt.Sprint(kv[i])
+		}
+		fs = append(fs, Field{Key: k, Value: kv[i+1]})
+	}
+	return fieldLogger{l: logger, fields: fs}
+}
+
+type fieldLogger struct {
+	l      Logger
+	fields []Field
+}
+
+func (fl fieldLogger) enabled(level Level) bool {
+	return Enabled(fl.l, level)
+}
+
+func (fl fieldLogger) logf(level Level, force bool, fields []Field, format string, a []interface{}) {
+	if !force && !fl.enabled(level) {
+		return
+	}
+	all := make([]Field, 0, len(fl.fields)+len(fields))
+	all = append(append(all, fl.fields...), fields...)
+	logf(fl.l, level, force, all, format, a)
+}
+
+func (fl fieldLogger) Debugf(format string, a ...interface{}) {
+	fl.logf(LevelDebug, false, nil, format, a)
+}
+
+func (fl fieldLogger) Infof(format string, a ...interface{}) {
+	fl.logf(LevelInfo, false, nil, format, a)
+}
+
+func (fl fieldLogger) Warnf(format string, a ...interface{}) {
+	fl.logf(LevelWarn, false, nil, format, a)
+}
+
+func (fl fieldLogger) Errorf(format string, a ...interface{}) {
+	fl.logf(LevelError, false, nil, format, a)
+}
+
+func (fl fieldLogger) Fatalf(format string, a ...interface{}) {
+	fl.logf(LevelFatal, false, nil, format, a)
+}
+
+// - - - - - - - - - - encoder logger - - - - - - - - - -
+
+// NewEncoder 返回使用enc输出到w的Logger，受全局级别限制。
+func NewEncoder(w io.Writer, enc Encoder) Logger {
+	return encLogger{w: w, enc: enc, mu: &sync.Mutex{}}
+}
+
+// SetOutputEncoder 同SetOutput，使用enc编码。
+func SetOutputEncoder(w io.Writer, enc Encoder) {
+	_logger = NewEncoder(w, enc)
+}
+
+type encLogger struct {
+	w   io.Writer
+	enc Encoder
+	mu  *sync.Mutex
+}
+
+var bufPool = sync.Pool{New: func() interface{} { return &bytes.Buffer{} }}
+
+func (el encLogger) enabled(level Level) bool {
+	return _level.Enabled(level)
+}
+
+func (el encLogger) logf(level Level, force bool, fields []Field, format string, a []interface{}) {
+	if force || el.enabled(level) {
+		r := Record{
+			Time:   time.Now(),
+			Level:  level,
+			Caller: caller(),
+			Msg:    fmt.Sprintf(format, a...),
+			Fields: fields,
+		}
+		buf := bufPool.Get().(*bytes.Buffer)
+		buf.Reset()
+		el.enc.Encode(buf, &r)
+		el.mu.Lock()
+		el.w.Write(buf.Bytes())
+		el.mu.Unlock()
+		bufPool.Put(buf)
+	}
+	if level == LevelFatal {
+		exit(1)
+	}
+}
+
+func (el encLogger) Debugf(format string, a ...interface{}) {
+	el.logf(LevelDebug, false, nil, format, a)
+}
+
+func (el encLogger) Infof(format string, a ...interface{}) {
+	el.logf(LevelInfo, false, nil, format, a)
+}
+
+func (el encLogger) Warnf(format string, a ...interface{}) {
+	el.logf(LevelWarn, false, nil, format, a)
+}
+
+func (el encLogger) Errorf(format string, a ...interface{}) {
+	el.logf(LevelError, false, nil, format, a)
+}
+
+func (el encLogger) Fatalf(format string, a ...interface{}) {
+	el.logf(LevelFatal, false, nil, format, a)
+}
+
+var pkgPrefix = reflect.TypeOf(logger{}).PkgPath() + "."
+
+// caller 返回本包之外的第一个调用者
+func caller() string {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, pkgPrefix) {
+			file := f.File
+			if i := strings.LastIndexByte(file, '/'); i >= 0 {
+				if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+					file = file[j+1:]
+				}
+			}
+			return file + ":" + strconv.Itoa(f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// - - - - - - - - - - encoders - - - - - - - - - -
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(buf *bytes.Buffer, r *Record) {
+	var tb [64]byte
+	buf.WriteString(`{"` + KeyTime + `":"`)
+	buf.Write(r.Time.AppendFormat(tb[:0], timeLayout))
+	buf.WriteString(`","` + KeyLevel + `":"`)
+	buf.WriteString(levelName(r.Level))
+	buf.WriteByte('"')
+	if r.Caller != "" {
+		buf.WriteString(`,"` + KeyCaller + `":`)
+		appendJSONString(buf, r.Caller)
+	}
+	buf.WriteString(`,"` + KeyMsg + `":`)
+	appendJSONString(buf, r.Msg)
+	for _, f := range r.Fields {
+		buf.WriteByte(',')
+		appendJSONString(buf, f.Key)
+		buf.WriteByte(':')
+		appendJSON(buf, f.Value)
+	}
+	buf.WriteString("}\n")
+}
+
+type jsonState struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var jsonPool = sync.Pool{New: func() interface{} {
+	js := &jsonState{}
+	js.enc = json.NewEncoder(&js.buf)
+	js.enc.SetEscapeHTML(false)
+	return js
+}}
+
+// appendJSON error按Error()输出，无法编码的值按fmt.Sprint输出。
+// 字符串、数字和布尔值直接追加，其它值使用复用的json.Encoder
+func appendJSON(buf *bytes.Buffer, v interface{}) {
+	switch x := v.(type) {
+	case string:
+		appendJSONString(buf, x)
+		return
+	case error:
+		appendJSONString(buf, x.Error())
+		return
+	case bool:
+		buf.WriteString(strconv.FormatBool(x))
+		return
+	case int:
+		appendInt(buf, int64(x))
+		return
+	case int8:
+		appendInt(buf, int64(x))
+		return
+	case int16:
+		appendInt(buf, int64(x))
+		return
+	case int32:
+		appendInt(buf, int64(x))
+		return
+	case int64:
+		appendInt(buf, x)
+		return
+	case uint:
+		appendUint(buf, uint64(x))
+		return
+	case uint8:
+		appendUint(buf, uint64(x))
+		return
+	case uint16:
+		appendUint(buf, uint64(x))
+		return
+	case uint32:
+		appendUint(buf, uint64(x))
+		return
+	case uint64:
+		appendUint(buf, x)
+		return
+	case float32:
+		appendFloat(buf, float64(x), 32)
+		return
+	case float64:
+		appendFloat(buf, x, 64)
+		return
+	case nil:
+		buf.WriteString("null")
+		return
+	}
+
+	js := jsonPool.Get().(*jsonState)
+	js.buf.Reset()
+	if js.enc.Encode(v) != nil {
+		js.buf.Reset()
+		appendJSONString(&js.buf, fmt.Sprint(v))
+	}
+	buf.Write(bytes.TrimSuffix(js.buf.Bytes(), []byte{'\n'}))
+	jsonPool.Put(js)
+}
+
+func appendInt(buf *bytes.Buffer, i int64) {
+	var b [20]byte
+	buf.Write(strconv.AppendInt(b[:0], i, 10))
+}
+
+func appendUint(buf *bytes.Buffer, u uint64) {
+	var b [20]byte
+	buf.Write(strconv.AppendUint(b[:0], u, 10))
+}
+
+// appendFloat 格式同encoding/json，NaN和Inf按字符串输出
+func appendFloat(buf *bytes.Buffer, f float64, bits int) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		appendJSONString(buf, strconv.FormatFloat(f, 'g', -1, bits))
+		return
+	}
+	fmtByte := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			fmtByte = 'e'
+		}
+	}
+	var a [32]byte
+	b := strconv.AppendFloat(a[:0], f, fmtByte, -1, bits)
+	if fmtByte == 'e' { // 1e-07转为1e-7
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	buf.Write(b)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString 同encoding/json且不转义HTML字符
+func appendJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= ' ' && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			buf.WriteString(s[start:i])
+			switch c {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			default:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString(s[start:i])
+			buf.WriteString("\ufffd")
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf.WriteString(s[start:i])
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.WriteString(s[start:])
+	buf.WriteByte('"')
+}
+
+type logfmtEncoder struct{}
+
+func (logfmtEncoder) Encode(buf *bytes.Buffer, r *Record) {
+	buf.WriteString(KeyTime + "=" + r.Time.Format(timeLayout))
+	buf.WriteString(" " + KeyLevel + "=" + levelName(r.Level))
+	if r.Caller != "" {
+		buf.WriteString(" " + KeyCaller + "=")
+		appendLogfmtValue(buf, r.Caller)
+	}
+	buf.WriteString(" " + KeyMsg + "=")
+	appendLogfmtValue(buf, r.Msg)
+	appendLogfmtFields(buf, r.Fields)
+	buf.WriteByte('\n')
+}
+
+// appendLogfmtFields 追加" k=v"
+func appendLogfmtFields(buf *bytes.Buffer, fields []Field) {
+	for _, f := range fields {
+		buf.WriteByte(' ')
+		buf.WriteString(strings.Map(func(r rune) rune {
+			if r <= ' ' || r == '=' || r == '"' || !unicode.IsPrint(r) {
+				return '_'
+			}
+			return r
+		}, f.Key))
+		buf.WriteByte('=')
+		appendLogfmtValue(buf, f.Value)
+	}
+}
+
+// appendLogfmtValue 包含空白、'='、'"'或不可打印字符时加引号
+func appendLogfmtValue(buf *bytes.Buffer, v interface{}) {
+	s, ok := v.(string)
+	if !ok {
+		s = fmt.Sprint(v)
+	}
+	quote := s == ""
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			quote = true
+			break
+		}
+	}
+	if quote {
+		s = strconv.Quote(s)
+	}
+	buf.WriteString(s)
+}
@@ -0,0 +1,173 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eachain/common/logger"
+)
+
+var testTime = time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+
+func TestEncoders(t *testing.T) {
+	r := &logger.Record{
+		Time:   testTime,
+		Level:  logger.LevelError,
+		Caller: "api/login.go:20",
+		Msg:    `say "hi" <b>`,
+		Fields: []logger.Field{
+			{Key: "user_id", Value: 1},
+			{Key: "ok", Value: true},
+			{Key: "err", Value: errors.New("a=b")},
+			{Key: "cost", Value: 1500 * time.Millisecond},
+			{Key: "tags", Value: []string{"x", "y"}},
+			{Key: "nil", Value: nil},
+			{Key: "bad key", Value: ""},
+		},
+	}
+	cases := []struct {
+		enc  logger.Encoder
+		want string
+	}{
+		{logger.JSONEncoder, `{"time":"2024-01-02T03:04:05.000006Z","level":"ERROR","caller":"api/login.go:20",` +
+			`"msg":"say \"hi\" <b>","user_id":1,"ok":true,"err":"a=b","cost":1500000000,"tags":["x","y"],"nil":null,"bad key":""}` + "\n"},
+		{logger.LogfmtEncoder, `time=2024-01-02T03:04:05.000006Z level=ERROR caller=api/login.go:20 ` +
+			`msg="say \"hi\" <b>" user_id=1 ok=true err="a=b" cost=1.5s tags="[x y]" nil=<nil> bad_key=""` + "\n"},
+	}
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		c.enc.Encode(buf, r)
+		if got := buf.String(); got != c.want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+		}
+	}
+
+	for l, name := range map[logger.Level]string{
+		logger.LevelDebug: "DEBUG", logger.LevelInfo: "INFO", logger.LevelWarn: "WARN",
+		logger.LevelError: "ERROR", logger.LevelFatal: "FATAL",
+	} {
+		buf := &bytes.Buffer{}
+		logger.JSONEncoder.Encode(buf, &logger.Record{Time: testTime, Level: l})
+		if !strings.Contains(buf.String(), `"level":"`+name+`"`) {
+			t.Errorf("level %v: %s", name, buf)
+		}
+	}
+}
+
+// JSONEncoder的快速路径须与encoding/json(不转义HTML)一致
+func TestJSONValues(t *testing.T) {
+	type point struct{ X, Y int }
+	values := []interface{}{
+		"", "a\"b\\c", "<&>", "\x00\x1f\t\n\r", "\u2028\u2029", "中文", "bad\xffutf8",
+		0, -1, int8(-8), int16(16), int32(-32), int64(math.MinInt64),
+		uint(1), uint8(8), uint16(16), uint32(32), uint64(math.MaxUint64),
+		0.0, -0.5, 1.5, 1e20, 1e21, 1e-6, 1e-7, 123456789.123, math.MaxFloat64, math.SmallestNonzeroFloat64,
+		float32(0.1), float32(1e-7), float32(3.4e38),
+		true, false, nil, point{1, 2}, map[string]int{"a": 1}, []byte("hi"),
+	}
+	for _, v := range values {
+		buf := &bytes.Buffer{}
+		logger.JSONEncoder.Encode(buf, &logger.Record{Time: testTime, Fields: []logger.Field{{Key: "v", Value: v}}})
+		var want bytes.Buffer
+		enc := json.NewEncoder(&want)
+		enc.SetEscapeHTML(false)
+		enc.Encode(v)
+		suffix := `,"v":` + strings.TrimSuffix(want.String(), "\n") + "}\n"
+		if !strings.HasSuffix(buf.String(), suffix) {
+			t.Errorf("%T %#v:\n%s\nwant suffix %s", v, v, buf, suffix)
+		}
+	}
+
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		buf := &bytes.Buffer{}
+		logger.JSONEncoder.Encode(buf, &logger.Record{Time: testTime, Fields: []logger.Field{{Key: "v", Value: f}}})
+		if !strings.HasSuffix(buf.String(), `,"v":"`+fmt.Sprint(f)+"\"}\n") {
+			t.Errorf("%v: %s", f, buf)
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log := logger.With(logger.NewEncoder(buf, logger.JSONEncoder), "user_id", 1, 2, "two", "odd")
+	log = logger.WithPrefix(log, "HandleLogin: ")
+	log = logger.With(log, "req_id", "x")
+	log = logger.WithSuffix(log, " (%d%%)")
+	_, file, line, _ := runtime.Caller(0)
+	log.Warnf("login from %v", "1.2.3.4", 50)
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("%v: %s", err, buf)
+	}
+	caller := "logger/field_test.go:" + strconv.Itoa(line+1)
+	if !strings.HasSuffix(file, "/field_test.go") || m["caller"] != caller {
+		t.Fatalf("caller: %v, want %v", m["caller"], caller)
+	}
+	if m["level"] != "WARN" || m["msg"] != "HandleLogin: login from 1.2.3.4 (50%)" ||
+		m["user_id"] != 1.0 || m["2"] != "two" || m["!BADKEY"] != "odd" || m["req_id"] != "x" {
+		t.Fatalf("fields: %s", buf)
+	}
+	if i, j := strings.Index(buf.String(), `"user_id"`), strings.Index(buf.String(), `"req_id"`); i > j {
+		t.Fatalf("field order: %s", buf)
+	}
+
+	// 文本Logger将字段按logfmt追加到末尾，字段中的'%'不作为格式
+	buf.Reset()
+	log = logger.With(logger.New(buf), "rate", "100%d", "q", "a b")
+	logger.WithPrefix(log, "p: ").Infof("done %v%%", 1)
+	if s := buf.String(); !strings.HasSuffix(s, `[INFO] p: done 1% rate=100%d q="a b"`+"\n") {
+		t.Fatalf("text: %q", s)
+	}
+
+	// 其它Logger同样按logfmt追加
+	buf.Reset()
+	logger.With(printfLogger{buf}, "k", "%s").Errorf("e%v", 1)
+	if s := buf.String(); s != "e1 k=%s\n" {
+		t.Fatalf("foreign: %q", s)
+	}
+
+	if l := logger.New(buf); logger.With(l) != l {
+		t.Fatal("With without fields should return logger")
+	}
+}
+
+type printfLogger struct {
+	buf *bytes.Buffer
+}
+
+func (pl printfLogger) Infof(format string, a ...interface{}) {
+	fmt.Fprintf(pl.buf, format+"\n", a...)
+}
+
+func (pl printfLogger) Warnf(format string, a ...interface{}) {
+	fmt.Fprintf(pl.buf, format+"\n", a...)
+}
+
+func (pl printfLogger) Errorf(format string, a ...interface{}) {
+	fmt.Fprintf(pl.buf, format+"\n", a...)
+}
+
+func BenchmarkJSONEncoder(b *testing.B) {
+	r := &logger.Record{
+		Time:   testTime,
+		Level:  logger.LevelInfo,
+		Caller: "api/login.go:20",
+		Msg:    "login from 1.2.3.4",
+		Fields: []logger.Field{{Key: "user_id", Value: 1}, {Key: "name", Value: "x"}, {Key: "cost", Value: 0.5}},
+	}
+	buf := &bytes.Buffer{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		logger.JSONEncoder.Encode(buf, r)
+	}
+}
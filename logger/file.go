@@ -1,10 +1,15 @@
 package logger
 
 import (
-	"crypto/rand"
+	"compress/gzip"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sync/atomic"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,99 +17,385 @@ const (
 	defaultKeepDays = 7
 )
 
+// Rotation 日志文件的切换和保留策略。
+// 当前日志始终写入dir/file，切换时移动到bakDir，
+// 命名为file-20060102(按天)或file-2006010215(按小时)，同一周期内按大小切换的依次添加.1, .2...
+type Rotation struct {
+	MaxSize  int64         // 当前文件超过该字节数时切换，0表示不按大小切换
+	Interval time.Duration // 按本地时间整点切换的周期，如time.Hour, 24*time.Hour，0表示不按时间切换
+
+	KeepDays     int   // 备份保留天数，0表示不限
+	MaxBackups   int   // 最多保留的备份文件数，0表示不限
+	MaxTotalSize int64 // 备份文件总字节数上限，超出时从最旧的开始删除，0表示不限
+
+	Compress bool   // gzip压缩备份文件，添加.gz后缀
+	Symlink  string // 非空时在该路径创建指向当前日志文件的符号链接
+}
+
 type fileWriter struct {
 	dir    string
 	bakDir string
 	file   string
+	r      Rotation
 
-	fp *atomic.Value // the output file fp
-}
+	mu    sync.Mutex
+	fp    *os.File
+	size  int64
+	start time.Time // 当前文件所属周期的开始时间
+	next  time.Time // 下次按时间切换的时间
+	retry time.Time // 切换失败后，下次重试的时间
 
-func (fw *fileWriter) Write(p []byte) (int, error) {
-	return fw.fp.Load().(*os.File).Write(p)
+	jobs    chan struct{} // 通知后台压缩和清理，容量为1
+	done    chan struct{}
+	stopped chan struct{} // 后台任务已退出
 }
 
 // SetOutputFile 可以设置输出日志的目录，备份目录和日志文件名。
 // 日志默认存储7天备份，每天凌晨切换。
 func SetOutputFile(dir, bakDir, file string) error {
-	err := os.MkdirAll(dir, 0755)
+	return SetOutputFileRotation(dir, bakDir, file, &Rotation{
+		Interval: 24 * time.Hour,
+		KeepDays: defaultKeepDays,
+	})
+}
+
+// SetOutputFileRotation 同SetOutputFile，按r切换和保留备份。
+func SetOutputFileRotation(dir, bakDir, file string, r *Rotation) error {
+	fw, err := NewFileWriter(dir, bakDir, file, r)
 	if err != nil {
 		return err
 	}
+	SetOutput(fw)
+	return nil
+}
+
+/*
+NewFileWriter 返回按r切换的日志文件，可配合NewEncoder使用，例如：
 
-	fp, err := os.OpenFile(filepath.Join(dir, file), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	w, err := logger.NewFileWriter("log", "log/bak", "app.log", &logger.Rotation{
+		MaxSize:    1 << 30,
+		Interval:   time.Hour,
+		KeepDays:   3,
+		MaxBackups: 100,
+		Compress:   true,
+	})
 	if err != nil {
 		return err
 	}
-
+	logger.SetOutputEncoder(w, logger.JSONEncoder)
+*/
+func NewFileWriter(dir, bakDir, file string, r *Rotation) (io.WriteCloser, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
 	fw := &fileWriter{
-		dir:    dir,
-		bakDir: bakDir,
-		file:   file,
-		fp:     &atomic.Value{},
+		dir:     dir,
+		bakDir:  bakDir,
+		file:    file,
+		r:       *r,
+		jobs:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	fp, err := fw.open()
+	if err != nil {
+		return nil, err
 	}
-	fw.fp.Store(fp)
+	fi, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return nil, err
+	}
+	fw.fp = fp
+	fw.size = fi.Size()
+	start := time.Now()
+	if fw.size > 0 { // 沿用上次的文件，周期从其修改时间算起
+		start = fi.ModTime()
+	}
+	fw.setPeriod(start)
+	fw.link()
 
-	SetOutput(fw)
+	go fw.work()
+	fw.notify() // 压缩和清理上次遗留的备份
+	return fw, nil
+}
 
-	go fw.refreshFile()
-	go fw.removeOldFiles()
-	return nil
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.fp == nil {
+		return 0, os.ErrClosed
+	}
+	if fw.due(len(p)) {
+		fw.rotate()
+	}
+	n, err := fw.fp.Write(p)
+	fw.size += int64(n)
+	return n, err
 }
 
-func sleepUntilTommorrow() time.Time {
+// Close 关闭当前文件，等待正在压缩的文件完成后停止后台任务，
+// 未压缩的备份在下次NewFileWriter时处理
+func (fw *fileWriter) Close() error {
+	fw.mu.Lock()
+	if fw.fp == nil {
+		fw.mu.Unlock()
+		return os.ErrClosed
+	}
+	close(fw.done)
+	err := fw.fp.Close()
+	fw.fp = nil
+	fw.mu.Unlock()
+
+	<-fw.stopped
+	return err
+}
+
+func (fw *fileWriter) open() (*os.File, error) {
+	return os.OpenFile(filepath.Join(fw.dir, fw.file), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+}
+
+// setPeriod 设置t所在的周期
+func (fw *fileWriter) setPeriod(t time.Time) {
+	d := fw.r.Interval
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch {
+	case d <= 0:
+		fw.start, fw.next = day, time.Time{}
+	case d >= 24*time.Hour:
+		fw.start, fw.next = day, day.Add(d)
+	default:
+		fw.start = day.Add(t.Sub(day) / d * d)
+		fw.next = fw.start.Add(d)
+	}
+}
+
+// due 写入n字节前是否需要切换
+func (fw *fileWriter) due(n int) bool {
 	now := time.Now()
-	tommorrow := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(24 * time.Hour)
-	time.Sleep(tommorrow.Sub(now))
-	return now
+	if now.Before(fw.retry) {
+		return false
+	}
+	if !fw.next.IsZero() && !now.Before(fw.next) {
+		return true
+	}
+	return fw.r.MaxSize > 0 && fw.size > 0 && fw.size+int64(n) > fw.r.MaxSize
 }
 
-func bakFilename(file string, t time.Time) string {
-	return file + "-" + t.Format("20060102")
+// rotate 将当前文件移动到备份目录并打开新文件，失败时继续写当前文件，一分钟后重试。
+// 调用时持有fw.mu，不能使用本包的日志输出错误。
+func (fw *fileWriter) rotate() {
+	fail := func(format string, a ...interface{}) {
+		fmt.Fprintf(os.Stderr, "logger: "+format+"\n", a...)
+		fw.retry = time.Now().Add(time.Minute)
+	}
+	if err := os.MkdirAll(fw.bakDir, 0755); err != nil {
+		fail("make bak dir: %v", err)
+		return
+	}
+
+	oldpath := filepath.Join(fw.dir, fw.file)
+	newpath := fw.bakPath()
+	if err := os.Rename(oldpath, newpath); err != nil {
+		fail("rename '%v' to '%v': %v", oldpath, newpath, err)
+		return
+	}
+	fp, err := fw.open()
+	if err != nil {
+		fail("create new log file: %v", err)
+		return
+	}
+
+	fw.fp.Close()
+	fw.fp = fp
+	fw.size = 0
+	fw.retry = time.Time{}
+	fw.setPeriod(time.Now())
+	fw.link()
+	fw.notify()
+}
+
+// notify 通知后台任务，不阻塞：已有未处理的通知时，后台会扫描备份目录一并处理
+func (fw *fileWriter) notify() {
+	select {
+	case fw.jobs <- struct{}{}:
+	default:
+	}
 }
 
-func (fw *fileWriter) refreshFile() {
+// bakPath 返回当前文件的备份路径，同一周期已有备份时添加序号
+func (fw *fileWriter) bakPath() string {
+	layout := "20060102"
+	if d := fw.r.Interval; d > 0 && d < 24*time.Hour {
+		layout = "2006010215"
+		if d%time.Hour != 0 {
+			layout = "200601021504"
+		}
+	}
+	name := fw.file + "-" + fw.start.Format(layout)
+	last := -1
+	entries, _ := os.ReadDir(fw.bakDir)
+	for _, e := range entries {
+		if n, i := bakIndex(e.Name()); n == name && i > last {
+			last = i
+		}
+	}
+	if last >= 0 { // 序号递增，不复用已被清理的序号
+		name += "." + strconv.Itoa(last+1)
+	}
+	return filepath.Join(fw.bakDir, name)
+}
+
+// link 更新指向当前文件的符号链接
+func (fw *fileWriter) link() {
+	if fw.r.Symlink == "" {
+		return
+	}
+	target, err := filepath.Abs(filepath.Join(fw.dir, fw.file))
+	if err != nil {
+		return
+	}
+	if cur, err := os.Readlink(fw.r.Symlink); err == nil && cur == target {
+		return
+	}
+	tmp := fw.r.Symlink + ".tmp"
+	os.Remove(tmp)
+	if err = os.Symlink(target, tmp); err == nil {
+		err = os.Rename(tmp, fw.r.Symlink)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logger: symlink '%v': %v\n", fw.r.Symlink, err)
+	}
+}
+
+// work 在后台压缩备份并清理过期文件
+func (fw *fileWriter) work() {
+	defer close(fw.stopped)
+	tick := time.NewTicker(time.Hour)
+	defer tick.Stop()
 	for {
-		yesterday := sleepUntilTommorrow()
-		err := os.MkdirAll(fw.bakDir, 0755)
-		if err != nil {
-			Warnf("logger: make bak dir:", err)
+		select {
+		case <-fw.done:
+			return
+		case <-fw.jobs:
+		case <-tick.C:
+		}
+		if fw.r.Compress && !fw.compressAll() {
+			return
+		}
+		fw.removeOldFiles()
+	}
+}
+
+// compressAll 压缩备份目录中未压缩的备份，Close时返回false
+func (fw *fileWriter) compressAll() bool {
+	for _, b := range fw.backups() {
+		if strings.HasSuffix(b.path, ".gz") {
 			continue
 		}
+		select {
+		case <-fw.done:
+			return false
+		default:
+		}
+		if err := compress(b.path); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: compress '%v': %v\n", b.path, err)
+		}
+	}
+	return true
+}
 
-		oldpath := filepath.Join(fw.dir, fw.file)
-		newpath := filepath.Join(fw.bakDir, bakFilename(fw.file, yesterday))
+// bakIndex 拆分备份文件名中的序号，如app.log-20060102.3.gz返回app.log-20060102, 3
+func bakIndex(path string) (string, int) {
+	name := strings.TrimSuffix(path, ".gz")
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		if n, err := strconv.Atoi(name[i+1:]); err == nil {
+			return name[:i], n
+		}
+	}
+	return name, 0
+}
 
-		err = os.Rename(oldpath, newpath)
-		if err != nil {
-			Warnf("logger: rename '%v' to '%v': %v", oldpath, newpath, err)
+func compress(path string) error {
+	src, err := os.Open(path)
+	if os.IsNotExist(err) { // 已被清理
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if e := zw.Close(); err == nil {
+		err = e
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+type backup struct {
+	path string
+	mod  time.Time
+	size int64
+}
+
+// backups 返回备份目录中的备份文件，新的在前
+func (fw *fileWriter) backups() []backup {
+	entries, err := os.ReadDir(fw.bakDir)
+	if err != nil {
+		return nil
+	}
+	var bs []backup
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || !strings.HasPrefix(name, fw.file+"-") || strings.HasSuffix(name, ".tmp") {
 			continue
 		}
-
-		fp, err := os.OpenFile(oldpath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		fi, err := e.Info()
 		if err != nil {
-			Warnf("logger: create new log file: %v", err)
 			continue
 		}
-
-		oldfp := fw.fp.Load().(*os.File)
-		fw.fp.Store(fp)
-		oldfp.Close()
+		bs = append(bs, backup{path: filepath.Join(fw.bakDir, name), mod: fi.ModTime(), size: fi.Size()})
 	}
+	sort.Slice(bs, func(i, j int) bool { // 修改时间相同时按名字和序号
+		if !bs[i].mod.Equal(bs[j].mod) {
+			return bs[i].mod.After(bs[j].mod)
+		}
+		ni, ii := bakIndex(bs[i].path)
+		nj, ij := bakIndex(bs[j].path)
+		if ni != nj {
+			return ni > nj
+		}
+		return ii > ij
+	})
+	return bs
 }
 
+// removeOldFiles 按KeepDays, MaxBackups, MaxTotalSize删除备份，从最旧的开始
 func (fw *fileWriter) removeOldFiles() {
-	const Day = 24 * time.Hour
-	b := make([]byte, 1)
-	for {
-		rand.Read(b)
-		time.Sleep(time.Duration(b[0]%120) * time.Second)
-
-		date := time.Now().Add(-defaultKeepDays * Day)
-		for i := 0; i < 10; i++ {
-			date = date.Add(-Day)
-			os.Remove(filepath.Join(fw.bakDir, bakFilename(fw.file, date)))
+	deadline := time.Now().Add(-time.Duration(fw.r.KeepDays) * 24 * time.Hour)
+	var total int64
+	for i, b := range fw.backups() {
+		total += b.size
+		if fw.r.KeepDays > 0 && b.mod.Before(deadline) ||
+			fw.r.MaxBackups > 0 && i >= fw.r.MaxBackups ||
+			fw.r.MaxTotalSize > 0 && total > fw.r.MaxTotalSize {
+			os.Remove(b.path)
 		}
-		sleepUntilTommorrow()
 	}
 }
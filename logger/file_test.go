@@ -0,0 +1,231 @@
+package logger_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eachain/common/logger"
+)
+
+// listDir 返回dir中的文件名，按名字排序
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// waitDir 等待后台任务处理完备份目录，直到ok返回true
+func waitDir(t *testing.T, dir string, ok func([]string) bool) []string {
+	t.Helper()
+	var names []string
+	for i := 0; i < 400; i++ {
+		if names = listDir(t, dir); ok(names) {
+			return names
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("backups: %v", names)
+	return nil
+}
+
+// writeLines 写入n行，每行40字节
+func writeLines(t *testing.T, w io.Writer, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := io.WriteString(w, strings.Repeat(string(rune('a'+i%26)), 39)+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRotateSize(t *testing.T) {
+	dir := t.TempDir()
+	bak := filepath.Join(dir, "bak")
+	w, err := logger.NewFileWriter(dir, bak, "app.log", &logger.Rotation{MaxSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, w, 9) // 每个文件2行，第3, 5, 7, 9行写入前切换
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("x")); err != os.ErrClosed {
+		t.Fatal("write after close:", err)
+	}
+
+	names := listDir(t, bak)
+	pattern := regexp.MustCompile(`^app\.log-\d{8}(\.[123])?$`)
+	if len(names) != 4 {
+		t.Fatalf("backups: %v", names)
+	}
+	for _, name := range names {
+		if !pattern.MatchString(name) {
+			t.Fatalf("backup name: %v", name)
+		}
+	}
+	first, _ := os.ReadFile(filepath.Join(bak, names[0])) // 没有序号的是第一个
+	cur, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if len(first) != 80 || first[0] != 'a' || len(cur) != 40 || cur[0] != 'i' {
+		t.Fatalf("content: %q, current %q", first, cur)
+	}
+
+	// 重新打开时沿用当前文件，序号继续递增
+	w, err = logger.NewFileWriter(dir, bak, "app.log", &logger.Rotation{MaxSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, w, 2)
+	w.Close()
+	if names = listDir(t, bak); len(names) != 5 || !strings.HasSuffix(names[len(names)-1], ".4") {
+		t.Fatalf("reopen: %v", names)
+	}
+}
+
+func TestRotateRetention(t *testing.T) {
+	cases := []struct {
+		name string
+		r    logger.Rotation
+		keep []string // 保留的备份序号，""表示没有序号
+	}{
+		{"max backups", logger.Rotation{MaxSize: 100, MaxBackups: 2}, []string{".2", ".3"}},
+		{"max total size", logger.Rotation{MaxSize: 100, MaxTotalSize: 200}, []string{".2", ".3"}},
+		{"keep days", logger.Rotation{MaxSize: 100, KeepDays: 1}, []string{"", ".1", ".2", ".3"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			bak := filepath.Join(dir, "bak")
+			os.MkdirAll(bak, 0755)
+			old := filepath.Join(bak, "app.log-20000101")
+			os.WriteFile(old, []byte("old\n"), 0644)
+			os.Chtimes(old, time.Now().Add(-72*time.Hour), time.Now().Add(-72*time.Hour))
+			other := filepath.Join(bak, "other.log-20000101")
+			os.WriteFile(other, []byte("other\n"), 0644)
+
+			w, err := logger.NewFileWriter(dir, bak, "app.log", &c.r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			writeLines(t, w, 9)
+
+			names := waitDir(t, bak, func(names []string) bool {
+				return len(names) == len(c.keep)+1
+			})
+			for i, suffix := range c.keep {
+				if bakSuffix(names[i]) != suffix {
+					t.Fatalf("backups: %v, want %v", names, c.keep)
+				}
+			}
+			if names[len(names)-1] != "other.log-20000101" {
+				t.Fatalf("other files should be kept: %v", names)
+			}
+		})
+	}
+}
+
+// bakSuffix 返回备份文件名中日期之后的部分
+func bakSuffix(name string) string {
+	return regexp.MustCompile(`^app\.log-\d{8}`).ReplaceAllString(name, "")
+}
+
+func TestRotateCompress(t *testing.T) {
+	dir := t.TempDir()
+	bak := filepath.Join(dir, "bak")
+	link := filepath.Join(dir, "current.log")
+	w, err := logger.NewFileWriter(dir, bak, "app.log", &logger.Rotation{MaxSize: 100, Compress: true, Symlink: link})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, w, 5)
+	names := waitDir(t, bak, func(names []string) bool {
+		return len(names) == 2 && strings.HasSuffix(names[0], ".gz") && strings.HasSuffix(names[1], ".gz")
+	})
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	first := names[0]
+	if bakSuffix(first) != ".gz" { // 第一个备份没有序号
+		first = names[1]
+	}
+	fp, err := os.Open(filepath.Join(bak, first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	zr, err := gzip.NewReader(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil || string(b) != strings.Repeat("a", 39)+"\n"+strings.Repeat("b", 39)+"\n" {
+		t.Fatalf("gzip content: %q, %v", b, err)
+	}
+
+	target, err := os.Readlink(link)
+	if err != nil {
+		t.Skip("symlink not supported:", err)
+	}
+	if abs, _ := filepath.Abs(filepath.Join(dir, "app.log")); target != abs {
+		t.Fatalf("symlink: %v, want %v", target, abs)
+	}
+	if cur, _ := os.ReadFile(link); len(cur) != 40 || cur[0] != 'e' {
+		t.Fatalf("symlink content: %q", cur)
+	}
+}
+
+// Close等待正在进行的压缩完成，不留下临时文件；未压缩的备份在下次打开时处理
+func TestCloseWaitsCompress(t *testing.T) {
+	dir := t.TempDir()
+	bak := filepath.Join(dir, "bak")
+	r := &logger.Rotation{MaxSize: 1 << 20, Compress: true}
+	w, err := logger.NewFileWriter(dir, bak, "app.log", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := []byte(strings.Repeat("x", 1<<20-1) + "\n")
+	for i := 0; i < 20; i++ {
+		w.Write(big)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != os.ErrClosed {
+		t.Fatal("close twice:", err)
+	}
+	for _, name := range listDir(t, bak) {
+		if strings.HasSuffix(name, ".tmp") {
+			t.Fatal("temp file left:", name)
+		}
+	}
+
+	w, err = logger.NewFileWriter(dir, bak, "app.log", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	waitDir(t, bak, func(names []string) bool {
+		for _, name := range names {
+			if !strings.HasSuffix(name, ".gz") {
+				return false
+			}
+		}
+		return len(names) == 19
+	})
+}
@@ -0,0 +1,205 @@
+package logger
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+var levelNames = [...]string{"DEBUG", "INFO", "WARN", "ERR", "FATAL"}
+
+func (l Level) String() string {
+	if l >= LevelDebug && l <= LevelFatal {
+		return levelNames[l]
+	}
+	return fmt.Sprintf("Level(%d)", l)
+}
+
+// ParseLevel 解析级别名，不区分大小写，"ERROR"同"ERR"
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "ERROR" {
+		return LevelError, nil
+	}
+	for i, name := range levelNames {
+		if s == name {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("logger: unknown level %q", s)
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(b []byte) error {
+	lv, err := ParseLevel(string(b))
+	if err == nil {
+		*l = lv
+	}
+	return err
+}
+
+// LevelVar 可在运行时修改的最低输出级别，并发安全，零值为LevelDebug
+type LevelVar struct {
+	v int32
+}
+
+func NewLevelVar(l Level) *LevelVar {
+	return &LevelVar{v: int32(l)}
+}
+
+func (lv *LevelVar) Level() Level {
+	return Level(atomic.LoadInt32(&lv.v))
+}
+
+func (lv *LevelVar) Set(l Level) {
+	atomic.StoreInt32(&lv.v, int32(l))
+}
+
+func (lv *LevelVar) Enabled(l Level) bool {
+	return l >= lv.Level()
+}
+
+func (lv *LevelVar) String() string {
+	return lv.Level().String()
+}
+
+/*
+ServeHTTP 查看或修改级别：
+
+	GET  /log/level            返回当前级别
+	POST /log/level?level=debug 修改级别
+*/
+func (lv *LevelVar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		l, err := ParseLevel(r.FormValue("level"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lv.Set(l)
+	}
+	fmt.Fprintln(w, lv.Level())
+}
+
+// ToggleOnSignal 每收到一次sig，在LevelDebug和原级别之间切换，
+// 例如lv.ToggleOnSignal(syscall.SIGUSR1)。调用stop停止监听。
+func (lv *LevelVar) ToggleOnSignal(sig ...os.Signal) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sig...)
+	go func() {
+		prev := lv.Level()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ch:
+			}
+			if cur := lv.Level(); cur != LevelDebug {
+				prev = cur
+				lv.Set(LevelDebug)
+			} else {
+				lv.Set(prev)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// 全局最低级别，New创建的Logger均受其限制，默认LevelInfo
+var _level = NewLevelVar(LevelInfo)
+
+// GlobalLevel 返回全局级别，可用于注册http handler或监听信号。
+func GlobalLevel() *LevelVar {
+	return _level
+}
+
+func SetLevel(l Level) {
+	_level.Set(l)
+}
+
+func GetLevel() Level {
+	return _level.Level()
+}
+
+// Enabled 判断logger是否输出level级别的日志，可用于避免构造昂贵的参数：
+//
+//	if logger.Enabled(log, logger.LevelDebug) {
+//		logger.Leveled(log).Debugf("state: %v", dump())
+//	}
+func Enabled(logger Logger, level Level) bool {
+	if s, ok := logger.(sink); ok {
+		return s.enabled(level)
+	}
+	return true
+}
+
+/*
+WithLevel 为logger单独设置最低级别，代替全局级别，例如只为某个模块打开Debug：
+
+	lv := logger.NewLevelVar(logger.LevelDebug)
+	log := logger.WithLevel(logger.WithPrefix(logger.Get(), "moduleA: "), lv)
+*/
+func WithLevel(logger Logger, lv *LevelVar) Logger {
+	return levelLogger{l: logger, lv: lv}
+}
+
+type levelLogger struct {
+	l  Logger
+	lv *LevelVar
+}
+
+func (ll levelLogger) enabled(level Level) bool {
+	return ll.lv.Enabled(level)
+}
+
+func (ll levelLogger) logf(level Level, force bool, fields []Field, format string, a []interface{}) {
+	if !force && !ll.lv.Enabled(level) {
+		return
+	}
+	logf(ll.l, level, true, fields, format, a)
+}
+
+func (ll levelLogger) Debugf(format string, a ...interface{}) {
+	ll.logf(LevelDebug, false, nil, format, a)
+}
+
+func (ll levelLogger) Infof(format string, a ...interface{}) {
+	ll.logf(LevelInfo, false, nil, format, a)
+}
+
+func (ll levelLogger) Warnf(format string, a ...interface{}) {
+	ll.logf(LevelWarn, false, nil, format, a)
+}
+
+func (ll levelLogger) Errorf(format string, a ...interface{}) {
+	ll.logf(LevelError, false, nil, format, a)
+}
+
+func (ll levelLogger) Fatalf(format string, a ...interface{}) {
+	ll.logf(LevelFatal, false, nil, format, a)
+}
@@ -0,0 +1,188 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := []struct {
+		s  string
+		l  Level
+		ok bool
+	}{
+		{"debug", LevelDebug, true},
+		{" Info ", LevelInfo, true},
+		{"WARN", LevelWarn, true},
+		{"err", LevelError, true},
+		{"error", LevelError, true},
+		{"fatal", LevelFatal, true},
+		{"trace", 0, false},
+		{"", 0, false},
+	}
+	for _, c := range cases {
+		l, err := ParseLevel(c.s)
+		if (err == nil) != c.ok || c.ok && l != c.l {
+			t.Errorf("ParseLevel(%q): %v, %v", c.s, l, err)
+		}
+	}
+
+	var l Level
+	if err := l.UnmarshalText([]byte("warn")); err != nil || l != LevelWarn {
+		t.Fatal("unmarshal text:", l, err)
+	}
+	if b, _ := l.MarshalText(); string(b) != "WARN" {
+		t.Fatal("marshal text:", string(b))
+	}
+	if s := Level(9).String(); s != "Level(9)" {
+		t.Fatal("unknown level:", s)
+	}
+}
+
+func TestLevelVar(t *testing.T) {
+	var zero LevelVar
+	if zero.Level() != LevelDebug {
+		t.Fatal("zero level:", zero.Level())
+	}
+	lv := NewLevelVar(LevelWarn)
+	if lv.Enabled(LevelInfo) || !lv.Enabled(LevelWarn) || !lv.Enabled(LevelError) {
+		t.Fatal("enabled:", lv)
+	}
+	lv.Set(LevelDebug)
+	if !lv.Enabled(LevelDebug) || lv.String() != "DEBUG" {
+		t.Fatal("set:", lv)
+	}
+}
+
+func TestLevelServeHTTP(t *testing.T) {
+	lv := NewLevelVar(LevelInfo)
+	do := func(method, target string) (int, string) {
+		w := httptest.NewRecorder()
+		lv.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+	if code, body := do(http.MethodGet, "/log/level"); code != 200 || body != "INFO" {
+		t.Fatal("get:", code, body)
+	}
+	if code, body := do(http.MethodPost, "/log/level?level=debug"); code != 200 || body != "DEBUG" || lv.Level() != LevelDebug {
+		t.Fatal("post:", code, body)
+	}
+	if code, _ := do(http.MethodPost, "/log/level?level=x"); code != http.StatusBadRequest || lv.Level() != LevelDebug {
+		t.Fatal("bad level:", code, lv.Level())
+	}
+	if code, _ := do(http.MethodGet, "/log/level?level=error"); code != 200 || lv.Level() != LevelDebug {
+		t.Fatal("get should not set:", lv.Level())
+	}
+}
+
+func TestToggleOnSignal(t *testing.T) {
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Skip(err)
+	}
+	lv := NewLevelVar(LevelWarn)
+	stop := lv.ToggleOnSignal(os.Interrupt)
+	defer stop()
+	wait := func(want Level) {
+		for i := 0; i < 200 && lv.Level() != want; i++ {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if lv.Level() != want {
+			t.Fatalf("level: %v, want %v", lv.Level(), want)
+		}
+	}
+	if err = p.Signal(os.Interrupt); err != nil {
+		t.Skip("signal not supported:", err)
+	}
+	wait(LevelDebug)
+	p.Signal(os.Interrupt)
+	wait(LevelWarn)
+	stop()
+	stop()
+}
+
+// stdLogger 只实现了Logger
+type stdLogger struct {
+	buf *bytes.Buffer
+}
+
+func (sl stdLogger) Infof(format string, a ...interface{}) {
+	fmt.Fprintf(sl.buf, "I "+format+"\n", a...)
+}
+
+func (sl stdLogger) Warnf(format string, a ...interface{}) {
+	fmt.Fprintf(sl.buf, "W "+format+"\n", a...)
+}
+
+func (sl stdLogger) Errorf(format string, a ...interface{}) {
+	fmt.Fprintf(sl.buf, "E "+format+"\n", a...)
+}
+
+// setExit 替换exit，返回调用次数
+func setExit(t *testing.T) *int {
+	n := new(int)
+	exit = func(int) { *n++ }
+	t.Cleanup(func() { exit = os.Exit })
+	return n
+}
+
+func TestLevelFilter(t *testing.T) {
+	defer SetLevel(GetLevel())
+	exits := setExit(t)
+	buf := &bytes.Buffer{}
+	log := Leveled(New(buf))
+
+	SetLevel(LevelWarn)
+	log.Debugf("d")
+	log.Infof("i")
+	log.Warnf("w")
+	log.Errorf("e")
+	log.Fatalf("f")
+	if s := buf.String(); strings.Contains(s, "[DEBUG]") || strings.Contains(s, "[INFO]") ||
+		!strings.Contains(s, "[WARN] w") || !strings.Contains(s, "[ERR] e") || !strings.Contains(s, "[FATAL] f") {
+		t.Fatalf("global level:\n%s", s)
+	}
+	if *exits != 1 {
+		t.Fatal("fatal exits:", *exits)
+	}
+	if Enabled(log, LevelInfo) || !Enabled(log, LevelWarn) {
+		t.Fatal("enabled")
+	}
+
+	// 单独设置的级别代替全局级别，并穿过WithPrefix
+	buf.Reset()
+	lv := NewLevelVar(LevelDebug)
+	mod := Leveled(WithPrefix(WithLevel(WithPrefix(New(buf), "a: "), lv), "b: "))
+	mod.Debugf("x=%v", 1)
+	if s := buf.String(); !strings.Contains(s, "[DEBUG] a: b: x=1\n") {
+		t.Fatalf("per-logger level:\n%s", s)
+	}
+	buf.Reset()
+	lv.Set(LevelError)
+	mod.Warnf("w")
+	if buf.Len() != 0 || Enabled(mod, LevelWarn) || !Enabled(mod, LevelError) {
+		t.Fatalf("per-logger filter:\n%s", buf.String())
+	}
+
+	// 只实现了Logger的类型：Debug按Info，Fatal按Error输出后退出
+	buf.Reset()
+	*exits = 0
+	ext := Leveled(WithPrefix(stdLogger{buf: buf}, "p: "))
+	ext.Debugf("d")
+	ext.Fatalf("f%%")
+	if s := buf.String(); s != "I p: d\nE p: f%\n" || *exits != 1 {
+		t.Fatalf("fallback: %q, exits %v", s, *exits)
+	}
+	buf.Reset()
+	WithLevel(stdLogger{buf: buf}, NewLevelVar(LevelInfo)).Infof("i")
+	Leveled(WithLevel(stdLogger{buf: buf}, NewLevelVar(LevelInfo))).Debugf("d")
+	if s := buf.String(); s != "I i\n" {
+		t.Fatalf("fallback level: %q", s)
+	}
+}
@@ -6,9 +6,12 @@
 package logger
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
 )
 
 type Logger interface {
@@ -17,6 +20,69 @@ type Logger interface {
 	Errorf(format string, a ...interface{})
 }
 
+// LevelLogger 增加Debug和Fatal级别，本包返回的Logger都实现了该接口。
+// 只实现了Logger的类型经WithPrefix等包装后，Debug按Info输出，Fatal按Error输出后退出。
+type LevelLogger interface {
+	Logger
+	Debugf(format string, a ...interface{})
+	Fatalf(format string, a ...interface{}) // 输出后调用os.Exit(1)
+}
+
+// Leveled 返回logger的LevelLogger形式，例如：
+//
+//	logger.Leveled(log).Debugf("state: %v", state)
+func Leveled(logger Logger) LevelLogger {
+	if ll, ok := logger.(LevelLogger); ok {
+		return ll
+	}
+	return fmtLogger{l: logger, f: prefixFormatter{}}
+}
+
+// sink 由本包的Logger实现，用于逐层传递级别，
+// 使WithPrefix等包装后仍能按级别过滤且不做多余的格式化。
+type sink interface {
+	enabled(level Level) bool
+	// logf force为true时外层已检查过级别
+	logf(level Level, force bool, fields []Field, format string, a []interface{})
+}
+
+// logf 通过sink或对应级别的方法输出
+func logf(l Logger, level Level, force bool, fields []Field, format string, a []interface{}) {
+	if s, ok := l.(sink); ok {
+		s.logf(level, force, fields, format, a)
+		return
+	}
+	if len(fields) > 0 { // 其它Logger不支持字段，按logfmt追加到末尾
+		buf := &bytes.Buffer{}
+		appendLogfmtFields(buf, fields)
+		format += strings.Replace(buf.String(), "%", "%%", -1)
+	}
+	ll, leveled := l.(LevelLogger)
+	switch level {
+	case LevelDebug:
+		if leveled {
+			ll.Debugf(format, a...)
+		} else {
+			l.Infof(format, a...)
+		}
+	case LevelInfo:
+		l.Infof(format, a...)
+	case LevelWarn:
+		l.Warnf(format, a...)
+	case LevelError:
+		l.Errorf(format, a...)
+	default:
+		if leveled {
+			ll.Fatalf(format, a...)
+		} else {
+			l.Errorf(format, a...)
+			exit(1)
+		}
+	}
+}
+
+var exit = os.Exit
+
 /*
 WithPrefix 用于添加日志公共前缀，例如：
 
@@ -53,7 +119,7 @@ func init() {
 	SetOutput(os.Stderr)
 }
 
-// New 返回一个Logger，可在单独逻辑内使用。
+// New 返回一个Logger，可在单独逻辑内使用，受全局级别限制。
 func New(w io.Writer) Logger {
 	l := log.New(w, "", log.LstdFlags|log.Lmicroseconds)
 	return newLogger(l)
@@ -71,6 +137,10 @@ func Get() Logger {
 	return _logger
 }
 
+func Debugf(format string, a ...interface{}) {
+	logf(_logger, LevelDebug, false, nil, format, a)
+}
+
 func Infof(format string, a ...interface{}) {
 	_logger.Infof(format, a...)
 }
@@ -83,6 +153,10 @@ func Errorf(format string, a ...interface{}) {
 	_logger.Errorf(format, a...)
 }
 
+func Fatalf(format string, a ...interface{}) {
+	logf(_logger, LevelFatal, false, nil, format, a)
+}
+
 // - - - - - - - - - - format logger - - - - - - - - - -
 
 type formatter interface {
@@ -110,65 +184,88 @@ type fmtLogger struct {
 	f formatter
 }
 
+func (fl fmtLogger) enabled(level Level) bool {
+	return Enabled(fl.l, level)
+}
+
+func (fl fmtLogger) logf(level Level, force bool, fields []Field, format string, a []interface{}) {
+	if !force && !fl.enabled(level) {
+		return
+	}
+	logf(fl.l, level, force, fields, fl.f.format(format), a)
+}
+
+func (fl fmtLogger) Debugf(format string, a ...interface{}) {
+	fl.logf(LevelDebug, false, nil, format, a)
+}
+
 func (fl fmtLogger) Infof(format string, a ...interface{}) {
-	fl.l.Infof(fl.f.format(format), a...)
+	fl.logf(LevelInfo, false, nil, format, a)
 }
 
 func (fl fmtLogger) Warnf(format string, a ...interface{}) {
-	fl.l.Warnf(fl.f.format(format), a...)
+	fl.logf(LevelWarn, false, nil, format, a)
 }
 
 func (fl fmtLogger) Errorf(format string, a ...interface{}) {
-	fl.l.Errorf(fl.f.format(format), a...)
+	fl.logf(LevelError, false, nil, format, a)
+}
+
+func (fl fmtLogger) Fatalf(format string, a ...interface{}) {
+	fl.logf(LevelFatal, false, nil, format, a)
 }
 
-// - - - - - - - - - - go logger - - - - - - - - - -
+// - - - - - - - - - - common logger - - - - - - - - - -
 
 type printer interface {
 	Printf(format string, a ...interface{})
 }
 
-type printLogger struct {
-	l printer
-}
-
-func (p printLogger) Infof(format string, a ...interface{}) {
-	p.l.Printf(format, a...)
+// logger 在每行前添加级别标签，如"[INFO] "，字段按logfmt追加到末尾
+type logger struct {
+	p printer
 }
 
-func (p printLogger) Warnf(format string, a ...interface{}) {
-	p.l.Printf(format, a...)
+func newLogger(p printer) logger {
+	return logger{p: p}
 }
 
-func (p printLogger) Errorf(format string, a ...interface{}) {
-	p.l.Printf(format, a...)
+func (l logger) enabled(level Level) bool {
+	return _level.Enabled(level)
 }
 
-// - - - - - - - - - - common logger - - - - - - - - - -
-
-type logger struct {
-	info Logger
-	warn Logger
-	err  Logger
+func (l logger) logf(level Level, force bool, fields []Field, format string, a []interface{}) {
+	if force || l.enabled(level) {
+		if len(fields) == 0 {
+			l.p.Printf("["+level.String()+"] "+format, a...)
+		} else {
+			buf := &bytes.Buffer{}
+			fmt.Fprintf(buf, format, a...)
+			appendLogfmtFields(buf, fields)
+			l.p.Printf("[%v] %s", level, buf.Bytes())
+		}
+	}
+	if level == LevelFatal {
+		exit(1)
+	}
 }
 
-func newLogger(p printer) logger {
-	gl := printLogger{l: p}
-	return logger{
-		info: WithPrefix(gl, "[INFO] "),
-		warn: WithPrefix(gl, "[WARN] "),
-		err:  WithPrefix(gl, "[ERR] "),
-	}
+func (l logger) Debugf(format string, a ...interface{}) {
+	l.logf(LevelDebug, false, nil, format, a)
 }
 
 func (l logger) Infof(format string, a ...interface{}) {
-	l.info.Infof(format, a...)
+	l.logf(LevelInfo, false, nil, format, a)
 }
 
 func (l logger) Warnf(format string, a ...interface{}) {
-	l.warn.Warnf(format, a...)
+	l.logf(LevelWarn, false, nil, format, a)
 }
 
 func (l logger) Errorf(format string, a ...interface{}) {
-	l.err.Errorf(format, a...)
+	l.logf(LevelError, false, nil, format, a)
+}
+
+func (l logger) Fatalf(format string, a ...interface{}) {
+	l.logf(LevelFatal, false, nil, format, a)
 }